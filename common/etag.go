@@ -0,0 +1,35 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+)
+
+// ETag is the entity tag of a record, derived from its updated_at with the microseconds stored by the database
+func ETag(updated_at time.Time) string {
+	return fmt.Sprintf("\"%d\"", updated_at.UnixMicro())
+}
+
+// SetETag adds the entity tag of the record to the response, it must be called before sending the body
+func SetETag(w http.ResponseWriter, updated_at time.Time) {
+	w.Header().Set("ETag", ETag(updated_at))
+}
+
+// ParseIfMatch returns the updated_at the If-Match header of the request expects, zero when the header
+// is missing or is *. A tag that was not made by ETag can never match, so it fails with PC001
+func ParseIfMatch(r *http.Request) (time.Time, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return time.Time{}, nil
+	}
+	micro, err := strconv.ParseInt(strings.Trim(header, "\""), 10, 64)
+	if err != nil || !strings.HasPrefix(header, "\"") || !strings.HasSuffix(header, "\"") {
+		return time.Time{}, fmt.Errorf(errors_handler.PC001)
+	}
+	return time.UnixMicro(micro), nil
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -49,9 +50,45 @@ func SendInvalidUUIDError(w http.ResponseWriter, msg string) {
 func SendServiceError(w http.ResponseWriter, msg string) {
 	// errors here may vary depending on the service
 	errorCode := errors_handler.MapServiceError(msg)
+	if msg == errors_handler.PC001 {
+		SendPreconditionFailedError(w)
+		return
+	}
 	sendJsonError(w, http.StatusBadRequest, errorCode, msg)
 }
 
+// SendPreconditionFailedError is sent when the If-Match header does not match the current version of the record
+func SendPreconditionFailedError(w http.ResponseWriter) {
+	sendJsonError(w, http.StatusPreconditionFailed, "PC001", errors_handler.PC001)
+}
+
+// SendAuthError is sent when the request could not be authenticated
+func SendAuthError(w http.ResponseWriter, msg string) {
+	errorCode := errors_handler.MapServiceError(msg)
+	sendJsonError(w, http.StatusUnauthorized, errorCode, msg)
+}
+
+// SendForbiddenError is sent when the user is authenticated but its role can not call the route
+func SendForbiddenError(w http.ResponseWriter, msg string) {
+	errorCode := errors_handler.MapServiceError(msg)
+	sendJsonError(w, http.StatusForbidden, errorCode, msg)
+}
+
+// SendConflictError is sent when the request clashes with a previous one
+func SendConflictError(w http.ResponseWriter, msg string) {
+	errorCode := errors_handler.MapServiceError(msg)
+	sendJsonError(w, http.StatusConflict, errorCode, msg)
+}
+
 func SendInvalidQueryStringError(w http.ResponseWriter, msg string) {
 	sendJsonError(w, http.StatusBadRequest, "QS001", msg)
 }
+
+// SendCsv writes the records as a csv attachment called filename
+func SendCsv(w http.ResponseWriter, httpCode int, filename string, records [][]string) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	w.WriteHeader(httpCode)
+	writer := csv.NewWriter(w)
+	writer.WriteAll(records)
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
 	"github.com/grabielcruz/transportation_back/utility"
@@ -116,3 +117,33 @@ func TestSendJson(t *testing.T) {
 
 	})
 }
+
+func TestETag(t *testing.T) {
+	updatedAt := time.Date(2023, 5, 17, 10, 30, 0, 123456000, time.UTC)
+	tag := ETag(updatedAt)
+	assert.Equal(t, "\"1684319400123456\"", tag)
+
+	req, err := http.NewRequest(http.MethodPatch, "/", nil)
+	assert.Nil(t, err)
+	ifMatch, err := ParseIfMatch(req)
+	assert.Nil(t, err)
+	assert.True(t, ifMatch.IsZero())
+
+	req.Header.Set("If-Match", "*")
+	ifMatch, err = ParseIfMatch(req)
+	assert.Nil(t, err)
+	assert.True(t, ifMatch.IsZero())
+
+	req.Header.Set("If-Match", tag)
+	ifMatch, err = ParseIfMatch(req)
+	assert.Nil(t, err)
+	assert.True(t, updatedAt.Equal(ifMatch))
+
+	req.Header.Set("If-Match", "1684319400123456")
+	_, err = ParseIfMatch(req)
+	assert.Equal(t, errors_handler.PC001, err.Error())
+
+	w := httptest.NewRecorder()
+	SendServiceError(w, errors_handler.PC001)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
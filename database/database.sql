@@ -1,6 +1,14 @@
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
 
+DROP TABLE IF EXISTS idempotency_keys CASCADE;
+DROP TABLE IF EXISTS audit_log CASCADE;
+DROP TABLE IF EXISTS sessions CASCADE;
+DROP TABLE IF EXISTS users CASCADE;
+DROP TABLE IF EXISTS closed_periods CASCADE;
+DROP TABLE IF EXISTS recurring_bill_occurrences CASCADE;
+DROP TABLE IF EXISTS recurring_bills CASCADE;
+DROP TABLE IF EXISTS transfers CASCADE;
 DROP TABLE IF EXISTS closed_bills CASCADE;
 DROP TABLE IF EXISTS pending_bills CASCADE;
 DROP TYPE IF EXISTS bill_status CASCADE;
@@ -8,6 +16,7 @@ DROP TABLE IF EXISTS bill_cross CASCADE;
 DROP TABLE IF EXISTS transactions CASCADE;
 DROP TABLE IF EXISTS persons CASCADE;
 DROP TABLE IF EXISTS money_accounts CASCADE;
+DROP TABLE IF EXISTS exchange_rates CASCADE;
 DROP TABLE IF EXISTS currencies CASCADE;
 
 CREATE TABLE currencies (
@@ -17,14 +26,32 @@ CREATE TABLE currencies (
 -- zero currency is 000
 INSERT INTO currencies (currency) VALUES ('000'), ('VED'), ('USD');
 
+-- how many to_currency units a from_currency unit was worth on a date
+CREATE TABLE exchange_rates (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+  from_currency VARCHAR (3) NOT NULL,
+  to_currency VARCHAR (3) NOT NULL,
+  date DATE NOT NULL,
+  rate NUMERIC(17,6) NOT NULL CHECK (rate > 0),
+  created_at TIMESTAMPTZ DEFAULT NOW(), 
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+  UNIQUE (from_currency, to_currency, date),
+  FOREIGN KEY (from_currency) REFERENCES currencies(currency) ON DELETE CASCADE,
+  FOREIGN KEY (to_currency) REFERENCES currencies(currency) ON DELETE CASCADE
+);
+
 CREATE TABLE money_accounts (
   id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
   name VARCHAR NOT NULL,
-  balance NUMERIC(17,2) DEFAULT 0.00 CHECK (balance >= 0),
+  balance NUMERIC(17,2) DEFAULT 0.00,
   details VARCHAR NOT NULL,
   currency VARCHAR (3) NOT NULL,
   created_at TIMESTAMPTZ DEFAULT NOW(), 
   updated_at TIMESTAMPTZ DEFAULT NOW(),
+  -- null when the account does not charge fees
+  fee_rule JSONB,
+  -- balance can go down to -credit_limit
+  credit_limit NUMERIC(17,2) NOT NULL DEFAULT 0.00 CHECK (credit_limit >= 0),
   FOREIGN KEY (currency) REFERENCES currencies(currency)
 );
 
@@ -50,15 +77,18 @@ CREATE TABLE transactions (
   person_id uuid NOT NULL,
   date DATE DEFAULT NOW(),
   amount NUMERIC(17,2) NOT NULL,
-  fee NUMERIC(17,2) DEFAULT 0.00 CHECK (fee >= 0),
+  fee NUMERIC(17,6) DEFAULT 0 CHECK (fee >= 0),
   amount_with_fee NUMERIC(17,2) NOT NULL,
   description VARCHAR NOT NULL,
-  balance NUMERIC(17,2) NOT NULL CHECK (balance >= 0),
+  balance NUMERIC(17,2) NOT NULL,
   pending_bill_id uuid DEFAULT uuid_nil(),
   closed_bill_id uuid DEFAULT uuid_nil(),
   revert_bill_id uuid DEFAULT uuid_nil(),
+  -- balances are chained in this order, it is written with clock_timestamp() after the account is locked
   created_at TIMESTAMPTZ DEFAULT NOW(), 
   updated_at TIMESTAMPTZ DEFAULT NOW(),
+  -- rule of the account used to compute the fee, null when the fee was explicit
+  fee_rule JSONB,
   FOREIGN KEY (account_id) REFERENCES money_accounts(id),
   FOREIGN KEY (person_id) REFERENCES persons(id)
 );
@@ -122,6 +152,8 @@ CREATE TABLE closed_bills (
   bill_cross_id uuid,
   revert_transaction_id uuid,
   --
+  -- pending bill it was paid from, several closed bills share it when paid partially
+  origin_bill_id uuid DEFAULT uuid_nil(),
   post_notes VARCHAR,
   created_at TIMESTAMPTZ DEFAULT NOW(), 
   updated_at TIMESTAMPTZ DEFAULT NOW(),
@@ -143,3 +175,119 @@ ALTER TABLE transactions
   ADD CONSTRAINT fk_transactions_closed_bills FOREIGN KEY (closed_bill_id) REFERENCES closed_bills (id);
 ALTER TABLE transactions
   ADD CONSTRAINT fk_revert_closed_bills FOREIGN KEY (revert_bill_id) REFERENCES closed_bills (id);
+
+-- a transfer moves money between two accounts, each side is registered as a transaction without person
+-- amount is debited from the first account, amount * rate is credited to the second one
+CREATE TABLE transfers (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+  from_account_id uuid NOT NULL,
+  to_account_id uuid NOT NULL,
+  date DATE DEFAULT NOW(),
+  amount NUMERIC(17,2) NOT NULL CHECK (amount > 0),
+  fee NUMERIC(17,6) DEFAULT 0 CHECK (fee >= 0),
+  rate NUMERIC(17,6) DEFAULT 1 CHECK (rate > 0),
+  credited_amount NUMERIC(17,2) NOT NULL,
+  description VARCHAR NOT NULL,
+  from_transaction_id uuid NOT NULL,
+  to_transaction_id uuid NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(), 
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+  FOREIGN KEY (from_account_id) REFERENCES money_accounts(id),
+  FOREIGN KEY (to_account_id) REFERENCES money_accounts(id),
+  FOREIGN KEY (from_transaction_id) REFERENCES transactions(id),
+  FOREIGN KEY (to_transaction_id) REFERENCES transactions(id)
+);
+
+-- template of pending bills generated on a schedule
+CREATE TABLE recurring_bills (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+  person_id uuid NOT NULL,
+  description VARCHAR NOT NULL,
+  currency VARCHAR (3) NOT NULL,
+  amount NUMERIC(17,2) NOT NULL CHECK (amount <> 0),
+  frequency VARCHAR NOT NULL CHECK (frequency IN ('MONTHLY', 'WEEKLY', 'DAILY')),
+  -- only used by MONTHLY
+  day_of_month INTEGER NOT NULL DEFAULT 0,
+  -- only used by DAILY
+  interval_days INTEGER NOT NULL DEFAULT 0,
+  start_date DATE NOT NULL,
+  -- null when it has no end
+  end_date DATE,
+  paused BOOLEAN NOT NULL DEFAULT false,
+  paused_at DATE,
+  created_at TIMESTAMPTZ DEFAULT NOW(), 
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+  FOREIGN KEY (person_id) REFERENCES persons(id),
+  FOREIGN KEY (currency) REFERENCES currencies(currency)
+);
+
+-- every date a recurring bill was due, so its bill is created only once.
+-- bill_id is null when the date was skipped because the recurring bill was paused
+-- or because the date was inside a closed period
+CREATE TABLE recurring_bill_occurrences (
+  recurring_bill_id uuid NOT NULL,
+  date DATE NOT NULL,
+  bill_id uuid,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  PRIMARY KEY (recurring_bill_id, date),
+  FOREIGN KEY (recurring_bill_id) REFERENCES recurring_bills(id) ON DELETE CASCADE
+);
+
+-- transactions and bills dated on or before closed_until can not be created, edited or deleted.
+-- the zero account closes the period for every account and for the bills
+CREATE TABLE closed_periods (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+  account_id uuid NOT NULL DEFAULT uuid_nil(),
+  closed_until DATE NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(), 
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+  UNIQUE (account_id, closed_until),
+  FOREIGN KEY (account_id) REFERENCES money_accounts(id) ON DELETE CASCADE
+);
+
+-- password_hash is pbkdf2_sha256$iterations$salt$hash
+CREATE TABLE users (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+  username VARCHAR NOT NULL UNIQUE,
+  password_hash VARCHAR NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW(), 
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+  role VARCHAR NOT NULL DEFAULT 'READ_ONLY' CHECK (role IN ('ADMIN', 'ACCOUNTANT', 'OPERATOR', 'READ_ONLY'))
+);
+
+-- a token is valid while its session is not closed and has not expired
+CREATE TABLE sessions (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+  user_id uuid NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  closed_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- entity_id is text since currencies are identified by their code. The zero actor is the server itself,
+-- the actor is not a foreign key so the entries remain after the user is deleted
+CREATE TABLE audit_log (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid (),
+  actor_id uuid NOT NULL DEFAULT uuid_nil(),
+  entity_type VARCHAR NOT NULL,
+  entity_id VARCHAR NOT NULL,
+  action VARCHAR NOT NULL,
+  before JSONB,
+  after JSONB,
+  created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- status_code is 0 while the first request is in progress, response holds the body sent to it.
+-- The keys are scoped by user, the zero user is a request without token
+CREATE TABLE idempotency_keys (
+  user_id uuid NOT NULL DEFAULT uuid_nil(),
+  idempotency_key VARCHAR NOT NULL,
+  request_hash VARCHAR NOT NULL,
+  status_code INTEGER NOT NULL DEFAULT 0,
+  response BYTEA,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  -- headers of the stored response, replayed with it
+  headers JSONB,
+  PRIMARY KEY (user_id, idempotency_key)
+);
@@ -39,13 +39,21 @@ const CU001 = "Could not delete VED or USD currency"
 const CU002 = "Currency code should be 3 upper case letters"
 const CU003 = "Currency already exists"
 const CU004 = "Currency is being used"
+const CU006 = "Exchange rate already registered for those currencies on that date"
+const CU007 = "No exchange rate registered on or before the date"
+const CU008 = "Exchange rate should be greater than zero"
 
 // Foreign Key error
 const CU005 = "Currency it is not registered in database"
 
+// Money accounts
+const MA001 = "Account statement does not reconcile with the stored balances"
+const MA002 = "Credit limit can not be lower than the current overdraft of the account"
+const MA003 = "Money account does not exists"
+
 // Transactions
 const TR001 = "Could not get balance from account"
-const TR002 = "Transaction should not generate a negative balance"
+const TR002 = "Transaction should not take the balance below the credit limit of the account, headroom left is %v"
 const TR003 = "The transaction requested is not the last transaction"
 
 // const TR004 = "No transaction found in database"
@@ -54,11 +62,52 @@ const TR006 = "New balance and updated balance missmatch, oldBalance = %v, newBa
 const TR007 = "Transaction should have a person"
 const TR008 = "Transaction should have an amount different from zero"
 const TR009 = "Fee should be between 0 and 1"
+const TR010 = "Transaction currency should be the same as the bill currency"
+const TR011 = "Transaction that closed or reverted a bill, or whose bill was paid, can not be modified"
+const TR012 = "Transaction whose bill is already closed can not be deleted"
+const TR013 = "Transaction that belongs to a transfer can not be modified"
+const TR014 = "Transaction should have a date"
+
+// Transfers
+const TF001 = "Transfer accounts should be different"
+const TF002 = "Transfer amount should be greater than zero"
+const TF003 = "Transfer between different currencies requires an exchange rate greater than zero"
 
 // Bills
 const BL001 = "Could not request empty set of bills"
 const BL002 = "Can not create bill with amount of zero"
 const BL003 = "Can not delete pending bill associated to transaction"
+const BL004 = "Transaction amount should have the same sign as the bill amount"
+const BL005 = "Transaction amount should not be greater than the bill amount"
+const BL006 = "Only bills solved by a transaction can be reverted"
+const BL007 = "Bills in a cross should belong to the same person"
+const BL008 = "Bills in a cross should have the same currency"
+const BL009 = "Closed bill status should be SOLVED, REVERTED or GROUPED"
+
+// Recurring bills
+const RB001 = "Recurring bill is already paused"
+const RB002 = "Recurring bill is not paused"
+
+// Users
+const US001 = "Username already in use"
+const US002 = "Invalid username or password"
+
+// Authentication
+const AU001 = "Missing or invalid bearer token"
+const AU002 = "Session expired or closed"
+const AU003 = "Role is not allowed to call this route"
+
+// Idempotency keys
+const IK001 = "Idempotency key already used with a different request"
+const IK002 = "A request with the same idempotency key is still in progress"
+const IK003 = "Idempotency key should have between 1 and 255 characters"
+
+// Preconditions
+const PC001 = "Record was modified since it was read"
+
+// Closed periods
+const CP001 = "Date belongs to a closed accounting period"
+const CP002 = "Period already closed up to that date for the account"
 
 // const BL003 = "Person with the specified uuid does not exists"
 // const BL004 = "Currency it is not registered in database"
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 )
 
 // module level
@@ -43,6 +44,12 @@ func MapDBErrors(err error) error {
 		return fmt.Errorf(CU003)
 	case "pq: update or delete on table \"currencies\" violates foreign key constraint \"money_accounts_currency_fkey\" on table \"money_accounts\"":
 		return fmt.Errorf(CU004)
+	case "pq: duplicate key value violates unique constraint \"exchange_rates_from_currency_to_currency_date_key\"":
+		return fmt.Errorf(CU006)
+	case "pq: insert or update on table \"exchange_rates\" violates foreign key constraint \"exchange_rates_from_currency_fkey\"":
+		return fmt.Errorf(CU005)
+	case "pq: insert or update on table \"exchange_rates\" violates foreign key constraint \"exchange_rates_to_currency_fkey\"":
+		return fmt.Errorf(CU005)
 
 	// Persons
 	case "pq: duplicate key value violates unique constraint \"persons_document_key\"":
@@ -53,13 +60,42 @@ func MapDBErrors(err error) error {
 		return fmt.Errorf(CU005)
 	case "pq: insert or update on table \"closed_bills\" violates foreign key constraint \"pending_bills_currency_fkey\"":
 		return fmt.Errorf(CU005)
+	case "pq: insert or update on table \"bill_cross\" violates foreign key constraint \"bill_cross_currency_fkey\"":
+		return fmt.Errorf(CU005)
 	case "pq: update or delete on table \"pending_bills\" violates foreign key constraint \"fk_transactions_pending_bills\" on table \"transactions\"":
 		return fmt.Errorf(BL003)
+
+	// Recurring bills
+	case "pq: insert or update on table \"recurring_bills\" violates foreign key constraint \"recurring_bills_person_id_fkey\"":
+		return fmt.Errorf(PE002)
+	case "pq: insert or update on table \"recurring_bills\" violates foreign key constraint \"recurring_bills_currency_fkey\"":
+		return fmt.Errorf(CU005)
+
+	// Users
+	case "pq: duplicate key value violates unique constraint \"users_username_key\"":
+		return fmt.Errorf(US001)
+
+	// Closed periods
+	case "pq: duplicate key value violates unique constraint \"closed_periods_account_id_closed_until_key\"":
+		return fmt.Errorf(CP002)
+	case "pq: insert or update on table \"closed_periods\" violates foreign key constraint \"closed_periods_account_id_fkey\"":
+		return fmt.Errorf(MA003)
 	}
 	return err
 }
 
+// formattedErrors are the messages that carry values, they are matched by the text before the first verb
+var formattedErrors = map[string]string{
+	TR002: "TR002",
+	TR006: "TR006",
+}
+
 func MapServiceError(error_msg string) string {
+	for format, code := range formattedErrors {
+		if strings.HasPrefix(error_msg, strings.SplitN(format, "%", 2)[0]) {
+			return code
+		}
+	}
 	switch error_msg {
 	// database
 	case DB001:
@@ -74,6 +110,8 @@ func MapServiceError(error_msg string) string {
 		return "DB005"
 	case DB007:
 		return "DB007"
+	case DB009:
+		return "DB009"
 
 	// currencies
 	case CU001:
@@ -84,6 +122,14 @@ func MapServiceError(error_msg string) string {
 		return "CU003"
 	case CU004:
 		return "CU004"
+	case CU005:
+		return "CU005"
+	case CU006:
+		return "CU006"
+	case CU007:
+		return "CU007"
+	case CU008:
+		return "CU008"
 
 	// persons
 	case PE001:
@@ -91,23 +137,45 @@ func MapServiceError(error_msg string) string {
 	case PE002:
 		return "PE002"
 
+	// money accounts
+	case MA001:
+		return "MA001"
+	case MA002:
+		return "MA002"
+	case MA003:
+		return "MA003"
+
 	// transactions
 	case TR001:
 		return "TR001"
-	case TR002:
-		return "TR002"
 	case TR003:
 		return "TR003"
 	case TR005:
 		return "TR005"
-	case TR006:
-		return "TR006"
 	case TR007:
 		return "TR007"
 	case TR008:
 		return "TR008"
 	case TR009:
 		return "TR009"
+	case TR010:
+		return "TR010"
+	case TR011:
+		return "TR011"
+	case TR012:
+		return "TR012"
+	case TR013:
+		return "TR013"
+	case TR014:
+		return "TR014"
+
+	// transfers
+	case TF001:
+		return "TF001"
+	case TF002:
+		return "TF002"
+	case TF003:
+		return "TF003"
 
 	// bills
 	case BL001:
@@ -116,6 +184,54 @@ func MapServiceError(error_msg string) string {
 		return "BL002"
 	case BL003:
 		return "BL003"
+	case BL004:
+		return "BL004"
+	case BL005:
+		return "BL005"
+	case BL006:
+		return "BL006"
+	case BL007:
+		return "BL007"
+	case BL008:
+		return "BL008"
+	case BL009:
+		return "BL009"
+
+	// recurring bills
+	case RB001:
+		return "RB001"
+	case RB002:
+		return "RB002"
+
+	// users
+	case US001:
+		return "US001"
+	case US002:
+		return "US002"
+	case AU001:
+		return "AU001"
+	case AU002:
+		return "AU002"
+	case AU003:
+		return "AU003"
+
+	// idempotency keys
+	case IK001:
+		return "IK001"
+	case IK002:
+		return "IK002"
+	case IK003:
+		return "IK003"
+
+	// preconditions
+	case PC001:
+		return "PC001"
+
+	// closed periods
+	case CP001:
+		return "CP001"
+	case CP002:
+		return "CP002"
 
 	//default
 	default:
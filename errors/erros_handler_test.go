@@ -1,6 +1,7 @@
 package errors_handler
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"testing"
@@ -39,3 +40,16 @@ func TestHandleError(t *testing.T) {
 
 	ResetFile(TestPath)
 }
+
+func TestMapServiceError(t *testing.T) {
+	assert.Equal(t, "DB001", MapServiceError(DB001))
+	assert.Equal(t, "TR002", MapServiceError(fmt.Sprintf(TR002, "10.00")))
+	assert.Equal(t, "MA002", MapServiceError(MA002))
+	assert.Equal(t, "CP001", MapServiceError(CP001))
+	assert.Equal(t, "AU002", MapServiceError(AU002))
+	assert.Equal(t, "AU003", MapServiceError(AU003))
+	assert.Equal(t, "IK001", MapServiceError(IK001))
+	assert.Equal(t, "IK003", MapServiceError(IK003))
+	assert.Equal(t, "PC001", MapServiceError(PC001))
+	assert.Equal(t, "SE001", MapServiceError("unknown"))
+}
@@ -1,22 +1,100 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/grabielcruz/transportation_back/database"
+	"github.com/grabielcruz/transportation_back/environment"
+	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/grabielcruz/transportation_back/modules/recurring_bills"
+	"github.com/grabielcruz/transportation_back/modules/transactions"
+	"github.com/grabielcruz/transportation_back/modules/users"
 	"github.com/grabielcruz/transportation_back/routes"
 )
 
+// shippedDefaults are the values of auth_secret and admin_password once published
+// with the repository, anyone could sign tokens or log in with them
+var shippedDefaults = map[string]bool{
+	"local development secret for session tokens": true,
+	"admin1234": true,
+	"a long random string that signs the session tokens": true,
+	"change this password":                               true,
+}
+
 func main() {
+	checkLedger := flag.Bool("check-ledger", false, "check the ledger of the existing database and exit")
+	repair := flag.Bool("repair", false, "with check-ledger, fix the inconsistencies found")
+	flag.Parse()
+
 	envPath := filepath.Clean(".env")
 	database.SetupDB(envPath)
+	env := environment.LoadEnvironment(envPath)
+
+	if *checkLedger {
+		// tables are not created again, they would be emptied
+		code := runLedgerCheck(*repair)
+		database.CloseConnection()
+		os.Exit(code)
+	}
+	defer database.CloseConnection()
+
+	for _, key := range []string{"auth_secret", "admin_password"} {
+		if env[key] == "" {
+			log.Fatal(key, " is missing in ", envPath)
+		}
+		if shippedDefaults[env[key]] {
+			log.Fatal(key, " is still set to a shipped default in ", envPath, ", change it")
+		}
+	}
+
 	sqlPath := filepath.Clean("database/database.sql")
 	database.CreateTables(sqlPath)
-	defer database.CloseConnection()
+
+	users.SetSecret(env["auth_secret"])
+	if env["idempotency_key_ttl"] != "" {
+		ttl, err := time.ParseDuration(env["idempotency_key_ttl"])
+		if err != nil || ttl <= 0 {
+			log.Fatal("idempotency_key_ttl should be a positive duration like 24h in ", envPath)
+		}
+		config.IdempotencyKeyTTL = ttl
+	}
+	created, err := users.CreateFirstUser(users.UserFields{Username: env["admin_username"], Password: env["admin_password"]})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if created {
+		log.Println("User", env["admin_username"], "created")
+	}
+
+	go recurring_bills.StartScheduler(config.SchedulerInterval)
 
 	router := routes.SetupAndGetRoutes()
 
 	log.Fatal(http.ListenAndServe(":8080", router))
 }
+
+// runLedgerCheck prints the ledger report and returns the exit code,
+// 1 when there are issues left unrepaired
+func runLedgerCheck(repair bool) int {
+	report, err := transactions.CheckLedger(repair)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Println(err)
+		return 1
+	}
+	if len(report.Issues) > 0 && !report.Repaired {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package audit_log
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/grabielcruz/transportation_back/modules/users"
+	"github.com/julienschmidt/httprouter"
+)
+
+// ActorId returns the user who makes the request, the services record it with their writes.
+// Requests without claims are made by the system actor
+func ActorId(r *http.Request) uuid.UUID {
+	if claims, err := users.GetClaims(r); err == nil {
+		return claims.UserId
+	}
+	return SystemActor
+}
+
+// GetAuditLogHandler lists the entries of the audit log, every filter in the query string is optional
+// but limit and offset
+func GetAuditLogHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	filter := AuditLogFilter{}
+	query := r.URL.Query()
+	var err error
+
+	filter.EntityType = query.Get("entity_type")
+	filter.EntityId = query.Get("entity_id")
+	if query.Get("from") != "" {
+		filter.From, err = time.Parse(config.DateLayout, query.Get("from"))
+		if err != nil {
+			common.SendInvalidQueryStringError(w, err.Error())
+			return
+		}
+	}
+	if query.Get("to") != "" {
+		filter.To, err = time.Parse(config.DateLayout, query.Get("to"))
+		if err != nil {
+			common.SendInvalidQueryStringError(w, err.Error())
+			return
+		}
+	}
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil {
+		common.SendInvalidQueryStringError(w, err.Error())
+		return
+	}
+	offset, err := strconv.Atoi(query.Get("offset"))
+	if err != nil {
+		common.SendInvalidQueryStringError(w, err.Error())
+		return
+	}
+	response, err := GetAuditLog(filter, limit, offset)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, response)
+}
@@ -0,0 +1,82 @@
+package audit_log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/users"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLogHandlers(t *testing.T) {
+	envPath := filepath.Clean("../../.env_test")
+	sqlPath := filepath.Clean("../../database/database.sql")
+	database.SetupDB(envPath)
+	database.CreateTables(sqlPath)
+	defer database.CloseConnection()
+	users.SetSecret("secret used to sign the tokens of the tests")
+	router := httprouter.New()
+	Routes(router)
+	entityId := uuid.New().String()
+	router.POST("/write", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		tx, err := database.DB.Begin()
+		assert.Nil(t, err)
+		_, err = Record(tx, ActorId(r), PersonEntity, entityId, CreateAction, nil, state{"john"})
+		assert.Nil(t, err)
+		assert.Nil(t, tx.Commit())
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler := users.RequireAuth(router)
+
+	fields := users.GenerateUserFields()
+	fields.Role = users.AdminRole
+	user, err := users.CreateUser(fields)
+	assert.Nil(t, err)
+	session, err := users.Login(fields)
+	assert.Nil(t, err)
+
+	t.Run("Record the user of the request and query the audit log", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/write", nil)
+		assert.Nil(t, err)
+		req.Header.Set("Authorization", "Bearer "+session.Token)
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		w2 := httptest.NewRecorder()
+		req2, err := http.NewRequest(http.MethodGet, "/audit_log?entity_type=person&entity_id="+entityId+"&limit=10&offset=0", nil)
+		assert.Nil(t, err)
+		req2.Header.Set("Authorization", "Bearer "+session.Token)
+		handler.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusOK, w2.Code)
+		response := AuditLogResponse{}
+		err = json.Unmarshal(w2.Body.Bytes(), &response)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, response.Count)
+		assert.Equal(t, user.ID, response.Entries[0].ActorId)
+		assert.JSONEq(t, `{"name": "john"}`, string(response.Entries[0].After))
+	})
+
+	t.Run("Error when sending a bad date", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/audit_log?from=yesterday&limit=10&offset=0", nil)
+		assert.Nil(t, err)
+		req.Header.Set("Authorization", "Bearer "+session.Token)
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var errResponse errors_handler.ErrorResponse
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "QS001", errResponse.Code)
+	})
+
+	DeleteAllAuditLog()
+	users.DeleteAllUsers()
+}
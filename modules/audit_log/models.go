@@ -0,0 +1,61 @@
+package audit_log
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/common"
+)
+
+// SystemActor is the actor of the writes made by the server itself, like the recurring bills scheduler
+// or the ledger repair
+var SystemActor = uuid.UUID{}
+
+// entities whose writes are recorded
+const (
+	PersonEntity       = "person"
+	MoneyAccountEntity = "money_account"
+	CurrencyEntity     = "currency"
+	ExchangeRateEntity = "exchange_rate"
+	TransactionEntity  = "transaction"
+	TransferEntity     = "transfer"
+	BillEntity         = "bill"
+	BillCrossEntity    = "bill_cross"
+)
+
+const (
+	CreateAction = "CREATE"
+	UpdateAction = "UPDATE"
+	DeleteAction = "DELETE"
+	// a pending bill closed by a payment, whole or partial
+	CloseAction = "CLOSE"
+	// a closed bill opened again
+	RevertAction = "REVERT"
+)
+
+// Entry is a write on an entity, Before is null for creations and After is null for deletions
+type Entry struct {
+	ID         uuid.UUID       `json:"id"`
+	ActorId    uuid.UUID       `json:"actor_id"`
+	EntityType string          `json:"entity_type"`
+	EntityId   string          `json:"entity_id"`
+	Action     string          `json:"action"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditLogResponse struct {
+	Entries []Entry `json:"entries"`
+	common.Pagination
+}
+
+// AuditLogFilter holds the optional filters of the entries, zero values are ignored.
+// From and To limit the day the entry was created, both included
+type AuditLogFilter struct {
+	EntityType string
+	EntityId   string
+	From       time.Time
+	To         time.Time
+}
@@ -0,0 +1,7 @@
+package audit_log
+
+import "github.com/julienschmidt/httprouter"
+
+func Routes(router *httprouter.Router) {
+	router.GET("/audit_log", GetAuditLogHandler)
+}
@@ -0,0 +1,128 @@
+package audit_log
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+)
+
+// Record adds an entry for a write on an entity inside tx, the database transaction of the write, so the entry
+// is kept only when the write is committed. Before and after are stored as json and nil is stored as null
+func Record(tx *sql.Tx, actor_id uuid.UUID, entity_type string, entity_id string, action string, before any, after any) (Entry, error) {
+	beforeJson, err := marshalState(before)
+	if err != nil {
+		return Entry{}, err
+	}
+	afterJson, err := marshalState(after)
+	if err != nil {
+		return Entry{}, err
+	}
+	row := tx.QueryRow("INSERT INTO audit_log (actor_id, entity_type, entity_id, action, before, after) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;", actor_id, entity_type, entity_id, action, beforeJson, afterJson)
+	entry, err := scanEntry(row)
+	if err != nil {
+		return entry, errors_handler.MapDBErrors(err)
+	}
+	return entry, nil
+}
+
+// marshalState returns nil for a nil state so the column is null
+func marshalState(state any) ([]byte, error) {
+	if state == nil {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
+
+// scanner is implemented by sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEntry(row scanner) (Entry, error) {
+	e := Entry{}
+	var before, after sql.NullString
+	err := row.Scan(&e.ID, &e.ActorId, &e.EntityType, &e.EntityId, &e.Action, &before, &after, &e.CreatedAt)
+	if before.Valid {
+		e.Before = json.RawMessage(before.String)
+	}
+	if after.Valid {
+		e.After = json.RawMessage(after.String)
+	}
+	return e, err
+}
+
+// GetAuditLog lists the entries matching the filter, the newest first
+func GetAuditLog(filter AuditLogFilter, limit int, offset int) (AuditLogResponse, error) {
+	response := AuditLogResponse{Entries: []Entry{}}
+	filters := []string{"TRUE"}
+	args := []any{}
+
+	addFilter := func(condition string, value any) {
+		args = append(args, value)
+		filters = append(filters, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.EntityType != "" {
+		addFilter("entity_type = $%v", filter.EntityType)
+	}
+	if filter.EntityId != "" {
+		addFilter("entity_id = $%v", filter.EntityId)
+	}
+	if !filter.From.IsZero() {
+		addFilter("created_at >= $%v", filter.From)
+	}
+	if !filter.To.IsZero() {
+		// the whole day of To is included
+		addFilter("created_at < $%v", filter.To.AddDate(0, 0, 1))
+	}
+
+	searchString := "WHERE " + strings.Join(filters, " AND ")
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return response, fmt.Errorf(errors_handler.DB002)
+	}
+
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM audit_log %v;", searchString)
+	row := tx.QueryRow(countQuery, args...)
+	err = row.Scan(&response.Count)
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.DB004)
+	}
+
+	recordsQuery := fmt.Sprintf("SELECT * FROM audit_log %v ORDER BY created_at DESC LIMIT $%v OFFSET $%v;", searchString, len(args)+1, len(args)+2)
+	rows, err := tx.Query(recordsQuery, append(args, limit, offset)...)
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.DB005)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			tx.Rollback()
+			return response, fmt.Errorf(errors_handler.DB005)
+		}
+		response.Entries = append(response.Entries, e)
+	}
+
+	response.Limit = limit
+	response.Offset = offset
+
+	err = tx.Commit()
+	if err != nil {
+		return response, fmt.Errorf(errors_handler.DB003)
+	}
+	return response, nil
+}
+
+func DeleteAllAuditLog() {
+	database.DB.Exec("DELETE FROM audit_log;")
+}
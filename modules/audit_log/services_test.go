@@ -0,0 +1,105 @@
+package audit_log
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/database"
+	"github.com/stretchr/testify/assert"
+)
+
+type state struct {
+	Name string `json:"name"`
+}
+
+func TestAuditLogServices(t *testing.T) {
+	envPath := filepath.Clean("../../.env_test")
+	sqlPath := filepath.Clean("../../database/database.sql")
+	database.SetupDB(envPath)
+	database.CreateTables(sqlPath)
+	defer database.CloseConnection()
+
+	// record commits every entry on its own database transaction
+	record := func(actor_id uuid.UUID, entity_type string, entity_id string, action string, before any, after any) (Entry, error) {
+		tx, err := database.DB.Begin()
+		assert.Nil(t, err)
+		entry, err := Record(tx, actor_id, entity_type, entity_id, action, before, after)
+		assert.Nil(t, tx.Commit())
+		return entry, err
+	}
+
+	t.Run("Get an empty audit log initially", func(t *testing.T) {
+		response, err := GetAuditLog(AuditLogFilter{}, 10, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, response.Count)
+		assert.Len(t, response.Entries, 0)
+	})
+
+	t.Run("Record a creation, an update and a deletion", func(t *testing.T) {
+		actor := uuid.New()
+		entityId := uuid.New().String()
+		created, err := record(actor, PersonEntity, entityId, CreateAction, nil, state{"john"})
+		assert.Nil(t, err)
+		assert.Equal(t, actor, created.ActorId)
+		assert.Nil(t, created.Before)
+		assert.JSONEq(t, `{"name": "john"}`, string(created.After))
+
+		_, err = record(actor, PersonEntity, entityId, UpdateAction, state{"john"}, state{"jane"})
+		assert.Nil(t, err)
+		deleted, err := record(actor, PersonEntity, entityId, DeleteAction, state{"jane"}, nil)
+		assert.Nil(t, err)
+		assert.Nil(t, deleted.After)
+		_, err = record(actor, CurrencyEntity, "USD", CreateAction, nil, "USD")
+		assert.Nil(t, err)
+
+		response, err := GetAuditLog(AuditLogFilter{EntityType: PersonEntity, EntityId: entityId}, 10, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, response.Count)
+		assert.Equal(t, DeleteAction, response.Entries[0].Action)
+		assert.Equal(t, UpdateAction, response.Entries[1].Action)
+		assert.JSONEq(t, `{"name": "john"}`, string(response.Entries[1].Before))
+		assert.JSONEq(t, `{"name": "jane"}`, string(response.Entries[1].After))
+		assert.Equal(t, CreateAction, response.Entries[2].Action)
+
+		response, err = GetAuditLog(AuditLogFilter{EntityType: CurrencyEntity}, 10, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, response.Count)
+		assert.Equal(t, "USD", response.Entries[0].EntityId)
+	})
+
+	DeleteAllAuditLog()
+
+	t.Run("Discard the entry when the write is rolled back", func(t *testing.T) {
+		entityId := uuid.New().String()
+		tx, err := database.DB.Begin()
+		assert.Nil(t, err)
+		_, err = Record(tx, SystemActor, PersonEntity, entityId, CreateAction, nil, state{"john"})
+		assert.Nil(t, err)
+		assert.Nil(t, tx.Rollback())
+
+		response, err := GetAuditLog(AuditLogFilter{EntityId: entityId}, 10, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, response.Count)
+	})
+
+	t.Run("Filter the audit log by date", func(t *testing.T) {
+		_, err := record(uuid.UUID{}, BillEntity, uuid.New().String(), CreateAction, nil, state{"bill"})
+		assert.Nil(t, err)
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+		response, err := GetAuditLog(AuditLogFilter{From: today, To: today}, 10, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, response.Count)
+		response, err = GetAuditLog(AuditLogFilter{To: today.AddDate(0, 0, -1)}, 10, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, response.Count)
+		response, err = GetAuditLog(AuditLogFilter{From: today.AddDate(0, 0, 1)}, 10, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, response.Count)
+	})
+
+	DeleteAllAuditLog()
+}
@@ -5,9 +5,13 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
+	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/grabielcruz/transportation_back/modules/currencies"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -66,7 +70,7 @@ func CreatePendingBillHandler(w http.ResponseWriter, r *http.Request, _ httprout
 		common.SendValidationError(w, err.Error())
 		return
 	}
-	newBill, err := CreatePendingBill(billFields)
+	newBill, err := CreatePendingBill(billFields, audit_log.ActorId(r))
 	if err != nil {
 		common.SendServiceError(w, err.Error())
 		return
@@ -85,6 +89,7 @@ func GetOneBillHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Par
 		common.SendServiceError(w, err.Error())
 		return
 	}
+	common.SetETag(w, bill.UpdatedAt)
 	common.SendJson(w, http.StatusOK, bill)
 }
 
@@ -94,6 +99,11 @@ func UpdatePendingBillHandler(w http.ResponseWriter, r *http.Request, ps httprou
 		common.SendInvalidUUIDError(w, err.Error())
 		return
 	}
+	ifMatch, err := common.ParseIfMatch(r)
+	if err != nil {
+		common.SendPreconditionFailedError(w)
+		return
+	}
 	billFields := BillFields{}
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -110,11 +120,12 @@ func UpdatePendingBillHandler(w http.ResponseWriter, r *http.Request, ps httprou
 		common.SendValidationError(w, err.Error())
 		return
 	}
-	updatedBill, err := UpdatePendingBill(bill_id, billFields)
+	updatedBill, err := UpdatePendingBill(bill_id, billFields, ifMatch, audit_log.ActorId(r))
 	if err != nil {
 		common.SendServiceError(w, err.Error())
 		return
 	}
+	common.SetETag(w, updatedBill.UpdatedAt)
 	common.SendJson(w, http.StatusOK, updatedBill)
 }
 
@@ -124,10 +135,223 @@ func DeleteBillHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Par
 		common.SendInvalidUUIDError(w, err.Error())
 		return
 	}
-	deletedId, err := DeleteBill(bill_id)
+	ifMatch, err := common.ParseIfMatch(r)
+	if err != nil {
+		common.SendPreconditionFailedError(w)
+		return
+	}
+	deletedId, err := DeleteBill(bill_id, ifMatch, audit_log.ActorId(r))
 	if err != nil {
 		common.SendServiceError(w, err.Error())
 		return
 	}
 	common.SendJson(w, http.StatusOK, deletedId)
 }
+
+func CreateBillCrossHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	fields := BillCrossFields{}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		common.SendReadError(w)
+		return
+	}
+	err = json.Unmarshal(body, &fields)
+	if err != nil {
+		common.SendUnmarshalError(w)
+		return
+	}
+	err = checkBillCrossFields(fields)
+	if err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	billCross, err := CreateBillCross(fields, audit_log.ActorId(r))
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusCreated, billCross)
+}
+
+func GetBillCrossHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	bill_cross_id, err := uuid.Parse(ps.ByName("bill_cross_id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	billCross, err := GetBillCross(bill_cross_id)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, billCross)
+}
+
+func GetBillSettlementsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	bill_id, err := uuid.Parse(ps.ByName("bill_id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil {
+		common.SendInvalidQueryStringError(w, err.Error())
+		return
+	}
+	offset, err := strconv.Atoi(query.Get("offset"))
+	if err != nil {
+		common.SendInvalidQueryStringError(w, err.Error())
+		return
+	}
+	billResponse, err := GetBillSettlements(bill_id, limit, offset)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, billResponse)
+}
+
+// GetClosedBillsHandler lists closed bills, every filter in the query string is optional
+// but limit and offset
+func GetClosedBillsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	filter := ClosedBillsFilter{}
+	query := r.URL.Query()
+	var err error
+
+	if query.Get("person_id") != "" {
+		filter.PersonId, err = uuid.Parse(query.Get("person_id"))
+		if err != nil {
+			common.SendInvalidUUIDError(w, err.Error())
+			return
+		}
+	}
+	if query.Get("transaction_id") != "" {
+		filter.TransactionId, err = uuid.Parse(query.Get("transaction_id"))
+		if err != nil {
+			common.SendInvalidUUIDError(w, err.Error())
+			return
+		}
+	}
+	if query.Get("from") != "" {
+		filter.From, err = time.Parse(config.DateLayout, query.Get("from"))
+		if err != nil {
+			common.SendInvalidQueryStringError(w, err.Error())
+			return
+		}
+	}
+	if query.Get("to") != "" {
+		filter.To, err = time.Parse(config.DateLayout, query.Get("to"))
+		if err != nil {
+			common.SendInvalidQueryStringError(w, err.Error())
+			return
+		}
+	}
+	filter.Currency = query.Get("currency")
+	filter.Status = query.Get("status")
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil {
+		common.SendInvalidQueryStringError(w, err.Error())
+		return
+	}
+	offset, err := strconv.Atoi(query.Get("offset"))
+	if err != nil {
+		common.SendInvalidQueryStringError(w, err.Error())
+		return
+	}
+	billResponse, err := GetClosedBills(filter, limit, offset)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, billResponse)
+}
+
+// GetAgingReportHandler sends the aging report of pending bills as of the date in the query string,
+// today by default. With format=csv it is sent as a csv file
+func GetAgingReportHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+	now := time.Now()
+	asOf := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var err error
+	if query.Get("as_of") != "" {
+		asOf, err = time.Parse(config.DateLayout, query.Get("as_of"))
+		if err != nil {
+			common.SendInvalidQueryStringError(w, err.Error())
+			return
+		}
+	}
+	format := query.Get("format")
+	if err := checkReportFormat(format); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	report, err := GetAgingReport(asOf)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	if format == "csv" {
+		common.SendCsv(w, http.StatusOK, "aging_"+asOf.Format(config.DateLayout)+".csv", agingReportRecords(report))
+		return
+	}
+	common.SendJson(w, http.StatusOK, report)
+}
+
+// agingReportRecords writes one line for receivables and another for payables of every row
+func agingReportRecords(report AgingReport) [][]string {
+	records := [][]string{{"person_id", "person_name", "currency", "kind", "days_0_30", "days_31_60", "days_61_90", "over_90_days", "total"}}
+	for _, row := range report.Rows {
+		for _, kind := range []string{"receivable", "payable"} {
+			b := row.Receivable
+			if kind == "payable" {
+				b = row.Payable
+			}
+			records = append(records, []string{
+				row.PersonId.String(),
+				row.PersonName,
+				row.Currency,
+				kind,
+				b.Days0To30.String(),
+				b.Days31To60.String(),
+				b.Days61To90.String(),
+				b.Over90Days.String(),
+				b.Total.String(),
+			})
+		}
+	}
+	return records
+}
+
+// GetPersonBalanceHandler sends the position of the person per currency. The query string may have a reporting
+// currency and the date of the rates used to convert to it, today by default
+func GetPersonBalanceHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	person_id, err := uuid.Parse(ps.ByName("id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	reportingCurrency := query.Get("currency")
+	if reportingCurrency != "" {
+		if err := currencies.CheckValidCurrency(reportingCurrency); err != nil {
+			common.SendValidationError(w, err.Error())
+			return
+		}
+	}
+	date := time.Now()
+	if query.Get("date") != "" {
+		date, err = time.Parse(config.DateLayout, query.Get("date"))
+		if err != nil {
+			common.SendInvalidQueryStringError(w, err.Error())
+			return
+		}
+	}
+	balance, err := GetPersonBalance(person_id, reportingCurrency, date)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, balance)
+}
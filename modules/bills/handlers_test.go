@@ -2,12 +2,14 @@ package bills
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/common"
@@ -15,6 +17,7 @@ import (
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
 	"github.com/grabielcruz/transportation_back/modules/config"
 	"github.com/grabielcruz/transportation_back/modules/persons"
+	"github.com/grabielcruz/transportation_back/money"
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
 )
@@ -27,9 +30,9 @@ func TestBillsHandlers(t *testing.T) {
 	defer database.CloseConnection()
 	router := httprouter.New()
 	Routes(router)
-	person1, err := persons.CreatePerson(persons.GeneratePersonFields())
+	person1, err := persons.CreatePerson(persons.GeneratePersonFields(), uuid.UUID{})
 	assert.Nil(t, err)
-	person2, err := persons.CreatePerson(persons.GeneratePersonFields())
+	person2, err := persons.CreatePerson(persons.GeneratePersonFields(), uuid.UUID{})
 	assert.Nil(t, err)
 
 	getBillsUrl := "/pending_bills/%v?to_pay=%v&to_charge=%v&limit=%d&offset=%d"
@@ -201,7 +204,7 @@ func TestBillsHandlers(t *testing.T) {
 
 	t.Run("Error when creating bill with amount zero", func(t *testing.T) {
 		billFields := GenerateBillFields(person1.ID)
-		billFields.Amount = 0
+		billFields.Amount = money.Zero
 		buf := bytes.Buffer{}
 		err := json.NewEncoder(&buf).Encode(billFields)
 		assert.Nil(t, err)
@@ -244,24 +247,24 @@ func TestBillsHandlers(t *testing.T) {
 	t.Run("Create 4 bills, 2 for person1, 2 for person2, negative and positive balance and get them filtered", func(t *testing.T) {
 		// person1
 		billFields := GenerateBillFields(person1.ID)
-		billFields.Amount = 55.55
-		_, err := CreatePendingBill(billFields)
+		billFields.Amount = money.MustParse("55.55")
+		_, err := CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 
 		billFields = GenerateBillFields(person1.ID)
-		billFields.Amount = -55.55
-		_, err = CreatePendingBill(billFields)
+		billFields.Amount = money.MustParse("-55.55")
+		_, err = CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 
 		// person2
 		billFields = GenerateBillFields(person2.ID)
-		billFields.Amount = 77.77
-		_, err = CreatePendingBill(billFields)
+		billFields.Amount = money.MustParse("77.77")
+		_, err = CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 
 		billFields = GenerateBillFields(person2.ID)
-		billFields.Amount = -77.77
-		_, err = CreatePendingBill(billFields)
+		billFields.Amount = money.MustParse("-77.77")
+		_, err = CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 
 		// all of them
@@ -283,13 +286,13 @@ func TestBillsHandlers(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-77.77"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person2.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(77.77), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("77.77"), billResponse.Bills[1].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[2].PersonId)
-		assert.Equal(t, float64(-55.55), billResponse.Bills[2].Amount)
+		assert.Equal(t, money.MustParse("-55.55"), billResponse.Bills[2].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[3].PersonId)
-		assert.Equal(t, float64(55.55), billResponse.Bills[3].Amount)
+		assert.Equal(t, money.MustParse("55.55"), billResponse.Bills[3].Amount)
 
 		// person1
 		// billResponse, err = GetPendingBills(person1.ID, true, true, config.Limit, config.Offset)
@@ -309,9 +312,9 @@ func TestBillsHandlers(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person1.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-55.55), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-55.55"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(55.55), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("55.55"), billResponse.Bills[1].Amount)
 
 		// person2
 		// billResponse, err = GetPendingBills(person2.ID, true, true, config.Limit, config.Offset)
@@ -331,9 +334,9 @@ func TestBillsHandlers(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-77.77"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person2.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(77.77), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("77.77"), billResponse.Bills[1].Amount)
 
 		// to_charge only
 		// billResponse, err = GetPendingBills(uuid.UUID{}, false, true, config.Limit, config.Offset)
@@ -353,9 +356,9 @@ func TestBillsHandlers(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, billResponse.Bills[0].PersonId, person2.ID)
-		assert.Equal(t, float64(77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("77.77"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(55.55), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("55.55"), billResponse.Bills[1].Amount)
 
 		// to_pay only
 		// billResponse, err = GetPendingBills(uuid.UUID{}, true, false, config.Limit, config.Offset)
@@ -375,9 +378,9 @@ func TestBillsHandlers(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-77.77"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(-55.55), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("-55.55"), billResponse.Bills[1].Amount)
 
 		// person1 to_charge
 		// billResponse, err = GetPendingBills(person1.ID, false, true, config.Limit, config.Offset)
@@ -397,7 +400,7 @@ func TestBillsHandlers(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person1.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(55.55), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("55.55"), billResponse.Bills[0].Amount)
 
 		// person1 to_pay
 		// billResponse, err = GetPendingBills(person1.ID, true, false, config.Limit, config.Offset)
@@ -417,7 +420,7 @@ func TestBillsHandlers(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person1.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-55.55), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-55.55"), billResponse.Bills[0].Amount)
 
 		// person2 to_charge
 		// billResponse, err = GetPendingBills(person2.ID, false, true, config.Limit, config.Offset)
@@ -437,7 +440,7 @@ func TestBillsHandlers(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("77.77"), billResponse.Bills[0].Amount)
 
 		// person2 to_pay
 		// billResponse, err = GetPendingBills(person2.ID, true, false, config.Limit, config.Offset)
@@ -458,7 +461,7 @@ func TestBillsHandlers(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-77.77"), billResponse.Bills[0].Amount)
 	})
 
 	EmptyBills()
@@ -482,7 +485,7 @@ func TestBillsHandlers(t *testing.T) {
 	t.Run("Error when creating a bill with balance less than zero", func(t *testing.T) {
 		billFields := GenerateBillFields(person1.ID)
 		buf := bytes.Buffer{}
-		billFields.Amount = -55
+		billFields.Amount = money.FromUnits(-55)
 		err := json.NewEncoder(&buf).Encode(billFields)
 		assert.Nil(t, err)
 
@@ -703,7 +706,7 @@ func TestBillsHandlers(t *testing.T) {
 	})
 
 	t.Run("Error when updating with zero person ID", func(t *testing.T) {
-		bill, err := CreatePendingBill(GenerateBillFields(person1.ID))
+		bill, err := CreatePendingBill(GenerateBillFields(person1.ID), uuid.UUID{})
 		assert.Nil(t, err)
 
 		updateFields := GenerateBillFields(uuid.UUID{})
@@ -727,7 +730,7 @@ func TestBillsHandlers(t *testing.T) {
 	EmptyBills()
 
 	t.Run("Error when updating with empty description", func(t *testing.T) {
-		bill, err := CreatePendingBill(GenerateBillFields(person1.ID))
+		bill, err := CreatePendingBill(GenerateBillFields(person1.ID), uuid.UUID{})
 		assert.Nil(t, err)
 
 		updateFields := GenerateBillFields(person1.ID)
@@ -752,12 +755,12 @@ func TestBillsHandlers(t *testing.T) {
 	EmptyBills()
 
 	t.Run("Error when updating with negative amount", func(t *testing.T) {
-		bill, err := CreatePendingBill(GenerateBillFields(person1.ID))
+		bill, err := CreatePendingBill(GenerateBillFields(person1.ID), uuid.UUID{})
 		assert.Nil(t, err)
 
 		updateFields := GenerateBillFields(person1.ID)
 		buf := bytes.Buffer{}
-		updateFields.Amount = 0
+		updateFields.Amount = money.Zero
 		err = json.NewEncoder(&buf).Encode(updateFields)
 		assert.Nil(t, err)
 		w := httptest.NewRecorder()
@@ -777,7 +780,7 @@ func TestBillsHandlers(t *testing.T) {
 	EmptyBills()
 
 	t.Run("Error when updating with invalid currency", func(t *testing.T) {
-		bill, err := CreatePendingBill(GenerateBillFields(person1.ID))
+		bill, err := CreatePendingBill(GenerateBillFields(person1.ID), uuid.UUID{})
 		assert.Nil(t, err)
 
 		updateFields := GenerateBillFields(person1.ID)
@@ -802,7 +805,7 @@ func TestBillsHandlers(t *testing.T) {
 	EmptyBills()
 
 	t.Run("Create and delete one bill", func(t *testing.T) {
-		bill, err := CreatePendingBill(GenerateBillFields(person1.ID))
+		bill, err := CreatePendingBill(GenerateBillFields(person1.ID), uuid.UUID{})
 		assert.Nil(t, err)
 
 		w := httptest.NewRecorder()
@@ -851,4 +854,210 @@ func TestBillsHandlers(t *testing.T) {
 		assert.Equal(t, "invalid UUID length: 5", errResponse.Error)
 		assert.Equal(t, "UI001", errResponse.Code)
 	})
+	EmptyBills()
+
+	t.Run("Create bill cross and get it", func(t *testing.T) {
+		fields := BillCrossFields{PersonId: person1.ID, Currency: "USD"}
+		for _, amount := range []money.Money{money.FromUnits(70), money.FromUnits(-30)} {
+			billFields := GenerateBillFields(person1.ID)
+			billFields.Currency = "USD"
+			billFields.Amount = amount
+			bill, err := CreatePendingBill(billFields, uuid.UUID{})
+			assert.Nil(t, err)
+			fields.BillIds = append(fields.BillIds, bill.ID)
+		}
+		buf := bytes.Buffer{}
+		err := json.NewEncoder(&buf).Encode(fields)
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/bill_cross", &buf)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		billCross := BillCross{}
+		err = json.Unmarshal(w.Body.Bytes(), &billCross)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(40), billCross.Balance)
+		assert.Len(t, billCross.ClosedBills, 2)
+		assert.Equal(t, money.FromUnits(40), billCross.ResidualBill.Amount)
+
+		w2 := httptest.NewRecorder()
+		req2, err := http.NewRequest(http.MethodGet, "/bill_cross/"+billCross.ID.String(), nil)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusOK, w2.Code)
+
+		gotBillCross := BillCross{}
+		err = json.Unmarshal(w2.Body.Bytes(), &gotBillCross)
+		assert.Nil(t, err)
+		assert.Equal(t, billCross.ID, gotBillCross.ID)
+		assert.Len(t, gotBillCross.ClosedBills, 2)
+		assert.Equal(t, billCross.ResidualBill.ID, gotBillCross.ResidualBill.ID)
+	})
+
+	EmptyBills()
+
+	t.Run("Error when creating bill cross with only one bill", func(t *testing.T) {
+		bill, err := CreatePendingBill(GenerateBillFields(person1.ID), uuid.UUID{})
+		assert.Nil(t, err)
+		fields := BillCrossFields{PersonId: person1.ID, Currency: bill.Currency, BillIds: []uuid.UUID{bill.ID}}
+		buf := bytes.Buffer{}
+		err = json.NewEncoder(&buf).Encode(fields)
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/bill_cross", &buf)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "At least two bills are required", errResponse.Error)
+		assert.Equal(t, "VA001", errResponse.Code)
+	})
+
+	EmptyBills()
+
+	t.Run("Get closed bills filtered by person and status", func(t *testing.T) {
+		fields := BillCrossFields{PersonId: person1.ID, Currency: "USD"}
+		for _, amount := range []money.Money{money.FromUnits(30), money.FromUnits(-10)} {
+			billFields := GenerateBillFields(person1.ID)
+			billFields.Currency = "USD"
+			billFields.Amount = amount
+			bill, err := CreatePendingBill(billFields, uuid.UUID{})
+			assert.Nil(t, err)
+			fields.BillIds = append(fields.BillIds, bill.ID)
+		}
+		_, err := CreateBillCross(fields, uuid.UUID{})
+		assert.Nil(t, err)
+		_, err = createClosedBill(GenerateBillFields(person2.ID))
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/closed_bills?limit=10&offset=0&status=GROUPED&person_id="+person1.ID.String(), nil)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		billResponse := BillResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &billResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, billResponse.Count)
+		for _, bill := range billResponse.Bills {
+			assert.Equal(t, GroupedStatus, bill.Status)
+			assert.Equal(t, person1.ID, bill.PersonId)
+		}
+	})
+
+	EmptyBills()
+
+	t.Run("Error when getting closed bills with bad date", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/closed_bills?limit=10&offset=0&from=15-03-2023", nil)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "QS001", errResponse.Code)
+	})
+
+	t.Run("Get aging report as json and csv", func(t *testing.T) {
+		billFields := GenerateBillFields(person1.ID)
+		billFields.Currency = "USD"
+		billFields.Date = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		billFields.Amount = money.FromUnits(-80)
+		_, err := CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/aging_report?as_of=2023-03-01", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		report := AgingReport{}
+		err = json.Unmarshal(w.Body.Bytes(), &report)
+		assert.Nil(t, err)
+		assert.Len(t, report.Rows, 1)
+		assert.Equal(t, money.FromUnits(80), report.Rows[0].Payable.Days31To60)
+
+		w2 := httptest.NewRecorder()
+		req2, err := http.NewRequest(http.MethodGet, "/aging_report?as_of=2023-03-01&format=csv", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusOK, w2.Code)
+		assert.Equal(t, "text/csv", w2.Header().Get("Content-Type"))
+
+		records, err := csv.NewReader(w2.Body).ReadAll()
+		assert.Nil(t, err)
+		assert.Len(t, records, 3)
+		assert.Equal(t, "days_31_60", records[0][5])
+		assert.Equal(t, []string{person1.ID.String(), person1.Name, "USD", "payable", "0.00", "80.00", "0.00", "0.00", "80.00"}, records[2])
+	})
+
+	EmptyBills()
+
+	t.Run("Error when getting aging report with bad format", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/aging_report?format=xml", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "Format should be json or csv", errResponse.Error)
+		assert.Equal(t, "VA001", errResponse.Code)
+	})
+
+	t.Run("Get balance of a person", func(t *testing.T) {
+		billFields := GenerateBillFields(person2.ID)
+		billFields.Currency = "USD"
+		billFields.Amount = money.FromUnits(-45)
+		_, err := CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/persons/"+person2.ID.String()+"/balance?currency=USD", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		balance := PersonBalance{}
+		err = json.Unmarshal(w.Body.Bytes(), &balance)
+		assert.Nil(t, err)
+		assert.Equal(t, person2.ID, balance.PersonId)
+		assert.Len(t, balance.Currencies, 1)
+		assert.Equal(t, money.FromUnits(45), balance.Currencies[0].ToPay)
+		assert.Equal(t, 1, balance.Currencies[0].OpenBills)
+		assert.Equal(t, money.FromUnits(-45), *balance.ReportingTotal)
+	})
+
+	EmptyBills()
+
+	t.Run("Error when getting balance of a person with bad currency", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/persons/"+person2.ID.String()+"/balance?currency=usd", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "VA001", errResponse.Code)
+	})
 }
@@ -5,6 +5,15 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/money"
+)
+
+// values of the bill_status type in database
+const (
+	PendingStatus  = "PENDING"
+	SolvedStatus   = "SOLVED"
+	RevertedStatus = "REVERTED"
+	GroupedStatus  = "GROUPED"
 )
 
 type Bill struct {
@@ -16,6 +25,8 @@ type Bill struct {
 	TransactionId       uuid.UUID `json:"transaction_id"`
 	BillCrossId         uuid.UUID `json:"bill_cross_id"`
 	RevertTransactionId uuid.UUID `json:"revert_transaction_id"`
+	// pending bill a closed bill was paid from, it keeps the same id when closed completely
+	OriginBillId uuid.UUID `json:"origin_bill_id"`
 	// Only after closed
 	PostNotes string `json:"post_notes"`
 	// timestamps
@@ -23,11 +34,11 @@ type Bill struct {
 }
 
 type BillFields struct {
-	PersonId    uuid.UUID `json:"person_id"`
-	Date        time.Time `json:"date"`
-	Description string    `json:"description"`
-	Currency    string    `json:"currency"`
-	Amount      float64   `json:"amount"`
+	PersonId    uuid.UUID   `json:"person_id"`
+	Date        time.Time   `json:"date"`
+	Description string      `json:"description"`
+	Currency    string      `json:"currency"`
+	Amount      money.Money `json:"amount"`
 	// either generated by one of these or none of these
 	ParentTransactionId uuid.UUID `json:"parent_transaction_id"`
 	ParentBillCrossId   uuid.UUID `json:"parent_bill_cross_id"`
@@ -38,3 +49,88 @@ type BillResponse struct {
 	FilterPersonId uuid.UUID `json:"filter_person_id"`
 	common.Pagination
 }
+
+// ClosedBillsFilter holds the optional filters of closed bills, zero values are ignored.
+// From and To limit the date of the bill, both included
+type ClosedBillsFilter struct {
+	PersonId      uuid.UUID
+	Currency      string
+	Status        string
+	From          time.Time
+	To            time.Time
+	TransactionId uuid.UUID
+}
+
+// BillCross groups pending bills of one person in one currency, closing them by netting
+// what is charged against what is paid
+type BillCross struct {
+	ID       uuid.UUID `json:"id"`
+	PersonId uuid.UUID `json:"person_id"`
+	Currency string    `json:"currency"`
+	// sum of the amounts of the grouped bills
+	Balance     money.Money `json:"balance"`
+	ClosedBills []Bill      `json:"closed_bills"`
+	// pending bill generated when balance is not zero, otherwise it has zero uuid
+	ResidualBill Bill      `json:"residual_bill"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type BillCrossFields struct {
+	PersonId uuid.UUID   `json:"person_id"`
+	Currency string      `json:"currency"`
+	BillIds  []uuid.UUID `json:"bill_ids"`
+}
+
+// AgingBuckets splits amounts by how many days old the bills are
+type AgingBuckets struct {
+	Days0To30  money.Money `json:"days_0_30"`
+	Days31To60 money.Money `json:"days_31_60"`
+	Days61To90 money.Money `json:"days_61_90"`
+	Over90Days money.Money `json:"over_90_days"`
+	Total      money.Money `json:"total"`
+}
+
+// AgingRow holds the pending bills of one person in one currency.
+// Payable amounts are shown as positive numbers
+type AgingRow struct {
+	PersonId   uuid.UUID    `json:"person_id"`
+	PersonName string       `json:"person_name"`
+	Currency   string       `json:"currency"`
+	Receivable AgingBuckets `json:"receivable"`
+	Payable    AgingBuckets `json:"payable"`
+}
+
+type AgingTotal struct {
+	Currency   string       `json:"currency"`
+	Receivable AgingBuckets `json:"receivable"`
+	Payable    AgingBuckets `json:"payable"`
+}
+
+// AgingReport ages the pending bills as of a date, rows are sorted by person name and currency
+type AgingReport struct {
+	AsOf   time.Time    `json:"as_of"`
+	Rows   []AgingRow   `json:"rows"`
+	Totals []AgingTotal `json:"totals"`
+}
+
+// CurrencyPosition sums the pending bills of a person in one currency.
+// ToPay is shown as a positive number, Net = ToCharge - ToPay
+type CurrencyPosition struct {
+	Currency       string      `json:"currency"`
+	ToCharge       money.Money `json:"to_charge"`
+	ToPay          money.Money `json:"to_pay"`
+	Net            money.Money `json:"net"`
+	OpenBills      int         `json:"open_bills"`
+	OldestOpenDate time.Time   `json:"oldest_open_date"`
+}
+
+type PersonBalance struct {
+	PersonId   uuid.UUID          `json:"person_id"`
+	PersonName string             `json:"person_name"`
+	Currencies []CurrencyPosition `json:"currencies"`
+	// only when a reporting currency is requested, the net of every currency converted with the rates
+	// effective on ReportingDate
+	ReportingCurrency string       `json:"reporting_currency,omitempty"`
+	ReportingDate     *time.Time   `json:"reporting_date,omitempty"`
+	ReportingTotal    *money.Money `json:"reporting_total,omitempty"`
+}
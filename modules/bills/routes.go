@@ -1,11 +1,21 @@
 package bills
 
-import "github.com/julienschmidt/httprouter"
+import (
+	"github.com/grabielcruz/transportation_back/modules/idempotency"
+	"github.com/julienschmidt/httprouter"
+)
 
 func Routes(router *httprouter.Router) {
 	router.GET("/pending_bills/:person_id", GetPendingBillsHandler)
-	router.POST("/pending_bills", CreatePendingBillHandler)
+	router.POST("/pending_bills", idempotency.Handle(CreatePendingBillHandler))
 	router.GET("/bills/:bill_id", GetOneBillHandler)
+	router.GET("/bill_settlements/:bill_id", GetBillSettlementsHandler)
+	router.GET("/closed_bills", GetClosedBillsHandler)
+	router.GET("/aging_report", GetAgingReportHandler)
+	// served here because persons can not depend on bills
+	router.GET("/persons/:id/balance", GetPersonBalanceHandler)
 	router.PATCH("/pending_bills/:bill_id", UpdatePendingBillHandler)
 	router.DELETE("/pending_bills/:bill_id", DeleteBillHandler)
+	router.POST("/bill_cross", idempotency.Handle(CreateBillCrossHandler))
+	router.GET("/bill_cross/:bill_cross_id", GetBillCrossHandler)
 }
@@ -1,14 +1,22 @@
 package bills
 
 import (
+	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/common"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
+	"github.com/grabielcruz/transportation_back/modules/closed_periods"
+	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/grabielcruz/transportation_back/modules/currencies"
 	"github.com/grabielcruz/transportation_back/modules/persons"
+	"github.com/grabielcruz/transportation_back/money"
 )
 
 // GetPendingBills returns the pending bills paginated, filtered by person, wether it is to be paid, it is to be charged
@@ -91,22 +99,37 @@ func GetPendingBills(person_id uuid.UUID, to_pay bool, to_charge bool, limit int
 	return billResponse, nil
 }
 
-func CreatePendingBill(fields BillFields) (Bill, error) {
+func CreatePendingBill(fields BillFields, actor_id uuid.UUID) (Bill, error) {
 	bill := Bill{}
-	if fields.Amount == float64(0) {
+	if fields.Amount == money.Zero {
 		return bill, fmt.Errorf(errors_handler.BL002)
 	}
-	row := database.DB.QueryRow("INSERT INTO pending_bills (person_id, date, description, currency, amount, parent_transaction_id, parent_bill_cross_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *;", fields.PersonId, fields.Date, fields.Description, fields.Currency, fields.Amount, uuid.UUID{}, uuid.UUID{})
-	err := row.Scan(&bill.ID, &bill.PersonId, &bill.Date, &bill.Description, &bill.Status, &bill.Currency, &bill.Amount, &bill.ParentTransactionId, &bill.ParentBillCrossId, &bill.CreatedAt, &bill.UpdatedAt)
+	tx, err := database.DB.Begin()
 	if err != nil {
-		return bill, errors_handler.MapDBErrors(err)
+		tx.Rollback()
+		return bill, fmt.Errorf(errors_handler.DB002)
 	}
-
-	bill.PersonName, err = persons.GetPersonsName(bill.PersonId)
+	if err := closed_periods.CheckOpenPeriod(tx, uuid.UUID{}, fields.Date); err != nil {
+		tx.Rollback()
+		return bill, err
+	}
+	// bills created by hand have no parent
+	fields.ParentTransactionId = uuid.UUID{}
+	fields.ParentBillCrossId = uuid.UUID{}
+	bill, err = InsertPendingBill(tx, fields)
 	if err != nil {
-		errors_handler.HandleError(err)
+		tx.Rollback()
+		return bill, err
+	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.BillEntity, bill.ID.String(), audit_log.CreateAction, nil, bill)
+	if err != nil {
+		tx.Rollback()
+		return bill, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return bill, fmt.Errorf(errors_handler.DB003)
 	}
-
 	return bill, nil
 }
 
@@ -121,7 +144,7 @@ func GetOneBill(bill_id uuid.UUID) (Bill, error) {
 	// not found in pending_bills, look for it on closed bills
 	if err != nil {
 		row = database.DB.QueryRow("SELECT * FROM closed_bills WHERE id = $1;", bill_id)
-		err = row.Scan(&b.ID, &b.PersonId, &b.Date, &b.Description, &b.Status, &b.Currency, &b.Amount, &b.ParentTransactionId, &b.ParentBillCrossId, &b.TransactionId, &b.BillCrossId, &b.RevertTransactionId, &b.PostNotes, &b.CreatedAt, &b.UpdatedAt)
+		err = row.Scan(&b.ID, &b.PersonId, &b.Date, &b.Description, &b.Status, &b.Currency, &b.Amount, &b.ParentTransactionId, &b.ParentBillCrossId, &b.TransactionId, &b.BillCrossId, &b.RevertTransactionId, &b.OriginBillId, &b.PostNotes, &b.CreatedAt, &b.UpdatedAt)
 		// bill not found anywhere
 		if err != nil {
 			return b, fmt.Errorf(errors_handler.DB001)
@@ -134,47 +157,103 @@ func GetOneBill(bill_id uuid.UUID) (Bill, error) {
 	return b, nil
 }
 
-func UpdatePendingBill(bill_id uuid.UUID, fields BillFields) (Bill, error) {
+// UpdatePendingBill overwrites the pending bill, when if_match is not zero it fails with PC001 unless
+// the bill was last updated at if_match
+func UpdatePendingBill(bill_id uuid.UUID, fields BillFields, if_match time.Time, actor_id uuid.UUID) (Bill, error) {
 	b := Bill{}
 	if fields.PersonId == (uuid.UUID{}) {
 		return b, fmt.Errorf(errors_handler.PE002)
 	}
-	if bill_id == (uuid.UUID{}) {
-		return b, fmt.Errorf(errors_handler.DB001)
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return b, fmt.Errorf(errors_handler.DB002)
 	}
-	row := database.DB.QueryRow("UPDATE pending_bills SET person_id = $1, date = $2, description = $3, currency = $4, amount = $5 WHERE id = $6 RETURNING *;", fields.PersonId, fields.Date, fields.Description, fields.Currency, fields.Amount, bill_id)
-	err := row.Scan(&b.ID, &b.PersonId, &b.Date, &b.Description, &b.Status, &b.Currency, &b.Amount, &b.ParentTransactionId, &b.ParentBillCrossId, &b.CreatedAt, &b.UpdatedAt)
+	before, err := GetPendingBillForUpdate(tx, bill_id)
 	if err != nil {
+		tx.Rollback()
+		return b, err
+	}
+	// the bill is moved out of its date and into the new one
+	for _, d := range []time.Time{before.Date, fields.Date} {
+		if err := closed_periods.CheckOpenPeriod(tx, uuid.UUID{}, d); err != nil {
+			tx.Rollback()
+			return b, err
+		}
+	}
+	row := tx.QueryRow("UPDATE pending_bills SET person_id = $1, date = $2, description = $3, currency = $4, amount = $5, updated_at = $6 WHERE id = $7 AND ($8::timestamptz IS NULL OR updated_at = $8) RETURNING *;", fields.PersonId, fields.Date, fields.Description, fields.Currency, fields.Amount, time.Now(), bill_id, sql.NullTime{Time: if_match, Valid: !if_match.IsZero()})
+	err = row.Scan(&b.ID, &b.PersonId, &b.Date, &b.Description, &b.Status, &b.Currency, &b.Amount, &b.ParentTransactionId, &b.ParentBillCrossId, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			// the bill is locked, so only the updated_at guard can drop the row
+			return b, fmt.Errorf(errors_handler.PC001)
+		}
 		return b, errors_handler.MapDBErrors(err)
 	}
 	b.PersonName, err = persons.GetPersonsName(b.PersonId)
 	if err != nil {
 		errors_handler.HandleError(err)
 	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.BillEntity, bill_id.String(), audit_log.UpdateAction, before, b)
+	if err != nil {
+		tx.Rollback()
+		return b, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return b, fmt.Errorf(errors_handler.DB003)
+	}
 	return b, nil
 }
 
-func DeleteBill(bill_id uuid.UUID) (common.ID, error) {
+// DeleteBill removes the pending bill, when if_match is not zero it fails with PC001 unless
+// the bill was last updated at if_match
+func DeleteBill(bill_id uuid.UUID, if_match time.Time, actor_id uuid.UUID) (common.ID, error) {
 	id := common.ID{}
-	if bill_id == (uuid.UUID{}) {
-		return id, fmt.Errorf(errors_handler.DB001)
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return id, fmt.Errorf(errors_handler.DB002)
 	}
-	row := database.DB.QueryRow("DELETE FROM pending_bills WHERE id = $1 RETURNING id;", bill_id)
-	err := row.Scan(&id.ID)
+	before, err := GetPendingBillForUpdate(tx, bill_id)
 	if err != nil {
+		tx.Rollback()
+		return id, err
+	}
+	if err := closed_periods.CheckOpenPeriod(tx, uuid.UUID{}, before.Date); err != nil {
+		tx.Rollback()
+		return id, err
+	}
+	row := tx.QueryRow("DELETE FROM pending_bills WHERE id = $1 AND ($2::timestamptz IS NULL OR updated_at = $2) RETURNING id;", bill_id, sql.NullTime{Time: if_match, Valid: !if_match.IsZero()})
+	err = row.Scan(&id.ID)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return id, fmt.Errorf(errors_handler.PC001)
+		}
 		return id, errors_handler.MapDBErrors(err)
 	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.BillEntity, bill_id.String(), audit_log.DeleteAction, before, nil)
+	if err != nil {
+		tx.Rollback()
+		return id, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return id, fmt.Errorf(errors_handler.DB003)
+	}
 	return id, nil
 }
 
 func createClosedBill(fields BillFields) (Bill, error) {
 	bill := Bill{}
-	if fields.Amount == float64(0) {
+	if fields.Amount == money.Zero {
 		return bill, fmt.Errorf(errors_handler.BL002)
 	}
 	randomUUID, _ := uuid.NewRandom()
 	row := database.DB.QueryRow("INSERT INTO closed_bills (id, person_id, date, description, currency, amount, parent_transaction_id, parent_bill_cross_id, transaction_id, bill_cross_id, post_notes) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING *;", randomUUID, fields.PersonId, fields.Date, fields.Description, fields.Currency, fields.Amount, uuid.UUID{}, uuid.UUID{}, uuid.UUID{}, uuid.UUID{}, "")
-	err := row.Scan(&bill.ID, &bill.PersonId, &bill.Date, &bill.Description, &bill.Status, &bill.Currency, &bill.Amount, &bill.ParentTransactionId, &bill.ParentBillCrossId, &bill.TransactionId, &bill.BillCrossId, &bill.RevertTransactionId, &bill.PostNotes, &bill.CreatedAt, &bill.UpdatedAt)
+	err := row.Scan(&bill.ID, &bill.PersonId, &bill.Date, &bill.Description, &bill.Status, &bill.Currency, &bill.Amount, &bill.ParentTransactionId, &bill.ParentBillCrossId, &bill.TransactionId, &bill.BillCrossId, &bill.RevertTransactionId, &bill.OriginBillId, &bill.PostNotes, &bill.CreatedAt, &bill.UpdatedAt)
 	if err != nil {
 		return bill, errors_handler.MapDBErrors(err)
 	}
@@ -187,7 +266,520 @@ func createClosedBill(fields BillFields) (Bill, error) {
 	return bill, nil
 }
 
+// CreateBillCross closes the pending bills of one person in one currency as grouped under a new bill cross.
+// When the sum of their amounts is not zero, a residual pending bill is created with the bill cross as parent.
+// It fails when any of the grouped bills, or the residual one, is dated in a closed period
+func CreateBillCross(fields BillCrossFields, actor_id uuid.UUID) (BillCross, error) {
+	bc := BillCross{}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return bc, fmt.Errorf(errors_handler.DB002)
+	}
+
+	pendingBills := []Bill{}
+	balance := money.Zero
+	for _, bill_id := range fields.BillIds {
+		b, err := GetPendingBillForUpdate(tx, bill_id)
+		if err != nil {
+			tx.Rollback()
+			return bc, err
+		}
+		if b.PersonId != fields.PersonId {
+			tx.Rollback()
+			return bc, fmt.Errorf(errors_handler.BL007)
+		}
+		if b.Currency != fields.Currency {
+			tx.Rollback()
+			return bc, fmt.Errorf(errors_handler.BL008)
+		}
+		// the bill is closed keeping its date
+		if err := closed_periods.CheckOpenPeriod(tx, uuid.UUID{}, b.Date); err != nil {
+			tx.Rollback()
+			return bc, err
+		}
+		balance += b.Amount
+		pendingBills = append(pendingBills, b)
+	}
+
+	row := tx.QueryRow("INSERT INTO bill_cross (person_id, currency, balance) VALUES ($1, $2, $3) RETURNING id, person_id, currency, balance, created_at;", fields.PersonId, fields.Currency, balance)
+	err = row.Scan(&bc.ID, &bc.PersonId, &bc.Currency, &bc.Balance, &bc.CreatedAt)
+	if err != nil {
+		tx.Rollback()
+		return bc, errors_handler.MapDBErrors(err)
+	}
+
+	for _, b := range pendingBills {
+		err = RemovePendingBill(tx, b.ID)
+		if err != nil {
+			tx.Rollback()
+			return bc, err
+		}
+		closedBill, err := InsertClosedBill(tx, b.ID, b.BillFields, GroupedStatus, uuid.UUID{}, bc.ID, b.ID)
+		if err != nil {
+			tx.Rollback()
+			return bc, err
+		}
+		bc.ClosedBills = append(bc.ClosedBills, closedBill)
+	}
+
+	if balance != 0 {
+		residualFields := BillFields{
+			PersonId:            fields.PersonId,
+			Date:                time.Now(),
+			Description:         fmt.Sprintf("Residual of bill cross %v", bc.ID),
+			Currency:            fields.Currency,
+			Amount:              balance,
+			ParentTransactionId: uuid.UUID{},
+			ParentBillCrossId:   bc.ID,
+		}
+		if err := closed_periods.CheckOpenPeriod(tx, uuid.UUID{}, residualFields.Date); err != nil {
+			tx.Rollback()
+			return bc, err
+		}
+		bc.ResidualBill, err = InsertPendingBill(tx, residualFields)
+		if err != nil {
+			tx.Rollback()
+			return bc, err
+		}
+	}
+
+	_, err = audit_log.Record(tx, actor_id, audit_log.BillCrossEntity, bc.ID.String(), audit_log.CreateAction, nil, bc)
+	if err != nil {
+		tx.Rollback()
+		return bc, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return bc, fmt.Errorf(errors_handler.DB003)
+	}
+	return bc, nil
+}
+
+// GetBillCross returns a bill cross with the bills it grouped and its residual bill, if any
+func GetBillCross(bill_cross_id uuid.UUID) (BillCross, error) {
+	bc := BillCross{}
+	if bill_cross_id == (uuid.UUID{}) {
+		return bc, fmt.Errorf(errors_handler.DB001)
+	}
+	row := database.DB.QueryRow("SELECT id, person_id, currency, balance, created_at FROM bill_cross WHERE id = $1;", bill_cross_id)
+	err := row.Scan(&bc.ID, &bc.PersonId, &bc.Currency, &bc.Balance, &bc.CreatedAt)
+	if err != nil {
+		return bc, errors_handler.MapDBErrors(err)
+	}
+
+	rows, err := database.DB.Query("SELECT * FROM closed_bills WHERE bill_cross_id = $1 ORDER BY created_at;", bc.ID)
+	if err != nil {
+		return bc, fmt.Errorf(errors_handler.DB005)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		b := Bill{}
+		err = rows.Scan(&b.ID, &b.PersonId, &b.Date, &b.Description, &b.Status, &b.Currency, &b.Amount, &b.ParentTransactionId, &b.ParentBillCrossId, &b.TransactionId, &b.BillCrossId, &b.RevertTransactionId, &b.OriginBillId, &b.PostNotes, &b.CreatedAt, &b.UpdatedAt)
+		if err != nil {
+			return bc, fmt.Errorf(errors_handler.DB005)
+		}
+		b.PersonName, err = persons.GetPersonsName(b.PersonId)
+		if err != nil {
+			errors_handler.HandleError(err)
+		}
+		bc.ClosedBills = append(bc.ClosedBills, b)
+	}
+
+	// residual bill could be already closed
+	residual_id := uuid.UUID{}
+	row = database.DB.QueryRow("SELECT id FROM pending_bills WHERE parent_bill_cross_id = $1 UNION SELECT id FROM closed_bills WHERE parent_bill_cross_id = $1 LIMIT 1;", bc.ID)
+	err = row.Scan(&residual_id)
+	if err == nil {
+		bc.ResidualBill, err = GetOneBill(residual_id)
+		if err != nil {
+			return bc, err
+		}
+	}
+	return bc, nil
+}
+
 func EmptyBills() {
 	database.DB.QueryRow("DELETE FROM pending_bills WHERE id <> $1;", uuid.UUID{})
 	database.DB.QueryRow("DELETE FROM closed_bills WHERE id <> $1;", uuid.UUID{})
+	database.DB.Exec("DELETE FROM bill_cross WHERE id <> $1;", uuid.UUID{})
+}
+
+// GetBillSettlements returns the closed bills that came from the pending bill with bill_id, paginated.
+// Those are the partial payments of the bill and its final closing, if it was already closed
+func GetBillSettlements(bill_id uuid.UUID, limit int, offset int) (BillResponse, error) {
+	billResponse := BillResponse{}
+	if bill_id == (uuid.UUID{}) {
+		return billResponse, fmt.Errorf(errors_handler.DB001)
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return billResponse, fmt.Errorf(errors_handler.DB002)
+	}
+
+	row := tx.QueryRow("SELECT COUNT(*) FROM closed_bills WHERE origin_bill_id = $1;", bill_id)
+	err = row.Scan(&billResponse.Count)
+	if err != nil {
+		tx.Rollback()
+		return billResponse, fmt.Errorf(errors_handler.DB004)
+	}
+
+	rows, err := tx.Query("SELECT * FROM closed_bills WHERE origin_bill_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3;", bill_id, limit, offset)
+	if err != nil {
+		tx.Rollback()
+		return billResponse, fmt.Errorf(errors_handler.DB005)
+	}
+
+	for rows.Next() {
+		b := Bill{}
+		err = rows.Scan(&b.ID, &b.PersonId, &b.Date, &b.Description, &b.Status, &b.Currency, &b.Amount, &b.ParentTransactionId, &b.ParentBillCrossId, &b.TransactionId, &b.BillCrossId, &b.RevertTransactionId, &b.OriginBillId, &b.PostNotes, &b.CreatedAt, &b.UpdatedAt)
+		if err != nil {
+			tx.Rollback()
+			return billResponse, fmt.Errorf(errors_handler.DB005)
+		}
+		b.PersonName, err = persons.GetPersonsName(b.PersonId)
+		if err != nil {
+			errors_handler.HandleError(err)
+		}
+		billResponse.Bills = append(billResponse.Bills, b)
+	}
+
+	billResponse.Limit = limit
+	billResponse.Offset = offset
+
+	err = tx.Commit()
+	if err != nil {
+		return billResponse, fmt.Errorf(errors_handler.DB003)
+	}
+	return billResponse, nil
+}
+
+// GetClosedBills returns the closed bills paginated, from the newest to the oldest, filtered by the non zero fields of filter
+func GetClosedBills(filter ClosedBillsFilter, limit int, offset int) (BillResponse, error) {
+	billResponse := BillResponse{}
+	// to exclude zero bill
+	filters := []string{"id <> $1"}
+	args := []any{uuid.UUID{}}
+
+	addFilter := func(condition string, value any) {
+		args = append(args, value)
+		filters = append(filters, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.PersonId != (uuid.UUID{}) {
+		addFilter("person_id = $%v", filter.PersonId)
+	}
+	if filter.Currency != "" {
+		addFilter("currency = $%v", filter.Currency)
+	}
+	if filter.Status != "" {
+		if filter.Status != SolvedStatus && filter.Status != RevertedStatus && filter.Status != GroupedStatus {
+			return billResponse, fmt.Errorf(errors_handler.BL009)
+		}
+		addFilter("status = $%v", filter.Status)
+	}
+	if !filter.From.IsZero() {
+		addFilter("date >= $%v", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addFilter("date <= $%v", filter.To)
+	}
+	if filter.TransactionId != (uuid.UUID{}) {
+		addFilter("transaction_id = $%v", filter.TransactionId)
+	}
+
+	searchString := "WHERE " + strings.Join(filters, " AND ")
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return billResponse, fmt.Errorf(errors_handler.DB002)
+	}
+
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM closed_bills %v;", searchString)
+	row := tx.QueryRow(countQuery, args...)
+	err = row.Scan(&billResponse.Count)
+	if err != nil {
+		tx.Rollback()
+		return billResponse, fmt.Errorf(errors_handler.DB004)
+	}
+
+	recordsQuery := fmt.Sprintf("SELECT * FROM closed_bills %v ORDER BY date DESC, created_at DESC LIMIT $%v OFFSET $%v;", searchString, len(args)+1, len(args)+2)
+	rows, err := tx.Query(recordsQuery, append(args, limit, offset)...)
+	if err != nil {
+		tx.Rollback()
+		return billResponse, fmt.Errorf(errors_handler.DB005)
+	}
+
+	for rows.Next() {
+		b := Bill{}
+		err = rows.Scan(&b.ID, &b.PersonId, &b.Date, &b.Description, &b.Status, &b.Currency, &b.Amount, &b.ParentTransactionId, &b.ParentBillCrossId, &b.TransactionId, &b.BillCrossId, &b.RevertTransactionId, &b.OriginBillId, &b.PostNotes, &b.CreatedAt, &b.UpdatedAt)
+		if err != nil {
+			tx.Rollback()
+			return billResponse, fmt.Errorf(errors_handler.DB005)
+		}
+		b.PersonName, err = persons.GetPersonsName(b.PersonId)
+		if err != nil {
+			errors_handler.HandleError(err)
+		}
+		billResponse.Bills = append(billResponse.Bills, b)
+	}
+
+	billResponse.Limit = limit
+	billResponse.Offset = offset
+	billResponse.FilterPersonId = filter.PersonId
+
+	err = tx.Commit()
+	if err != nil {
+		return billResponse, fmt.Errorf(errors_handler.DB003)
+	}
+	return billResponse, nil
+}
+
+// GetAgingReport groups the pending bills by person and currency and splits their amounts in buckets of
+// 0-30, 31-60, 61-90 and more than 90 days, counted from the date of the bill to as_of.
+// Bills dated after as_of fall in the first bucket
+func GetAgingReport(as_of time.Time) (AgingReport, error) {
+	report := AgingReport{AsOf: as_of, Rows: []AgingRow{}, Totals: []AgingTotal{}}
+
+	rows, err := database.DB.Query("SELECT b.person_id, p.name, b.currency, b.date, b.amount FROM pending_bills b JOIN persons p ON p.id = b.person_id WHERE b.id <> $1 ORDER BY p.name, b.person_id, b.currency;", uuid.UUID{})
+	if err != nil {
+		return report, fmt.Errorf(errors_handler.DB005)
+	}
+	defer rows.Close()
+
+	totals := map[string]int{}
+	for rows.Next() {
+		r := AgingRow{}
+		date := time.Time{}
+		amount := money.Zero
+		err = rows.Scan(&r.PersonId, &r.PersonName, &r.Currency, &date, &amount)
+		if err != nil {
+			return report, fmt.Errorf(errors_handler.DB005)
+		}
+		last := len(report.Rows) - 1
+		if last < 0 || report.Rows[last].PersonId != r.PersonId || report.Rows[last].Currency != r.Currency {
+			report.Rows = append(report.Rows, r)
+			last++
+		}
+		if _, ok := totals[r.Currency]; !ok {
+			totals[r.Currency] = len(report.Totals)
+			report.Totals = append(report.Totals, AgingTotal{Currency: r.Currency})
+		}
+		total := &report.Totals[totals[r.Currency]]
+
+		days := int(as_of.Sub(date).Hours() / 24)
+		if amount > 0 {
+			report.Rows[last].Receivable.add(days, amount)
+			total.Receivable.add(days, amount)
+		} else {
+			report.Rows[last].Payable.add(days, -amount)
+			total.Payable.add(days, -amount)
+		}
+	}
+	sort.Slice(report.Totals, func(i, j int) bool {
+		return report.Totals[i].Currency < report.Totals[j].Currency
+	})
+	return report, nil
+}
+
+func (b *AgingBuckets) add(days int, amount money.Money) {
+	switch {
+	case days <= 30:
+		b.Days0To30 += amount
+	case days <= 60:
+		b.Days31To60 += amount
+	case days <= 90:
+		b.Days61To90 += amount
+	default:
+		b.Over90Days += amount
+	}
+	b.Total += amount
+}
+
+// GetPersonBalance sums the pending bills of the person per currency. When reporting_currency is not empty
+// the net of every currency is converted to it with the rates effective on date and added to a total
+func GetPersonBalance(person_id uuid.UUID, reporting_currency string, date time.Time) (PersonBalance, error) {
+	pb := PersonBalance{PersonId: person_id, Currencies: []CurrencyPosition{}}
+	var err error
+	pb.PersonName, err = persons.GetPersonsName(person_id)
+	if err != nil {
+		return pb, err
+	}
+
+	rows, err := database.DB.Query("SELECT currency, COALESCE(SUM(amount) FILTER (WHERE amount > 0), 0), COALESCE(SUM(-amount) FILTER (WHERE amount < 0), 0), COUNT(*), MIN(date) FROM pending_bills WHERE person_id = $1 AND id <> $2 GROUP BY currency ORDER BY currency;", person_id, uuid.UUID{})
+	if err != nil {
+		return pb, fmt.Errorf(errors_handler.DB005)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		cp := CurrencyPosition{}
+		err = rows.Scan(&cp.Currency, &cp.ToCharge, &cp.ToPay, &cp.OpenBills, &cp.OldestOpenDate)
+		if err != nil {
+			return pb, fmt.Errorf(errors_handler.DB005)
+		}
+		cp.Net = cp.ToCharge - cp.ToPay
+		pb.Currencies = append(pb.Currencies, cp)
+	}
+
+	if reporting_currency == "" {
+		return pb, nil
+	}
+	total := money.Zero
+	for _, cp := range pb.Currencies {
+		converted, err := currencies.Convert(cp.Net, cp.Currency, reporting_currency, date, config.ConversionRounding)
+		if err != nil {
+			return pb, err
+		}
+		total += converted
+	}
+	pb.ReportingCurrency = reporting_currency
+	pb.ReportingDate = &date
+	pb.ReportingTotal = &total
+	return pb, nil
+}
+
+// GetPendingBillForUpdate gets a pending bill locking its row until the database transaction ends
+func GetPendingBillForUpdate(tx *sql.Tx, bill_id uuid.UUID) (Bill, error) {
+	b := Bill{}
+	if bill_id == (uuid.UUID{}) {
+		return b, fmt.Errorf(errors_handler.DB001)
+	}
+	row := tx.QueryRow("SELECT * FROM pending_bills WHERE id = $1 FOR UPDATE;", bill_id)
+	err := row.Scan(&b.ID, &b.PersonId, &b.Date, &b.Description, &b.Status, &b.Currency, &b.Amount, &b.ParentTransactionId, &b.ParentBillCrossId, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return b, errors_handler.MapDBErrors(err)
+	}
+	b.PersonName, err = persons.GetPersonsName(b.PersonId)
+	if err != nil {
+		errors_handler.HandleError(err)
+	}
+	return b, nil
+}
+
+// InsertClosedBill registers a closed bill inside the given database transaction.
+// transaction_id and bill_cross_id are zero uuid when the bill is not closed by them,
+// origin_bill_id is the pending bill the closed one comes from
+func InsertClosedBill(tx *sql.Tx, bill_id uuid.UUID, fields BillFields, status string, transaction_id uuid.UUID, bill_cross_id uuid.UUID, origin_bill_id uuid.UUID) (Bill, error) {
+	bill := Bill{}
+	if fields.Amount == money.Zero {
+		return bill, fmt.Errorf(errors_handler.BL002)
+	}
+	row := tx.QueryRow("INSERT INTO closed_bills (id, person_id, date, description, status, currency, amount, parent_transaction_id, parent_bill_cross_id, transaction_id, bill_cross_id, origin_bill_id, post_notes) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING *;", bill_id, fields.PersonId, fields.Date, fields.Description, status, fields.Currency, fields.Amount, fields.ParentTransactionId, fields.ParentBillCrossId, transaction_id, bill_cross_id, origin_bill_id, "")
+	err := row.Scan(&bill.ID, &bill.PersonId, &bill.Date, &bill.Description, &bill.Status, &bill.Currency, &bill.Amount, &bill.ParentTransactionId, &bill.ParentBillCrossId, &bill.TransactionId, &bill.BillCrossId, &bill.RevertTransactionId, &bill.OriginBillId, &bill.PostNotes, &bill.CreatedAt, &bill.UpdatedAt)
+	if err != nil {
+		return bill, errors_handler.MapDBErrors(err)
+	}
+	bill.PersonName, err = persons.GetPersonsName(bill.PersonId)
+	if err != nil {
+		errors_handler.HandleError(err)
+	}
+	return bill, nil
+}
+
+// InsertPendingBill registers a pending bill inside the given database transaction, keeping its parents
+func InsertPendingBill(tx *sql.Tx, fields BillFields) (Bill, error) {
+	bill := Bill{}
+	if fields.Amount == money.Zero {
+		return bill, fmt.Errorf(errors_handler.BL002)
+	}
+	row := tx.QueryRow("INSERT INTO pending_bills (person_id, date, description, currency, amount, parent_transaction_id, parent_bill_cross_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *;", fields.PersonId, fields.Date, fields.Description, fields.Currency, fields.Amount, fields.ParentTransactionId, fields.ParentBillCrossId)
+	err := row.Scan(&bill.ID, &bill.PersonId, &bill.Date, &bill.Description, &bill.Status, &bill.Currency, &bill.Amount, &bill.ParentTransactionId, &bill.ParentBillCrossId, &bill.CreatedAt, &bill.UpdatedAt)
+	if err != nil {
+		return bill, errors_handler.MapDBErrors(err)
+	}
+	bill.PersonName, err = persons.GetPersonsName(bill.PersonId)
+	if err != nil {
+		errors_handler.HandleError(err)
+	}
+	return bill, nil
+}
+
+// GetClosedBillForUpdate gets a closed bill locking its row until the database transaction ends
+func GetClosedBillForUpdate(tx *sql.Tx, bill_id uuid.UUID) (Bill, error) {
+	b := Bill{}
+	if bill_id == (uuid.UUID{}) {
+		return b, fmt.Errorf(errors_handler.DB001)
+	}
+	row := tx.QueryRow("SELECT * FROM closed_bills WHERE id = $1 FOR UPDATE;", bill_id)
+	err := row.Scan(&b.ID, &b.PersonId, &b.Date, &b.Description, &b.Status, &b.Currency, &b.Amount, &b.ParentTransactionId, &b.ParentBillCrossId, &b.TransactionId, &b.BillCrossId, &b.RevertTransactionId, &b.OriginBillId, &b.PostNotes, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return b, errors_handler.MapDBErrors(err)
+	}
+	b.PersonName, err = persons.GetPersonsName(b.PersonId)
+	if err != nil {
+		errors_handler.HandleError(err)
+	}
+	return b, nil
+}
+
+// SetClosedBillReverted marks a closed bill as reverted by the transaction with revert_transaction_id
+func SetClosedBillReverted(tx *sql.Tx, bill_id uuid.UUID, revert_transaction_id uuid.UUID) (Bill, error) {
+	b := Bill{}
+	row := tx.QueryRow("UPDATE closed_bills SET status = $1, revert_transaction_id = $2, updated_at = $3 WHERE id = $4 RETURNING *;", RevertedStatus, revert_transaction_id, time.Now(), bill_id)
+	err := row.Scan(&b.ID, &b.PersonId, &b.Date, &b.Description, &b.Status, &b.Currency, &b.Amount, &b.ParentTransactionId, &b.ParentBillCrossId, &b.TransactionId, &b.BillCrossId, &b.RevertTransactionId, &b.OriginBillId, &b.PostNotes, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return b, errors_handler.MapDBErrors(err)
+	}
+	b.PersonName, err = persons.GetPersonsName(b.PersonId)
+	if err != nil {
+		errors_handler.HandleError(err)
+	}
+	return b, nil
+}
+
+// RemovePendingBill deletes a pending bill inside the given database transaction,
+// unlinking it first from the transaction that generated it
+func RemovePendingBill(tx *sql.Tx, bill_id uuid.UUID) error {
+	_, err := tx.Exec("UPDATE transactions SET pending_bill_id = $1 WHERE pending_bill_id = $2;", uuid.UUID{}, bill_id)
+	if err != nil {
+		return fmt.Errorf(errors_handler.DB009)
+	}
+	row := tx.QueryRow("DELETE FROM pending_bills WHERE id = $1 RETURNING id;", bill_id)
+	err = row.Scan(&bill_id)
+	if err != nil {
+		return errors_handler.MapDBErrors(err)
+	}
+	return nil
+}
+
+// SetPendingBillFields updates a pending bill inside the given database transaction, its currency and parents
+// are not modified
+func SetPendingBillFields(tx *sql.Tx, bill_id uuid.UUID, fields BillFields) (Bill, error) {
+	b := Bill{}
+	if fields.Amount == money.Zero {
+		return b, fmt.Errorf(errors_handler.BL002)
+	}
+	row := tx.QueryRow("UPDATE pending_bills SET person_id = $1, date = $2, description = $3, amount = $4, updated_at = $5 WHERE id = $6 RETURNING *;", fields.PersonId, fields.Date, fields.Description, fields.Amount, time.Now(), bill_id)
+	err := row.Scan(&b.ID, &b.PersonId, &b.Date, &b.Description, &b.Status, &b.Currency, &b.Amount, &b.ParentTransactionId, &b.ParentBillCrossId, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return b, errors_handler.MapDBErrors(err)
+	}
+	b.PersonName, err = persons.GetPersonsName(b.PersonId)
+	if err != nil {
+		errors_handler.HandleError(err)
+	}
+	return b, nil
+}
+
+// SetPendingBillAmount changes the amount of a pending bill inside the given database transaction
+func SetPendingBillAmount(tx *sql.Tx, bill_id uuid.UUID, amount money.Money) (Bill, error) {
+	b := Bill{}
+	if amount == money.Zero {
+		return b, fmt.Errorf(errors_handler.BL002)
+	}
+	row := tx.QueryRow("UPDATE pending_bills SET amount = $1, updated_at = $2 WHERE id = $3 RETURNING *;", amount, time.Now(), bill_id)
+	err := row.Scan(&b.ID, &b.PersonId, &b.Date, &b.Description, &b.Status, &b.Currency, &b.Amount, &b.ParentTransactionId, &b.ParentBillCrossId, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return b, errors_handler.MapDBErrors(err)
+	}
+	b.PersonName, err = persons.GetPersonsName(b.PersonId)
+	if err != nil {
+		errors_handler.HandleError(err)
+	}
+	return b, nil
 }
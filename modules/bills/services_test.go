@@ -3,12 +3,16 @@ package bills
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/closed_periods"
 	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/grabielcruz/transportation_back/modules/currencies"
 	"github.com/grabielcruz/transportation_back/modules/persons"
+	"github.com/grabielcruz/transportation_back/money"
 	"github.com/grabielcruz/transportation_back/utility"
 	"github.com/stretchr/testify/assert"
 )
@@ -19,9 +23,9 @@ func TestBillServices(t *testing.T) {
 	database.SetupDB(envPath)
 	database.CreateTables(sqlPath)
 	defer database.CloseConnection()
-	person1, err := persons.CreatePerson(persons.GeneratePersonFields())
+	person1, err := persons.CreatePerson(persons.GeneratePersonFields(), uuid.UUID{})
 	assert.Nil(t, err)
-	person2, err := persons.CreatePerson(persons.GeneratePersonFields())
+	person2, err := persons.CreatePerson(persons.GeneratePersonFields(), uuid.UUID{})
 	assert.Nil(t, err)
 
 	t.Run("Get all pending bills response with zero bills", func(t *testing.T) {
@@ -36,7 +40,7 @@ func TestBillServices(t *testing.T) {
 
 	t.Run("Create one pending bill", func(t *testing.T) {
 		billFields := GenerateBillFields(person1.ID)
-		newBill, err := CreatePendingBill(billFields)
+		newBill, err := CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 		assert.Equal(t, billFields.PersonId, newBill.PersonId)
 		assert.Equal(t, person1.Name, newBill.PersonName)
@@ -51,24 +55,24 @@ func TestBillServices(t *testing.T) {
 	t.Run("Create 4 bills, 2 for person1, 2 for person2, negative and positive balance and get them filtered", func(t *testing.T) {
 		// person1
 		billFields := GenerateBillFields(person1.ID)
-		billFields.Amount = 55.55
-		_, err := CreatePendingBill(billFields)
+		billFields.Amount = money.MustParse("55.55")
+		_, err := CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 
 		billFields = GenerateBillFields(person1.ID)
-		billFields.Amount = -55.55
-		_, err = CreatePendingBill(billFields)
+		billFields.Amount = money.MustParse("-55.55")
+		_, err = CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 
 		// person2
 		billFields = GenerateBillFields(person2.ID)
-		billFields.Amount = 77.77
-		_, err = CreatePendingBill(billFields)
+		billFields.Amount = money.MustParse("77.77")
+		_, err = CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 
 		billFields = GenerateBillFields(person2.ID)
-		billFields.Amount = -77.77
-		_, err = CreatePendingBill(billFields)
+		billFields.Amount = money.MustParse("-77.77")
+		_, err = CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 
 		// all of them
@@ -80,13 +84,13 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-77.77"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person2.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(77.77), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("77.77"), billResponse.Bills[1].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[2].PersonId)
-		assert.Equal(t, float64(-55.55), billResponse.Bills[2].Amount)
+		assert.Equal(t, money.MustParse("-55.55"), billResponse.Bills[2].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[3].PersonId)
-		assert.Equal(t, float64(55.55), billResponse.Bills[3].Amount)
+		assert.Equal(t, money.MustParse("55.55"), billResponse.Bills[3].Amount)
 
 		// person1
 		billResponse, err = GetPendingBills(person1.ID, true, true, config.Limit, config.Offset)
@@ -97,9 +101,9 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person1.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-55.55), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-55.55"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(55.55), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("55.55"), billResponse.Bills[1].Amount)
 
 		// person2
 		billResponse, err = GetPendingBills(person2.ID, true, true, config.Limit, config.Offset)
@@ -110,9 +114,9 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-77.77"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person2.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(77.77), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("77.77"), billResponse.Bills[1].Amount)
 
 		// to_charge only
 		billResponse, err = GetPendingBills(uuid.UUID{}, false, true, config.Limit, config.Offset)
@@ -123,9 +127,9 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, billResponse.Bills[0].PersonId, person2.ID)
-		assert.Equal(t, float64(77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("77.77"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(55.55), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("55.55"), billResponse.Bills[1].Amount)
 
 		// to_pay only
 		billResponse, err = GetPendingBills(uuid.UUID{}, true, false, config.Limit, config.Offset)
@@ -136,9 +140,9 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-77.77"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(-55.55), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("-55.55"), billResponse.Bills[1].Amount)
 
 		// person1 to_charge
 		billResponse, err = GetPendingBills(person1.ID, false, true, config.Limit, config.Offset)
@@ -149,7 +153,7 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person1.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(55.55), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("55.55"), billResponse.Bills[0].Amount)
 
 		// person1 to_pay
 		billResponse, err = GetPendingBills(person1.ID, true, false, config.Limit, config.Offset)
@@ -160,7 +164,7 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person1.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-55.55), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-55.55"), billResponse.Bills[0].Amount)
 
 		// person2 to_charge
 		billResponse, err = GetPendingBills(person2.ID, false, true, config.Limit, config.Offset)
@@ -171,7 +175,7 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("77.77"), billResponse.Bills[0].Amount)
 
 		// person2 to_pay
 		billResponse, err = GetPendingBills(person2.ID, true, false, config.Limit, config.Offset)
@@ -182,7 +186,7 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-77.77"), billResponse.Bills[0].Amount)
 	})
 
 	EmptyBills()
@@ -195,8 +199,8 @@ func TestBillServices(t *testing.T) {
 
 	t.Run("Error when creating bill with balance = 0", func(t *testing.T) {
 		billFields := GenerateBillFields(person1.ID)
-		billFields.Amount = 0
-		_, err := CreatePendingBill(billFields)
+		billFields.Amount = money.Zero
+		_, err := CreatePendingBill(billFields, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.BL002, err.Error())
 	})
@@ -204,7 +208,7 @@ func TestBillServices(t *testing.T) {
 	t.Run("Error when creating bill with unregistered currency", func(t *testing.T) {
 		billFields := GenerateBillFields(person1.ID)
 		billFields.Currency = "EEE"
-		_, err = CreatePendingBill(billFields)
+		_, err = CreatePendingBill(billFields, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.CU005, err.Error())
 	})
@@ -214,7 +218,7 @@ func TestBillServices(t *testing.T) {
 		firstBill := Bill{}
 		for i := 1; i <= 6; i++ {
 			person_id := uuid.UUID{}
-			amount := float64(0)
+			amount := money.Zero
 			if i%2 == 0 {
 				person_id = person2.ID
 				amount = utility.GetRandomNegativeBalance()
@@ -224,7 +228,7 @@ func TestBillServices(t *testing.T) {
 			}
 			fields := GenerateBillFields(person_id)
 			fields.Amount = amount
-			createdBill, err := CreatePendingBill(fields)
+			createdBill, err := CreatePendingBill(fields, uuid.UUID{})
 			assert.Nil(t, err)
 			if i == 1 {
 				firstBill = createdBill
@@ -234,7 +238,7 @@ func TestBillServices(t *testing.T) {
 		// person2 only has positive balances
 		for i := 1; i <= 6; i++ {
 			person_id := uuid.UUID{}
-			amount := float64(0)
+			amount := money.Zero
 			if i%2 == 0 {
 				person_id = person1.ID
 				amount = utility.GetRandomNegativeBalance()
@@ -244,31 +248,31 @@ func TestBillServices(t *testing.T) {
 			}
 			fields := GenerateBillFields(person_id)
 			fields.Amount = amount
-			_, err := CreatePendingBill(fields)
+			_, err := CreatePendingBill(fields, uuid.UUID{})
 			assert.Nil(t, err)
 		}
 
 		// the last four inserts should be the first ones
 		// person1
 		billFields := GenerateBillFields(person1.ID)
-		billFields.Amount = 55.55
-		_, err := CreatePendingBill(billFields)
+		billFields.Amount = money.MustParse("55.55")
+		_, err := CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 
 		billFields = GenerateBillFields(person1.ID)
-		billFields.Amount = -55.55
-		_, err = CreatePendingBill(billFields)
+		billFields.Amount = money.MustParse("-55.55")
+		_, err = CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 
 		// person2
 		billFields = GenerateBillFields(person2.ID)
-		billFields.Amount = 77.77
-		_, err = CreatePendingBill(billFields)
+		billFields.Amount = money.MustParse("77.77")
+		_, err = CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 
 		billFields = GenerateBillFields(person2.ID)
-		billFields.Amount = -77.77
-		_, err = CreatePendingBill(billFields)
+		billFields.Amount = money.MustParse("-77.77")
+		_, err = CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 
 		// all of them
@@ -280,13 +284,13 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-77.77"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person2.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(77.77), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("77.77"), billResponse.Bills[1].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[2].PersonId)
-		assert.Equal(t, float64(-55.55), billResponse.Bills[2].Amount)
+		assert.Equal(t, money.MustParse("-55.55"), billResponse.Bills[2].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[3].PersonId)
-		assert.Equal(t, float64(55.55), billResponse.Bills[3].Amount)
+		assert.Equal(t, money.MustParse("55.55"), billResponse.Bills[3].Amount)
 
 		// second page
 		billResponse, err = GetPendingBills(uuid.UUID{}, true, true, config.Limit, 10)
@@ -311,9 +315,9 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person1.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-55.55), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-55.55"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(55.55), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("55.55"), billResponse.Bills[1].Amount)
 
 		// person2
 		billResponse, err = GetPendingBills(person2.ID, true, true, config.Limit, config.Offset)
@@ -324,9 +328,9 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-77.77"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person2.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(77.77), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("77.77"), billResponse.Bills[1].Amount)
 
 		// to_charge only
 		billResponse, err = GetPendingBills(uuid.UUID{}, false, true, config.Limit, config.Offset)
@@ -337,9 +341,9 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, billResponse.Bills[0].PersonId, person2.ID)
-		assert.Equal(t, float64(77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("77.77"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(55.55), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("55.55"), billResponse.Bills[1].Amount)
 
 		// to_pay only
 		billResponse, err = GetPendingBills(uuid.UUID{}, true, false, config.Limit, config.Offset)
@@ -350,9 +354,9 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-77.77"), billResponse.Bills[0].Amount)
 		assert.Equal(t, person1.ID, billResponse.Bills[1].PersonId)
-		assert.Equal(t, float64(-55.55), billResponse.Bills[1].Amount)
+		assert.Equal(t, money.MustParse("-55.55"), billResponse.Bills[1].Amount)
 
 		// person1 to_charge
 		billResponse, err = GetPendingBills(person1.ID, false, true, config.Limit, config.Offset)
@@ -363,7 +367,7 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person1.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(55.55), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("55.55"), billResponse.Bills[0].Amount)
 
 		// person1 to_pay
 		billResponse, err = GetPendingBills(person1.ID, true, false, config.Limit, config.Offset)
@@ -374,7 +378,7 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person1.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-55.55), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-55.55"), billResponse.Bills[0].Amount)
 
 		// person2 to_charge
 		billResponse, err = GetPendingBills(person2.ID, false, true, config.Limit, config.Offset)
@@ -385,7 +389,7 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("77.77"), billResponse.Bills[0].Amount)
 
 		// person2 to_pay
 		billResponse, err = GetPendingBills(person2.ID, true, false, config.Limit, config.Offset)
@@ -396,14 +400,14 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, billResponse.Offset, config.Offset)
 		assert.Equal(t, billResponse.Limit, config.Limit)
 		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
-		assert.Equal(t, float64(-77.77), billResponse.Bills[0].Amount)
+		assert.Equal(t, money.MustParse("-77.77"), billResponse.Bills[0].Amount)
 	})
 
 	EmptyBills()
 
 	t.Run("Create one bill and get it with single response", func(t *testing.T) {
 		billFields := GenerateBillFields(person1.ID)
-		newBill, err := CreatePendingBill(billFields)
+		newBill, err := CreatePendingBill(billFields, uuid.UUID{})
 		assert.Nil(t, err)
 		bill, err := GetOneBill(newBill.ID)
 		assert.Nil(t, err)
@@ -446,10 +450,10 @@ func TestBillServices(t *testing.T) {
 	})
 
 	t.Run("Create one bill and update it", func(t *testing.T) {
-		bill, err := CreatePendingBill(GenerateBillFields(person1.ID))
+		bill, err := CreatePendingBill(GenerateBillFields(person1.ID), uuid.UUID{})
 		assert.Nil(t, err)
 		updateFields := GenerateBillFields(person1.ID)
-		updatedBill, err := UpdatePendingBill(bill.ID, updateFields)
+		updatedBill, err := UpdatePendingBill(bill.ID, updateFields, time.Time{}, uuid.UUID{})
 		assert.Nil(t, err)
 
 		assert.Equal(t, updatedBill.PersonId, updateFields.PersonId)
@@ -469,6 +473,30 @@ func TestBillServices(t *testing.T) {
 		assert.Equal(t, updatedBill.Currency, bill2.Currency)
 		assert.Equal(t, updatedBill.CreatedAt, bill2.CreatedAt)
 		assert.Equal(t, updatedBill.UpdatedAt, bill2.UpdatedAt)
+		assert.True(t, updatedBill.UpdatedAt.After(bill.UpdatedAt))
+	})
+
+	EmptyBills()
+
+	t.Run("Error when updating or deleting a bill modified since it was read", func(t *testing.T) {
+		bill, err := CreatePendingBill(GenerateBillFields(person1.ID), uuid.UUID{})
+		assert.Nil(t, err)
+		updatedBill, err := UpdatePendingBill(bill.ID, GenerateBillFields(person1.ID), bill.UpdatedAt, uuid.UUID{})
+		assert.Nil(t, err)
+
+		_, err = UpdatePendingBill(bill.ID, GenerateBillFields(person1.ID), bill.UpdatedAt, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.PC001, err.Error())
+		_, err = DeleteBill(bill.ID, bill.UpdatedAt, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.PC001, err.Error())
+
+		id, err := DeleteBill(bill.ID, updatedBill.UpdatedAt, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, bill.ID, id.ID)
+		_, err = DeleteBill(bill.ID, updatedBill.UpdatedAt, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
 	})
 
 	EmptyBills()
@@ -476,24 +504,24 @@ func TestBillServices(t *testing.T) {
 	t.Run("Error when updating unexisting bill", func(t *testing.T) {
 		randomUUID, err := uuid.NewRandom()
 		assert.Nil(t, err)
-		_, err = UpdatePendingBill(randomUUID, GenerateBillFields(person1.ID))
+		_, err = UpdatePendingBill(randomUUID, GenerateBillFields(person1.ID), time.Time{}, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.DB001, err.Error())
 	})
 
 	t.Run("Error when updating with zero person id", func(t *testing.T) {
-		bill, err := CreatePendingBill(GenerateBillFields(person1.ID))
+		bill, err := CreatePendingBill(GenerateBillFields(person1.ID), uuid.UUID{})
 		assert.Nil(t, err)
 		updateFields := GenerateBillFields(uuid.UUID{})
-		_, err = UpdatePendingBill(bill.ID, updateFields)
+		_, err = UpdatePendingBill(bill.ID, updateFields, time.Time{}, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.PE002, err.Error())
 	})
 
 	t.Run("Create and delete one bill", func(t *testing.T) {
-		bill, err := CreatePendingBill(GenerateBillFields(person1.ID))
+		bill, err := CreatePendingBill(GenerateBillFields(person1.ID), uuid.UUID{})
 		assert.Nil(t, err)
-		id, err := DeleteBill(bill.ID)
+		id, err := DeleteBill(bill.ID, time.Time{}, uuid.UUID{})
 		assert.Nil(t, err)
 		assert.Equal(t, id.ID, bill.ID)
 		_, err = GetOneBill(bill.ID)
@@ -502,11 +530,361 @@ func TestBillServices(t *testing.T) {
 	})
 
 	t.Run("Error when requesting to delete unexisting pending bill", func(t *testing.T) {
-		_, err := CreatePendingBill(GenerateBillFields(person1.ID))
+		_, err := CreatePendingBill(GenerateBillFields(person1.ID), uuid.UUID{})
+		assert.Nil(t, err)
+		_, err = DeleteBill(uuid.UUID{}, time.Time{}, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+	})
+
+	EmptyBills()
+
+	t.Run("Create bill cross with residual bill", func(t *testing.T) {
+		amounts := []money.Money{money.FromUnits(100), money.FromUnits(-30), money.FromUnits(-20)}
+		fields := BillCrossFields{PersonId: person1.ID, Currency: "USD"}
+		for _, amount := range amounts {
+			billFields := GenerateBillFields(person1.ID)
+			billFields.Currency = "USD"
+			billFields.Amount = amount
+			bill, err := CreatePendingBill(billFields, uuid.UUID{})
+			assert.Nil(t, err)
+			fields.BillIds = append(fields.BillIds, bill.ID)
+		}
+
+		billCross, err := CreateBillCross(fields, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(50), billCross.Balance)
+		assert.Equal(t, person1.ID, billCross.PersonId)
+		assert.Equal(t, "USD", billCross.Currency)
+		assert.Len(t, billCross.ClosedBills, 3)
+		for i, closedBill := range billCross.ClosedBills {
+			assert.Equal(t, fields.BillIds[i], closedBill.ID)
+			assert.Equal(t, GroupedStatus, closedBill.Status)
+			assert.Equal(t, billCross.ID, closedBill.BillCrossId)
+			assert.Equal(t, amounts[i], closedBill.Amount)
+		}
+		assert.Equal(t, money.FromUnits(50), billCross.ResidualBill.Amount)
+		assert.Equal(t, billCross.ID, billCross.ResidualBill.ParentBillCrossId)
+		assert.Equal(t, PendingStatus, billCross.ResidualBill.Status)
+
+		// only the residual bill remains pending
+		billResponse, err := GetPendingBills(person1.ID, true, true, config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, billResponse.Count)
+		assert.Equal(t, billCross.ResidualBill.ID, billResponse.Bills[0].ID)
+
+		sameBillCross, err := GetBillCross(billCross.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, billCross.ID, sameBillCross.ID)
+		assert.Equal(t, billCross.Balance, sameBillCross.Balance)
+		assert.Len(t, sameBillCross.ClosedBills, 3)
+		assert.Equal(t, billCross.ResidualBill.ID, sameBillCross.ResidualBill.ID)
+	})
+
+	EmptyBills()
+
+	t.Run("Create bill cross without residual bill", func(t *testing.T) {
+		fields := BillCrossFields{PersonId: person1.ID, Currency: "VED"}
+		for _, amount := range []money.Money{money.MustParse("40.25"), money.MustParse("-40.25")} {
+			billFields := GenerateBillFields(person1.ID)
+			billFields.Currency = "VED"
+			billFields.Amount = amount
+			bill, err := CreatePendingBill(billFields, uuid.UUID{})
+			assert.Nil(t, err)
+			fields.BillIds = append(fields.BillIds, bill.ID)
+		}
+
+		billCross, err := CreateBillCross(fields, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.Zero, billCross.Balance)
+		assert.Len(t, billCross.ClosedBills, 2)
+		assert.Equal(t, uuid.UUID{}, billCross.ResidualBill.ID)
+
+		billResponse, err := GetPendingBills(person1.ID, true, true, config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, billResponse.Count)
+	})
+
+	EmptyBills()
+
+	t.Run("Error when creating bill cross with bills of different persons", func(t *testing.T) {
+		fields := BillCrossFields{PersonId: person1.ID, Currency: "USD"}
+		for _, person_id := range []uuid.UUID{person1.ID, person2.ID} {
+			billFields := GenerateBillFields(person_id)
+			billFields.Currency = "USD"
+			bill, err := CreatePendingBill(billFields, uuid.UUID{})
+			assert.Nil(t, err)
+			fields.BillIds = append(fields.BillIds, bill.ID)
+		}
+		_, err := CreateBillCross(fields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.BL007, err.Error())
+
+		// nothing was closed
+		billResponse, err := GetPendingBills(uuid.UUID{}, true, true, config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, billResponse.Count)
+	})
+
+	EmptyBills()
+
+	t.Run("Error when creating bill cross with bills of different currencies", func(t *testing.T) {
+		fields := BillCrossFields{PersonId: person1.ID, Currency: "USD"}
+		for _, currency := range []string{"USD", "VED"} {
+			billFields := GenerateBillFields(person1.ID)
+			billFields.Currency = currency
+			bill, err := CreatePendingBill(billFields, uuid.UUID{})
+			assert.Nil(t, err)
+			fields.BillIds = append(fields.BillIds, bill.ID)
+		}
+		_, err := CreateBillCross(fields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.BL008, err.Error())
+	})
+
+	EmptyBills()
+
+	t.Run("Error when creating bill cross with unexisting bill", func(t *testing.T) {
+		billFields := GenerateBillFields(person1.ID)
+		bill, err := CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+		randomUUID, err := uuid.NewRandom()
+		assert.Nil(t, err)
+		fields := BillCrossFields{PersonId: person1.ID, Currency: bill.Currency, BillIds: []uuid.UUID{bill.ID, randomUUID}}
+		_, err = CreateBillCross(fields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+	})
+
+	EmptyBills()
+
+	t.Run("Get closed bills filtered", func(t *testing.T) {
+		march := time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC)
+		april := time.Date(2023, 4, 15, 0, 0, 0, 0, time.UTC)
+		for _, date := range []time.Time{march, april} {
+			billFields := GenerateBillFields(person1.ID)
+			billFields.Currency = "USD"
+			billFields.Date = date
+			_, err := createClosedBill(billFields)
+			assert.Nil(t, err)
+		}
+		billFields := GenerateBillFields(person2.ID)
+		billFields.Currency = "VED"
+		billFields.Date = march
+		_, err := createClosedBill(billFields)
+		assert.Nil(t, err)
+
+		billResponse, err := GetClosedBills(ClosedBillsFilter{}, config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, billResponse.Count)
+		assert.Len(t, billResponse.Bills, 3)
+
+		billResponse, err = GetClosedBills(ClosedBillsFilter{PersonId: person1.ID}, config.Limit, config.Offset)
 		assert.Nil(t, err)
-		_, err = DeleteBill(uuid.UUID{})
+		assert.Equal(t, 2, billResponse.Count)
+		assert.Equal(t, person1.ID, billResponse.FilterPersonId)
+
+		billResponse, err = GetClosedBills(ClosedBillsFilter{Currency: "VED"}, config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, billResponse.Count)
+		assert.Equal(t, person2.ID, billResponse.Bills[0].PersonId)
+
+		filter := ClosedBillsFilter{From: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2023, 3, 31, 0, 0, 0, 0, time.UTC)}
+		billResponse, err = GetClosedBills(filter, config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, billResponse.Count)
+
+		filter.PersonId = person1.ID
+		filter.Status = SolvedStatus
+		billResponse, err = GetClosedBills(filter, config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, billResponse.Count)
+		assert.Equal(t, march, billResponse.Bills[0].Date.UTC())
+
+		filter.Status = GroupedStatus
+		billResponse, err = GetClosedBills(filter, config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, billResponse.Count)
+		assert.Len(t, billResponse.Bills, 0)
+	})
+
+	EmptyBills()
+
+	t.Run("Error when getting closed bills with a bad status", func(t *testing.T) {
+		_, err := GetClosedBills(ClosedBillsFilter{Status: PendingStatus}, config.Limit, config.Offset)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.BL009, err.Error())
+	})
+
+	EmptyBills()
+
+	t.Run("Get aging report of pending bills", func(t *testing.T) {
+		asOf := time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC)
+		create := func(person_id uuid.UUID, currency string, days int, amount money.Money) {
+			billFields := GenerateBillFields(person_id)
+			billFields.Currency = currency
+			billFields.Date = asOf.AddDate(0, 0, -days)
+			billFields.Amount = amount
+			_, err := CreatePendingBill(billFields, uuid.UUID{})
+			assert.Nil(t, err)
+		}
+		create(person1.ID, "USD", 10, money.FromUnits(100))
+		create(person1.ID, "USD", 30, money.FromUnits(50))
+		create(person1.ID, "USD", 31, money.FromUnits(-20))
+		create(person1.ID, "USD", 75, money.FromUnits(40))
+		create(person1.ID, "USD", 91, money.FromUnits(-30))
+		create(person1.ID, "VED", 5, money.FromUnits(7))
+		create(person2.ID, "USD", 200, money.FromUnits(60))
+
+		report, err := GetAgingReport(asOf)
+		assert.Nil(t, err)
+		assert.Equal(t, asOf, report.AsOf)
+		assert.Len(t, report.Rows, 3)
+
+		for _, row := range report.Rows {
+			if row.PersonId == person1.ID && row.Currency == "USD" {
+				assert.Equal(t, person1.Name, row.PersonName)
+				assert.Equal(t, money.FromUnits(150), row.Receivable.Days0To30)
+				assert.Equal(t, money.Zero, row.Receivable.Days31To60)
+				assert.Equal(t, money.FromUnits(40), row.Receivable.Days61To90)
+				assert.Equal(t, money.FromUnits(190), row.Receivable.Total)
+				assert.Equal(t, money.FromUnits(20), row.Payable.Days31To60)
+				assert.Equal(t, money.FromUnits(30), row.Payable.Over90Days)
+				assert.Equal(t, money.FromUnits(50), row.Payable.Total)
+			}
+			if row.PersonId == person2.ID {
+				assert.Equal(t, money.FromUnits(60), row.Receivable.Over90Days)
+				assert.Equal(t, money.Zero, row.Payable.Total)
+			}
+		}
+
+		assert.Len(t, report.Totals, 2)
+		assert.Equal(t, "USD", report.Totals[0].Currency)
+		assert.Equal(t, money.FromUnits(250), report.Totals[0].Receivable.Total)
+		assert.Equal(t, money.FromUnits(50), report.Totals[0].Payable.Total)
+		assert.Equal(t, "VED", report.Totals[1].Currency)
+		assert.Equal(t, money.FromUnits(7), report.Totals[1].Receivable.Days0To30)
+	})
+
+	EmptyBills()
+
+	t.Run("Get empty aging report", func(t *testing.T) {
+		report, err := GetAgingReport(time.Now())
+		assert.Nil(t, err)
+		assert.Len(t, report.Rows, 0)
+		assert.Len(t, report.Totals, 0)
+	})
+
+	t.Run("Get balance of a person per currency", func(t *testing.T) {
+		oldest := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+		for i, amount := range []money.Money{money.FromUnits(100), money.FromUnits(-30), money.MustParse("20.5")} {
+			billFields := GenerateBillFields(person1.ID)
+			billFields.Currency = "USD"
+			billFields.Date = oldest.AddDate(0, 0, i)
+			billFields.Amount = amount
+			_, err := CreatePendingBill(billFields, uuid.UUID{})
+			assert.Nil(t, err)
+		}
+		billFields := GenerateBillFields(person1.ID)
+		billFields.Currency = "VED"
+		billFields.Amount = money.FromUnits(-500)
+		_, err := CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+		_, err = CreatePendingBill(GenerateBillFields(person2.ID), uuid.UUID{})
+		assert.Nil(t, err)
+
+		balance, err := GetPersonBalance(person1.ID, "", time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, person1.Name, balance.PersonName)
+		assert.Len(t, balance.Currencies, 2)
+		assert.Equal(t, "USD", balance.Currencies[0].Currency)
+		assert.Equal(t, money.MustParse("120.5"), balance.Currencies[0].ToCharge)
+		assert.Equal(t, money.FromUnits(30), balance.Currencies[0].ToPay)
+		assert.Equal(t, money.MustParse("90.5"), balance.Currencies[0].Net)
+		assert.Equal(t, 3, balance.Currencies[0].OpenBills)
+		assert.Equal(t, oldest, balance.Currencies[0].OldestOpenDate.UTC())
+		assert.Equal(t, "VED", balance.Currencies[1].Currency)
+		assert.Equal(t, money.FromUnits(-500), balance.Currencies[1].Net)
+		assert.Nil(t, balance.ReportingTotal)
+
+		// 1 USD = 10 VED
+		rate, err := currencies.CreateExchangeRate(currencies.ExchangeRateFields{FromCurrency: "USD", ToCurrency: "VED", Date: oldest, Rate: money.MustParseRate("10")}, uuid.UUID{})
+		assert.Nil(t, err)
+		balance, err = GetPersonBalance(person1.ID, "USD", time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, "USD", balance.ReportingCurrency)
+		assert.Equal(t, money.MustParse("40.5"), *balance.ReportingTotal)
+
+		// no rate registered before the date
+		_, err = GetPersonBalance(person1.ID, "USD", oldest.AddDate(0, 0, -1))
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CU007, err.Error())
+
+		_, err = currencies.DeleteExchangeRate(rate.ID, uuid.UUID{})
+		assert.Nil(t, err)
+	})
+
+	EmptyBills()
+
+	t.Run("Error when getting balance of unexisting person", func(t *testing.T) {
+		_, err := GetPersonBalance(uuid.UUID{}, "", time.Now())
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+
+		randId, err := uuid.NewRandom()
+		assert.Nil(t, err)
+		_, err = GetPersonBalance(randId, "", time.Now())
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.DB001, err.Error())
 	})
 
+	EmptyBills()
+
+	t.Run("Error when writing bills dated in a closed period", func(t *testing.T) {
+		closedUntil := time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC)
+		fields := GenerateBillFields(person1.ID)
+		fields.Date = closedUntil
+		oldBill, err := CreatePendingBill(fields, uuid.UUID{})
+		assert.Nil(t, err)
+		fields.Date = closedUntil.AddDate(0, 0, 1)
+		newBill, err := CreatePendingBill(fields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		_, err = closed_periods.CreateClosedPeriod(closed_periods.ClosedPeriodFields{ClosedUntil: closedUntil})
+		assert.Nil(t, err)
+
+		fields.Date = closedUntil
+		_, err = CreatePendingBill(fields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+
+		// moving a bill into the closed period or out of it
+		_, err = UpdatePendingBill(newBill.ID, fields, time.Time{}, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+		fields.Date = closedUntil.AddDate(0, 0, 1)
+		_, err = UpdatePendingBill(oldBill.ID, fields, time.Time{}, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+		_, err = UpdatePendingBill(newBill.ID, fields, time.Time{}, uuid.UUID{})
+		assert.Nil(t, err)
+
+		_, err = DeleteBill(oldBill.ID, time.Time{}, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+
+		// grouping closes the bill with its date
+		crossFields := BillCrossFields{PersonId: person1.ID, Currency: oldBill.Currency, BillIds: []uuid.UUID{newBill.ID, oldBill.ID}}
+		_, err = CreateBillCross(crossFields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+		sameBill, err := GetOneBill(newBill.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, PendingStatus, sameBill.Status)
+
+		_, err = DeleteBill(newBill.ID, time.Time{}, uuid.UUID{})
+		assert.Nil(t, err)
+
+		closed_periods.DeleteAllClosedPeriods()
+	})
 }
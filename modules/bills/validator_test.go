@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/money"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,10 +20,40 @@ func TestCheckBillFields(t *testing.T) {
 	fields.Description = "abc"
 	err = checkBillFields(fields)
 	assert.Equal(t, "Amount should be greater than zero", err.Error())
-	fields.Amount = float64(55)
+	fields.Amount = money.FromUnits(55)
 	err = checkBillFields(fields)
 	assert.Equal(t, "Currency code should be 3 upper case letters", err.Error())
 	fields.Currency = "ABC"
 	err = checkBillFields(fields)
 	assert.Nil(t, err)
 }
+
+func TestCheckBillCrossFields(t *testing.T) {
+	fields := BillCrossFields{}
+	err := checkBillCrossFields(fields)
+	assert.Equal(t, "Person id should be not zero uuid", err.Error())
+	randId, err := uuid.NewRandom()
+	assert.Nil(t, err)
+	fields.PersonId = randId
+	err = checkBillCrossFields(fields)
+	assert.Equal(t, "Currency code should be 3 upper case letters", err.Error())
+	fields.Currency = "USD"
+	err = checkBillCrossFields(fields)
+	assert.Equal(t, "At least two bills are required", err.Error())
+	fields.BillIds = []uuid.UUID{randId, randId}
+	err = checkBillCrossFields(fields)
+	assert.Equal(t, "Bill ids should not be repeated", err.Error())
+	otherId, err := uuid.NewRandom()
+	assert.Nil(t, err)
+	fields.BillIds = []uuid.UUID{randId, otherId}
+	err = checkBillCrossFields(fields)
+	assert.Nil(t, err)
+}
+
+func TestCheckReportFormat(t *testing.T) {
+	assert.Nil(t, checkReportFormat(""))
+	assert.Nil(t, checkReportFormat("json"))
+	assert.Nil(t, checkReportFormat("csv"))
+	err := checkReportFormat("xml")
+	assert.Equal(t, "Format should be json or csv", err.Error())
+}
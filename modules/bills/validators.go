@@ -22,3 +22,30 @@ func checkBillFields(fields BillFields) error {
 	}
 	return nil
 }
+
+func checkBillCrossFields(fields BillCrossFields) error {
+	if fields.PersonId == (uuid.UUID{}) {
+		return fmt.Errorf("Person id should be not zero uuid")
+	}
+	if err := currencies.CheckValidCurrency(fields.Currency); err != nil {
+		return err
+	}
+	if len(fields.BillIds) < 2 {
+		return fmt.Errorf("At least two bills are required")
+	}
+	seen := map[uuid.UUID]bool{}
+	for _, id := range fields.BillIds {
+		if seen[id] {
+			return fmt.Errorf("Bill ids should not be repeated")
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+func checkReportFormat(format string) error {
+	if format != "" && format != "json" && format != "csv" {
+		return fmt.Errorf("Format should be json or csv")
+	}
+	return nil
+}
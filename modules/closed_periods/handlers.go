@@ -0,0 +1,57 @@
+package closed_periods
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/common"
+	"github.com/julienschmidt/httprouter"
+)
+
+func GetClosedPeriodsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	periods, err := GetClosedPeriods()
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, periods)
+}
+
+func CreateClosedPeriodHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	fields := ClosedPeriodFields{}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		common.SendReadError(w)
+		return
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		common.SendUnmarshalError(w)
+		return
+	}
+	if err := checkClosedPeriodFields(fields); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	cp, err := CreateClosedPeriod(fields)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusCreated, cp)
+}
+
+func DeleteClosedPeriodHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := uuid.Parse(ps.ByName("id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	cp, err := DeleteClosedPeriod(id)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, cp)
+}
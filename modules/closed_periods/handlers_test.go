@@ -0,0 +1,80 @@
+package closed_periods
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClosedPeriodsHandlers(t *testing.T) {
+	envPath := filepath.Clean("../../.env_test")
+	sqlPath := filepath.Clean("../../database/database.sql")
+	database.SetupDB(envPath)
+	database.CreateTables(sqlPath)
+	defer database.CloseConnection()
+	router := httprouter.New()
+	Routes(router)
+
+	t.Run("Create, list and delete a closed period", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		body := bytes.NewBufferString(`{"closed_until": "2023-01-31T00:00:00Z"}`)
+		req, err := http.NewRequest(http.MethodPost, "/admin/closed_periods", body)
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		cp := ClosedPeriod{}
+		err = json.Unmarshal(w.Body.Bytes(), &cp)
+		assert.Nil(t, err)
+		assert.Equal(t, date(2023, 1, 31), cp.ClosedUntil.UTC())
+
+		w2 := httptest.NewRecorder()
+		req2, err := http.NewRequest(http.MethodGet, "/admin/closed_periods", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusOK, w2.Code)
+		periods := []ClosedPeriod{}
+		err = json.Unmarshal(w2.Body.Bytes(), &periods)
+		assert.Nil(t, err)
+		assert.Equal(t, []ClosedPeriod{cp}, periods)
+
+		w3 := httptest.NewRecorder()
+		req3, err := http.NewRequest(http.MethodDelete, "/admin/closed_periods/"+cp.ID.String(), nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w3, req3)
+		assert.Equal(t, http.StatusOK, w3.Code)
+	})
+
+	t.Run("Error when closed until date is missing", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/admin/closed_periods", bytes.NewBufferString(`{}`))
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var errResponse errors_handler.ErrorResponse
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "VA001", errResponse.Code)
+	})
+
+	t.Run("Error when deleting with invalid uuid", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodDelete, "/admin/closed_periods/abc", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var errResponse errors_handler.ErrorResponse
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "UI001", errResponse.Code)
+	})
+
+	DeleteAllClosedPeriods()
+}
@@ -0,0 +1,21 @@
+package closed_periods
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/common"
+)
+
+// ClosedPeriodFields closes the books up to ClosedUntil, included. The zero AccountId closes them for
+// every account and for the bills, otherwise only the transactions of that account are locked
+type ClosedPeriodFields struct {
+	AccountId   uuid.UUID `json:"account_id"`
+	ClosedUntil time.Time `json:"closed_until"`
+}
+
+type ClosedPeriod struct {
+	ID uuid.UUID `json:"id"`
+	ClosedPeriodFields
+	common.Timestamps
+}
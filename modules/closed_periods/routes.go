@@ -0,0 +1,12 @@
+package closed_periods
+
+import (
+	"github.com/grabielcruz/transportation_back/modules/idempotency"
+	"github.com/julienschmidt/httprouter"
+)
+
+func Routes(router *httprouter.Router) {
+	router.GET("/admin/closed_periods", GetClosedPeriodsHandler)
+	router.POST("/admin/closed_periods", idempotency.Handle(CreateClosedPeriodHandler))
+	router.DELETE("/admin/closed_periods/:id", DeleteClosedPeriodHandler)
+}
@@ -0,0 +1,104 @@
+package closed_periods
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/config"
+)
+
+// scanner is implemented by sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanClosedPeriod(row scanner) (ClosedPeriod, error) {
+	cp := ClosedPeriod{}
+	err := row.Scan(&cp.ID, &cp.AccountId, &cp.ClosedUntil, &cp.CreatedAt, &cp.UpdatedAt)
+	return cp, err
+}
+
+func GetClosedPeriods() ([]ClosedPeriod, error) {
+	periods := []ClosedPeriod{}
+	rows, err := database.DB.Query("SELECT * FROM closed_periods ORDER BY closed_until DESC, created_at DESC;")
+	if err != nil {
+		return periods, fmt.Errorf(errors_handler.DB005)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		cp, err := scanClosedPeriod(rows)
+		if err != nil {
+			return periods, fmt.Errorf(errors_handler.DB005)
+		}
+		periods = append(periods, cp)
+	}
+	return periods, nil
+}
+
+// periodsLock is the key of the advisory lock taken by the writes checking closed periods
+// and by the closing of a period, so a period is never closed while a write inside it is running
+const periodsLock = 7301
+
+// CreateClosedPeriod waits for the writes that already checked their dates, the later ones see the new period
+func CreateClosedPeriod(fields ClosedPeriodFields) (ClosedPeriod, error) {
+	cp := ClosedPeriod{}
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return cp, fmt.Errorf(errors_handler.DB002)
+	}
+	_, err = tx.Exec("SELECT pg_advisory_xact_lock($1);", periodsLock)
+	if err != nil {
+		tx.Rollback()
+		return cp, fmt.Errorf(errors_handler.DB005)
+	}
+	row := tx.QueryRow("INSERT INTO closed_periods (account_id, closed_until) VALUES ($1, $2) RETURNING *;", fields.AccountId, fields.ClosedUntil.Format(config.DateLayout))
+	cp, err = scanClosedPeriod(row)
+	if err != nil {
+		tx.Rollback()
+		return cp, errors_handler.MapDBErrors(err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return cp, fmt.Errorf(errors_handler.DB003)
+	}
+	return cp, nil
+}
+
+// DeleteClosedPeriod opens again the dates the period was locking, unless another period still covers them
+func DeleteClosedPeriod(closed_period_id uuid.UUID) (ClosedPeriod, error) {
+	row := database.DB.QueryRow("DELETE FROM closed_periods WHERE id = $1 RETURNING *;", closed_period_id)
+	cp, err := scanClosedPeriod(row)
+	if err != nil {
+		return cp, errors_handler.MapDBErrors(err)
+	}
+	return cp, nil
+}
+
+// CheckOpenPeriod fails when date is inside a period closed for every account or for account_id.
+// Bills are checked with the zero account, so only the periods closed for every account apply to them.
+// It runs inside tx, the database transaction of the write, and no period can be closed until tx ends
+func CheckOpenPeriod(tx *sql.Tx, account_id uuid.UUID, date time.Time) error {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock_shared($1);", periodsLock)
+	if err != nil {
+		return fmt.Errorf(errors_handler.DB005)
+	}
+	var closedUntil time.Time
+	row := tx.QueryRow("SELECT closed_until FROM closed_periods WHERE account_id IN ($1, $2) AND closed_until >= $3 LIMIT 1;", uuid.UUID{}, account_id, date.Format(config.DateLayout))
+	err = row.Scan(&closedUntil)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf(errors_handler.DB005)
+	}
+	return fmt.Errorf(errors_handler.CP001)
+}
+
+func DeleteAllClosedPeriods() {
+	database.DB.Exec("DELETE FROM closed_periods;")
+}
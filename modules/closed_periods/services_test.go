@@ -0,0 +1,136 @@
+package closed_periods
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/money_accounts"
+	"github.com/stretchr/testify/assert"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestClosedPeriodsServices(t *testing.T) {
+	envPath := filepath.Clean("../../.env_test")
+	sqlPath := filepath.Clean("../../database/database.sql")
+	database.SetupDB(envPath)
+	database.CreateTables(sqlPath)
+	defer database.CloseConnection()
+	account, err := money_accounts.CreateMoneyAccount(money_accounts.GenerateAccountFields(), uuid.UUID{})
+	assert.Nil(t, err)
+	otherAccount, err := money_accounts.CreateMoneyAccount(money_accounts.GenerateAccountFields(), uuid.UUID{})
+	assert.Nil(t, err)
+
+	// checkOpenPeriod runs the check on its own database transaction
+	checkOpenPeriod := func(account_id uuid.UUID, date time.Time) error {
+		tx, err := database.DB.Begin()
+		assert.Nil(t, err)
+		defer tx.Rollback()
+		return CheckOpenPeriod(tx, account_id, date)
+	}
+
+	t.Run("Get no closed periods", func(t *testing.T) {
+		periods, err := GetClosedPeriods()
+		assert.Nil(t, err)
+		assert.Len(t, periods, 0)
+		assert.Nil(t, checkOpenPeriod(account.ID, time.Now()))
+	})
+
+	t.Run("Close a period for every account", func(t *testing.T) {
+		cp, err := CreateClosedPeriod(ClosedPeriodFields{ClosedUntil: date(2023, 1, 31)})
+		assert.Nil(t, err)
+		assert.Equal(t, uuid.UUID{}, cp.AccountId)
+		assert.Equal(t, date(2023, 1, 31), cp.ClosedUntil.UTC())
+
+		err = checkOpenPeriod(account.ID, date(2023, 1, 31).Add(23*time.Hour))
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+		err = checkOpenPeriod(uuid.UUID{}, date(2022, 12, 1))
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+		assert.Nil(t, checkOpenPeriod(account.ID, date(2023, 2, 1)))
+
+		_, err = DeleteClosedPeriod(cp.ID)
+		assert.Nil(t, err)
+		assert.Nil(t, checkOpenPeriod(account.ID, date(2023, 1, 31)))
+	})
+
+	t.Run("Closing a period waits for the writes that checked it", func(t *testing.T) {
+		tx, err := database.DB.Begin()
+		assert.Nil(t, err)
+		assert.Nil(t, CheckOpenPeriod(tx, account.ID, date(2023, 1, 15)))
+
+		created := make(chan ClosedPeriod)
+		go func() {
+			cp, err := CreateClosedPeriod(ClosedPeriodFields{ClosedUntil: date(2023, 1, 31)})
+			assert.Nil(t, err)
+			created <- cp
+		}()
+		select {
+		case <-created:
+			t.Error("the period was closed while a write inside it was running")
+		case <-time.After(200 * time.Millisecond):
+		}
+		assert.Nil(t, tx.Commit())
+
+		cp := <-created
+		assert.NotNil(t, checkOpenPeriod(account.ID, date(2023, 1, 15)))
+		_, err = DeleteClosedPeriod(cp.ID)
+		assert.Nil(t, err)
+	})
+
+	t.Run("Close a period for one account", func(t *testing.T) {
+		_, err := CreateClosedPeriod(ClosedPeriodFields{AccountId: account.ID, ClosedUntil: date(2023, 1, 31)})
+		assert.Nil(t, err)
+
+		err = checkOpenPeriod(account.ID, date(2023, 1, 15))
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+		assert.Nil(t, checkOpenPeriod(otherAccount.ID, date(2023, 1, 15)))
+		// bills are only locked by the periods closed for every account
+		assert.Nil(t, checkOpenPeriod(uuid.UUID{}, date(2023, 1, 15)))
+
+		periods, err := GetClosedPeriods()
+		assert.Nil(t, err)
+		assert.Len(t, periods, 1)
+		assert.Equal(t, account.ID, periods[0].AccountId)
+	})
+
+	DeleteAllClosedPeriods()
+
+	t.Run("Error when closing the same period twice", func(t *testing.T) {
+		fields := ClosedPeriodFields{AccountId: account.ID, ClosedUntil: date(2023, 1, 31)}
+		_, err := CreateClosedPeriod(fields)
+		assert.Nil(t, err)
+		_, err = CreateClosedPeriod(fields)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP002, err.Error())
+	})
+
+	DeleteAllClosedPeriods()
+
+	t.Run("Error when closing a period of unexisting account", func(t *testing.T) {
+		randId, err := uuid.NewRandom()
+		assert.Nil(t, err)
+		_, err = CreateClosedPeriod(ClosedPeriodFields{AccountId: randId, ClosedUntil: date(2023, 1, 31)})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.MA003, err.Error())
+	})
+
+	t.Run("Error when deleting unexisting closed period", func(t *testing.T) {
+		randId, err := uuid.NewRandom()
+		assert.Nil(t, err)
+		_, err = DeleteClosedPeriod(randId)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+	})
+
+	DeleteAllClosedPeriods()
+	money_accounts.DeleteAllMoneyAccounts()
+}
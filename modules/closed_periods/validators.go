@@ -0,0 +1,10 @@
+package closed_periods
+
+import "fmt"
+
+func checkClosedPeriodFields(fields ClosedPeriodFields) error {
+	if fields.ClosedUntil.IsZero() {
+		return fmt.Errorf("Closed until date is required")
+	}
+	return nil
+}
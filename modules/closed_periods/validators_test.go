@@ -0,0 +1,17 @@
+package closed_periods
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckClosedPeriodFields(t *testing.T) {
+	fields := ClosedPeriodFields{}
+	err := checkClosedPeriodFields(fields)
+	assert.Equal(t, "Closed until date is required", err.Error())
+	fields.ClosedUntil = time.Now()
+	err = checkClosedPeriodFields(fields)
+	assert.Nil(t, err)
+}
@@ -1,4 +1,32 @@
 package config
 
+import (
+	"time"
+
+	"github.com/grabielcruz/transportation_back/money"
+)
+
 const Limit = 10
 const Offset = 0
+
+// layout of dates received in query strings and path params
+const DateLayout = "2006-01-02"
+
+// rounding applied to the fee of a transaction
+const FeeRounding = money.HalfUp
+
+// rounding applied when reports convert amounts to another currency
+const ConversionRounding = money.HalfUp
+
+// how often the scheduler creates the due recurring bills
+const SchedulerInterval = time.Hour
+
+// how far in the future the occurrences of a recurring bill are previewed
+const PreviewYears = 10
+
+// how long a session token is valid, it can be refreshed before it expires
+const TokenDuration = 8 * time.Hour
+
+// how long the response of a request with an Idempotency-Key is kept to be replayed,
+// main sets it from idempotency_key_ttl in the env file when it is there
+var IdempotencyKeyTTL = 24 * time.Hour
@@ -1,9 +1,16 @@
 package currencies
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
+	"github.com/grabielcruz/transportation_back/modules/config"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -14,7 +21,7 @@ func GetCurrenciesHandler(w http.ResponseWriter, r *http.Request, _ httprouter.P
 
 func CreateCurrencyHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	currency := ps.ByName("currency")
-	createdCurrency, err := CreateCurrency(currency)
+	createdCurrency, err := CreateCurrency(currency, audit_log.ActorId(r))
 	if err != nil {
 		common.SendServiceError(w, err.Error())
 		return
@@ -24,10 +31,129 @@ func CreateCurrencyHandler(w http.ResponseWriter, r *http.Request, ps httprouter
 
 func DeleteCurrencyHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	currency := ps.ByName("currency")
-	deletedCurrency, err := DeleteCurrency(currency)
+	deletedCurrency, err := DeleteCurrency(currency, audit_log.ActorId(r))
 	if err != nil {
 		common.SendServiceError(w, err.Error())
 		return
 	}
 	common.SendJson(w, http.StatusOK, deletedCurrency)
 }
+
+func GetExchangeRatesHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil {
+		common.SendInvalidQueryStringError(w, err.Error())
+		return
+	}
+	offset, err := strconv.Atoi(query.Get("offset"))
+	if err != nil {
+		common.SendInvalidQueryStringError(w, err.Error())
+		return
+	}
+	response, err := GetExchangeRates(query.Get("from"), query.Get("to"), limit, offset)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, response)
+}
+
+func GetExchangeRateHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	exchange_rate_id, err := uuid.Parse(ps.ByName("exchange_rate_id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	exchangeRate, err := GetExchangeRate(exchange_rate_id)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, exchangeRate)
+}
+
+func CreateExchangeRateHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	fields := ExchangeRateFields{}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		common.SendReadError(w)
+		return
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		common.SendUnmarshalError(w)
+		return
+	}
+	if err := checkExchangeRateFields(fields); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	exchangeRate, err := CreateExchangeRate(fields, audit_log.ActorId(r))
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusCreated, exchangeRate)
+}
+
+func UpdateExchangeRateHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	exchange_rate_id, err := uuid.Parse(ps.ByName("exchange_rate_id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	fields := ExchangeRateFields{}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		common.SendReadError(w)
+		return
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		common.SendUnmarshalError(w)
+		return
+	}
+	if err := checkExchangeRateFields(fields); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	exchangeRate, err := UpdateExchangeRate(exchange_rate_id, fields, audit_log.ActorId(r))
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, exchangeRate)
+}
+
+func DeleteExchangeRateHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	exchange_rate_id, err := uuid.Parse(ps.ByName("exchange_rate_id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	exchangeRate, err := DeleteExchangeRate(exchange_rate_id, audit_log.ActorId(r))
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, exchangeRate)
+}
+
+// GetEffectiveRateHandler returns the rate effective on the date of the query string, today when it is not sent
+func GetEffectiveRateHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	date := time.Now()
+	query := r.URL.Query()
+	if query.Get("date") != "" {
+		parsedDate, err := time.Parse(config.DateLayout, query.Get("date"))
+		if err != nil {
+			common.SendInvalidQueryStringError(w, err.Error())
+			return
+		}
+		date = parsedDate
+	}
+	exchangeRate, err := GetEffectiveRate(ps.ByName("from"), ps.ByName("to"), date)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, exchangeRate)
+}
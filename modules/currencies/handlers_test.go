@@ -7,10 +7,13 @@ import (
 	"net/http/httptest"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
 	"github.com/grabielcruz/transportation_back/modules/money_accounts"
+	"github.com/grabielcruz/transportation_back/money"
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
 )
@@ -203,12 +206,12 @@ func TestMoneyAccountsHandlers(t *testing.T) {
 	})
 
 	t.Run("Error when trying to delete currency associated with a money account", func(t *testing.T) {
-		createdCurrency, err := CreateCurrency("ABC")
+		createdCurrency, err := CreateCurrency("ABC", uuid.UUID{})
 		assert.Nil(t, err)
 
 		accountsFields := money_accounts.GenerateAccountFields()
 		accountsFields.Currency = createdCurrency
-		newMoneyAccount, err := money_accounts.CreateMoneyAccount(accountsFields)
+		newMoneyAccount, err := money_accounts.CreateMoneyAccount(accountsFields, uuid.UUID{})
 		assert.Nil(t, err)
 		assert.Equal(t, newMoneyAccount.Currency, createdCurrency)
 
@@ -244,4 +247,89 @@ func TestMoneyAccountsHandlers(t *testing.T) {
 		assert.Equal(t, "CU002", errResponse.Code)
 	})
 
+	t.Run("Create an exchange rate and get the effective one", func(t *testing.T) {
+		fields := ExchangeRateFields{
+			FromCurrency: "USD",
+			ToCurrency:   "VED",
+			Date:         time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC),
+			Rate:         money.MustParseRate("18.5"),
+		}
+		body, err := json.Marshal(fields)
+		assert.Nil(t, err)
+		req, err := http.NewRequest(http.MethodPost, "/exchange_rates", bytes.NewReader(body))
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		createdRate := ExchangeRate{}
+		err = json.Unmarshal(w.Body.Bytes(), &createdRate)
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParseRate("18.5"), createdRate.Rate)
+
+		req, err = http.NewRequest(http.MethodGet, "/effective_exchange_rate/USD/VED?date=2023-02-01", nil)
+		assert.Nil(t, err)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		effectiveRate := ExchangeRate{}
+		err = json.Unmarshal(w.Body.Bytes(), &effectiveRate)
+		assert.Nil(t, err)
+		assert.Equal(t, createdRate.ID, effectiveRate.ID)
+
+		req, err = http.NewRequest(http.MethodGet, "/exchange_rates?limit=10&offset=0&from=USD", nil)
+		assert.Nil(t, err)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		response := ExchangeRateResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, response.Count)
+	})
+
+	t.Run("Error when getting effective rate before any registered rate", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/effective_exchange_rate/USD/VED?date=2020-01-01", nil)
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, errors_handler.CU007, errResponse.Error)
+		assert.Equal(t, "CU007", errResponse.Code)
+	})
+
+	t.Run("Error when creating exchange rate with the same currencies", func(t *testing.T) {
+		fields := ExchangeRateFields{
+			FromCurrency: "USD",
+			ToCurrency:   "USD",
+			Date:         time.Now(),
+			Rate:         money.MustParseRate("1"),
+		}
+		body, err := json.Marshal(fields)
+		assert.Nil(t, err)
+		req, err := http.NewRequest(http.MethodPost, "/exchange_rates", bytes.NewReader(body))
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "Currencies should be different", errResponse.Error)
+		assert.Equal(t, "VA001", errResponse.Code)
+	})
+
+	deleteAllExchangeRates()
 }
@@ -0,0 +1,28 @@
+package currencies
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/money"
+)
+
+type ExchangeRate struct {
+	ID uuid.UUID `json:"id"`
+	ExchangeRateFields
+	common.Timestamps
+}
+
+// ExchangeRateFields holds how many units of to_currency one unit of from_currency was worth on a date
+type ExchangeRateFields struct {
+	FromCurrency string     `json:"from_currency"`
+	ToCurrency   string     `json:"to_currency"`
+	Date         time.Time  `json:"date"`
+	Rate         money.Rate `json:"rate"`
+}
+
+type ExchangeRateResponse struct {
+	ExchangeRates []ExchangeRate `json:"exchange_rates"`
+	common.Pagination
+}
@@ -1,9 +1,19 @@
 package currencies
 
-import "github.com/julienschmidt/httprouter"
+import (
+	"github.com/grabielcruz/transportation_back/modules/idempotency"
+	"github.com/julienschmidt/httprouter"
+)
 
 func Routes(router *httprouter.Router) {
 	router.GET("/currencies", GetCurrenciesHandler)
-	router.POST("/currencies/:currency", CreateCurrencyHandler)
+	router.POST("/currencies/:currency", idempotency.Handle(CreateCurrencyHandler))
 	router.DELETE("/currencies/:currency", DeleteCurrencyHandler)
+
+	router.GET("/exchange_rates", GetExchangeRatesHandler)
+	router.GET("/exchange_rates/:exchange_rate_id", GetExchangeRateHandler)
+	router.POST("/exchange_rates", idempotency.Handle(CreateExchangeRateHandler))
+	router.PATCH("/exchange_rates/:exchange_rate_id", UpdateExchangeRateHandler)
+	router.DELETE("/exchange_rates/:exchange_rate_id", DeleteExchangeRateHandler)
+	router.GET("/effective_exchange_rate/:from/:to", GetEffectiveRateHandler)
 }
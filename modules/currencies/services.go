@@ -2,9 +2,13 @@ package currencies
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
+	"github.com/grabielcruz/transportation_back/money"
 )
 
 func GetCurrencies() []string {
@@ -29,21 +33,36 @@ func GetCurrencies() []string {
 	return currencies
 }
 
-func CreateCurrency(newCurrency string) (string, error) {
+func CreateCurrency(newCurrency string, actor_id uuid.UUID) (string, error) {
 	createdCurrency := ""
 	err := CheckValidCurrency(newCurrency)
 	if err != nil {
 		return createdCurrency, err
 	}
-	row := database.DB.QueryRow("INSERT INTO currencies (currency) VALUES ($1) RETURNING currency;", newCurrency)
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return createdCurrency, fmt.Errorf(errors_handler.DB002)
+	}
+	row := tx.QueryRow("INSERT INTO currencies (currency) VALUES ($1) RETURNING currency;", newCurrency)
 	err = row.Scan(&createdCurrency)
 	if err != nil {
+		tx.Rollback()
 		return createdCurrency, errors_handler.MapDBErrors(err)
 	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.CurrencyEntity, createdCurrency, audit_log.CreateAction, nil, createdCurrency)
+	if err != nil {
+		tx.Rollback()
+		return createdCurrency, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return createdCurrency, fmt.Errorf(errors_handler.DB003)
+	}
 	return createdCurrency, nil
 }
 
-func DeleteCurrency(currency string) (string, error) {
+func DeleteCurrency(currency string, actor_id uuid.UUID) (string, error) {
 	deletedCurrency := ""
 	err := CheckValidCurrency(currency)
 	if err != nil {
@@ -52,15 +71,231 @@ func DeleteCurrency(currency string) (string, error) {
 	if currency == "VED" || currency == "USD" {
 		return deletedCurrency, fmt.Errorf("Could not delete VED or USD currency")
 	}
-	row := database.DB.QueryRow("DELETE FROM currencies WHERE currency = $1 RETURNING currency;", currency)
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return deletedCurrency, fmt.Errorf(errors_handler.DB002)
+	}
+	row := tx.QueryRow("DELETE FROM currencies WHERE currency = $1 RETURNING currency;", currency)
 	err = row.Scan(&deletedCurrency)
 	if err != nil {
+		tx.Rollback()
 		return deletedCurrency, errors_handler.MapDBErrors(err)
 	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.CurrencyEntity, deletedCurrency, audit_log.DeleteAction, deletedCurrency, nil)
+	if err != nil {
+		tx.Rollback()
+		return deletedCurrency, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return deletedCurrency, fmt.Errorf(errors_handler.DB003)
+	}
 	return deletedCurrency, nil
 }
 
+// GetExchangeRates returns the registered rates from newest to oldest, from_currency and to_currency
+// are optional filters, an empty string matches any currency
+func GetExchangeRates(from_currency string, to_currency string, limit int, offset int) (ExchangeRateResponse, error) {
+	response := ExchangeRateResponse{}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.DB002)
+	}
+
+	row := tx.QueryRow("SELECT COUNT(*) FROM exchange_rates WHERE ($1 = '' OR from_currency = $1) AND ($2 = '' OR to_currency = $2);", from_currency, to_currency)
+	err = row.Scan(&response.Count)
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.DB004)
+	}
+
+	rows, err := tx.Query("SELECT * FROM exchange_rates WHERE ($1 = '' OR from_currency = $1) AND ($2 = '' OR to_currency = $2) ORDER BY date DESC, created_at DESC LIMIT $3 OFFSET $4;", from_currency, to_currency, limit, offset)
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.DB005)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		er := ExchangeRate{}
+		err = rows.Scan(&er.ID, &er.FromCurrency, &er.ToCurrency, &er.Date, &er.Rate, &er.CreatedAt, &er.UpdatedAt)
+		if err != nil {
+			tx.Rollback()
+			return response, fmt.Errorf(errors_handler.DB005)
+		}
+		response.ExchangeRates = append(response.ExchangeRates, er)
+	}
+
+	response.Limit = limit
+	response.Offset = offset
+
+	err = tx.Commit()
+	if err != nil {
+		return response, fmt.Errorf(errors_handler.DB003)
+	}
+
+	return response, nil
+}
+
+func GetExchangeRate(exchange_rate_id uuid.UUID) (ExchangeRate, error) {
+	er := ExchangeRate{}
+	row := database.DB.QueryRow("SELECT * FROM exchange_rates WHERE id = $1;", exchange_rate_id)
+	err := row.Scan(&er.ID, &er.FromCurrency, &er.ToCurrency, &er.Date, &er.Rate, &er.CreatedAt, &er.UpdatedAt)
+	if err != nil {
+		return er, fmt.Errorf(errors_handler.DB001)
+	}
+	return er, nil
+}
+
+func CreateExchangeRate(fields ExchangeRateFields, actor_id uuid.UUID) (ExchangeRate, error) {
+	er := ExchangeRate{}
+	if fields.Rate.Sign() <= 0 {
+		return er, fmt.Errorf(errors_handler.CU008)
+	}
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return er, fmt.Errorf(errors_handler.DB002)
+	}
+	row := tx.QueryRow("INSERT INTO exchange_rates (from_currency, to_currency, date, rate) VALUES ($1, $2, $3, $4) RETURNING *;", fields.FromCurrency, fields.ToCurrency, fields.Date, fields.Rate)
+	err = row.Scan(&er.ID, &er.FromCurrency, &er.ToCurrency, &er.Date, &er.Rate, &er.CreatedAt, &er.UpdatedAt)
+	if err != nil {
+		tx.Rollback()
+		return er, errors_handler.MapDBErrors(err)
+	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.ExchangeRateEntity, er.ID.String(), audit_log.CreateAction, nil, er)
+	if err != nil {
+		tx.Rollback()
+		return er, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return er, fmt.Errorf(errors_handler.DB003)
+	}
+	return er, nil
+}
+
+func UpdateExchangeRate(exchange_rate_id uuid.UUID, fields ExchangeRateFields, actor_id uuid.UUID) (ExchangeRate, error) {
+	er := ExchangeRate{}
+	if fields.Rate.Sign() <= 0 {
+		return er, fmt.Errorf(errors_handler.CU008)
+	}
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return er, fmt.Errorf(errors_handler.DB002)
+	}
+	before := ExchangeRate{}
+	row := tx.QueryRow("SELECT * FROM exchange_rates WHERE id = $1 FOR UPDATE;", exchange_rate_id)
+	err = row.Scan(&before.ID, &before.FromCurrency, &before.ToCurrency, &before.Date, &before.Rate, &before.CreatedAt, &before.UpdatedAt)
+	if err != nil {
+		tx.Rollback()
+		return er, errors_handler.MapDBErrors(err)
+	}
+	row = tx.QueryRow("UPDATE exchange_rates SET from_currency = $1, to_currency = $2, date = $3, rate = $4, updated_at = $5 WHERE id = $6 RETURNING *;", fields.FromCurrency, fields.ToCurrency, fields.Date, fields.Rate, time.Now(), exchange_rate_id)
+	err = row.Scan(&er.ID, &er.FromCurrency, &er.ToCurrency, &er.Date, &er.Rate, &er.CreatedAt, &er.UpdatedAt)
+	if err != nil {
+		tx.Rollback()
+		return er, errors_handler.MapDBErrors(err)
+	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.ExchangeRateEntity, exchange_rate_id.String(), audit_log.UpdateAction, before, er)
+	if err != nil {
+		tx.Rollback()
+		return er, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return er, fmt.Errorf(errors_handler.DB003)
+	}
+	return er, nil
+}
+
+func DeleteExchangeRate(exchange_rate_id uuid.UUID, actor_id uuid.UUID) (ExchangeRate, error) {
+	er := ExchangeRate{}
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return er, fmt.Errorf(errors_handler.DB002)
+	}
+	row := tx.QueryRow("DELETE FROM exchange_rates WHERE id = $1 RETURNING *;", exchange_rate_id)
+	err = row.Scan(&er.ID, &er.FromCurrency, &er.ToCurrency, &er.Date, &er.Rate, &er.CreatedAt, &er.UpdatedAt)
+	if err != nil {
+		tx.Rollback()
+		return er, errors_handler.MapDBErrors(err)
+	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.ExchangeRateEntity, exchange_rate_id.String(), audit_log.DeleteAction, er, nil)
+	if err != nil {
+		tx.Rollback()
+		return er, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return er, fmt.Errorf(errors_handler.DB003)
+	}
+	return er, nil
+}
+
+// GetEffectiveRate returns the rate from from_currency to to_currency effective on date, that is the one registered
+// on that date or the most recent one before it. When only the opposite pair is registered its inverse is returned.
+// Converting a currency to itself always has rate 1
+func GetEffectiveRate(from_currency string, to_currency string, date time.Time) (ExchangeRate, error) {
+	er, inverted, err := findEffectiveRate(from_currency, to_currency, date)
+	if err != nil {
+		return er, err
+	}
+	if inverted {
+		er.Rate = er.Rate.Inverse()
+	}
+	return er, nil
+}
+
+// findEffectiveRate is like GetEffectiveRate but it keeps the registered rate of the opposite pair,
+// telling whether it has to be inverted
+func findEffectiveRate(from_currency string, to_currency string, date time.Time) (ExchangeRate, bool, error) {
+	er := ExchangeRate{}
+	if from_currency == to_currency {
+		er.FromCurrency = from_currency
+		er.ToCurrency = to_currency
+		er.Date = date
+		er.Rate = money.OneRate
+		return er, false, nil
+	}
+
+	row := database.DB.QueryRow("SELECT * FROM exchange_rates WHERE from_currency = $1 AND to_currency = $2 AND date <= $3 ORDER BY date DESC LIMIT 1;", from_currency, to_currency, date)
+	err := row.Scan(&er.ID, &er.FromCurrency, &er.ToCurrency, &er.Date, &er.Rate, &er.CreatedAt, &er.UpdatedAt)
+	if err == nil {
+		return er, false, nil
+	}
+
+	row = database.DB.QueryRow("SELECT * FROM exchange_rates WHERE from_currency = $1 AND to_currency = $2 AND date <= $3 ORDER BY date DESC LIMIT 1;", to_currency, from_currency, date)
+	err = row.Scan(&er.ID, &er.ToCurrency, &er.FromCurrency, &er.Date, &er.Rate, &er.CreatedAt, &er.UpdatedAt)
+	if err != nil {
+		return ExchangeRate{}, false, fmt.Errorf(errors_handler.CU007)
+	}
+	return er, true, nil
+}
+
+// Convert returns amount expressed in to_currency, using the rate effective on date and rounding with mode.
+// The amount is divided by the rate of the opposite pair instead of being multiplied by its rounded inverse
+func Convert(amount money.Money, from_currency string, to_currency string, date time.Time, mode money.RoundingMode) (money.Money, error) {
+	er, inverted, err := findEffectiveRate(from_currency, to_currency, date)
+	if err != nil {
+		return money.Zero, err
+	}
+	if inverted {
+		return amount.DivRate(er.Rate, mode), nil
+	}
+	return amount.MulRate(er.Rate, mode), nil
+}
+
 func resetCurrencies() {
 	database.DB.QueryRow("DELETE FROM currencies WHERE currency <> $1;", "000")
 	database.DB.QueryRow("INSERT INTO currencies (currency) VALUES ('VED'), ('USD');")
 }
+
+func deleteAllExchangeRates() {
+	database.DB.Exec("DELETE FROM exchange_rates;")
+}
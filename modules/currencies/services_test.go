@@ -3,10 +3,14 @@ package currencies
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/config"
 	"github.com/grabielcruz/transportation_back/modules/money_accounts"
+	"github.com/grabielcruz/transportation_back/money"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,7 +30,7 @@ func TestCurrenciesServices(t *testing.T) {
 
 	t.Run("Can create a currency", func(t *testing.T) {
 		newCurrency := "ABC"
-		createdCurrency, err := CreateCurrency(newCurrency)
+		createdCurrency, err := CreateCurrency(newCurrency, uuid.UUID{})
 		assert.Nil(t, err)
 		assert.Equal(t, newCurrency, createdCurrency)
 		currencies := GetCurrencies()
@@ -40,26 +44,26 @@ func TestCurrenciesServices(t *testing.T) {
 
 	t.Run("Error when creating repeated currency", func(t *testing.T) {
 		newCurrency := "VED"
-		_, err := CreateCurrency(newCurrency)
+		_, err := CreateCurrency(newCurrency, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.CU003, err.Error())
 	})
 
 	t.Run("Error when creating empty currency", func(t *testing.T) {
 		newCurrency := ""
-		_, err := CreateCurrency(newCurrency)
+		_, err := CreateCurrency(newCurrency, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.CU002, err.Error())
 	})
 
 	t.Run("Can create a currency, then delete it", func(t *testing.T) {
 		newCurrency := "ABC"
-		createdCurrency, err := CreateCurrency(newCurrency)
+		createdCurrency, err := CreateCurrency(newCurrency, uuid.UUID{})
 		assert.Nil(t, err)
 		assert.Equal(t, newCurrency, createdCurrency)
 
 		// deleting
-		deletedCurrency, err := DeleteCurrency(newCurrency)
+		deletedCurrency, err := DeleteCurrency(newCurrency, uuid.UUID{})
 		assert.Nil(t, err)
 		assert.Equal(t, newCurrency, deletedCurrency)
 
@@ -74,42 +78,160 @@ func TestCurrenciesServices(t *testing.T) {
 
 	t.Run("Error when deleting unexisting currency", func(t *testing.T) {
 		currency := "KKK"
-		_, err := DeleteCurrency(currency)
+		_, err := DeleteCurrency(currency, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.DB001, err.Error())
 	})
 
 	t.Run("Error when trying to delete VED or USD currencies", func(t *testing.T) {
-		_, err := DeleteCurrency("VED")
+		_, err := DeleteCurrency("VED", uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.CU001, err.Error())
-		_, err = DeleteCurrency("USD")
+		_, err = DeleteCurrency("USD", uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.CU001, err.Error())
 	})
 
 	t.Run("Error when trying to delete currency associated with a money account", func(t *testing.T) {
-		createdCurrency, err := CreateCurrency("ABC")
+		createdCurrency, err := CreateCurrency("ABC", uuid.UUID{})
 		assert.Nil(t, err)
 
 		accountsFields := money_accounts.GenerateAccountFields()
 		accountsFields.Currency = createdCurrency
-		newMoneyAccount, err := money_accounts.CreateMoneyAccount(accountsFields)
+		newMoneyAccount, err := money_accounts.CreateMoneyAccount(accountsFields, uuid.UUID{})
 		assert.Nil(t, err)
 		assert.Equal(t, newMoneyAccount.Currency, createdCurrency)
 
-		_, err = DeleteCurrency(createdCurrency)
+		_, err = DeleteCurrency(createdCurrency, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.CU004, err.Error())
 	})
 
 	t.Run("Error when deleting zero currency", func(t *testing.T) {
 		currency := "000"
-		_, err := DeleteCurrency(currency)
+		_, err := DeleteCurrency(currency, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.CU002, err.Error())
 	})
 
 	resetCurrencies()
 	money_accounts.DeleteAllMoneyAccounts()
+	t.Run("Create, update and delete an exchange rate", func(t *testing.T) {
+		fields := ExchangeRateFields{
+			FromCurrency: "USD",
+			ToCurrency:   "VED",
+			Date:         time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC),
+			Rate:         money.MustParseRate("18.5"),
+		}
+		exchangeRate, err := CreateExchangeRate(fields, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, "USD", exchangeRate.FromCurrency)
+		assert.Equal(t, "VED", exchangeRate.ToCurrency)
+		assert.Equal(t, money.MustParseRate("18.5"), exchangeRate.Rate)
+
+		_, err = CreateExchangeRate(fields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CU006, err.Error())
+
+		fields.Rate = money.MustParseRate("19")
+		updatedRate, err := UpdateExchangeRate(exchangeRate.ID, fields, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParseRate("19"), updatedRate.Rate)
+
+		response, err := GetExchangeRates("USD", "", config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, response.Count)
+		assert.Len(t, response.ExchangeRates, 1)
+
+		deletedRate, err := DeleteExchangeRate(exchangeRate.ID, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, exchangeRate.ID, deletedRate.ID)
+
+		_, err = GetExchangeRate(exchangeRate.ID)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+	})
+
+	deleteAllExchangeRates()
+
+	t.Run("Error when creating exchange rate with unexisting currency", func(t *testing.T) {
+		fields := ExchangeRateFields{
+			FromCurrency: "USD",
+			ToCurrency:   "XYZ",
+			Date:         time.Now(),
+			Rate:         money.MustParseRate("2"),
+		}
+		_, err := CreateExchangeRate(fields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CU005, err.Error())
+
+		fields.ToCurrency = "VED"
+		fields.Rate = money.Rate{}
+		_, err = CreateExchangeRate(fields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CU008, err.Error())
+	})
+
+	t.Run("Get effective rate falling back to the most recent earlier one", func(t *testing.T) {
+		for day, rate := range map[int]string{1: "10", 5: "20", 20: "40"} {
+			_, err := CreateExchangeRate(ExchangeRateFields{
+				FromCurrency: "USD",
+				ToCurrency:   "VED",
+				Date:         time.Date(2023, 3, day, 0, 0, 0, 0, time.UTC),
+				Rate:         money.MustParseRate(rate),
+			}, uuid.UUID{})
+			assert.Nil(t, err)
+		}
+
+		exchangeRate, err := GetEffectiveRate("USD", "VED", time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC))
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParseRate("20"), exchangeRate.Rate)
+
+		exchangeRate, err = GetEffectiveRate("USD", "VED", time.Date(2023, 3, 19, 0, 0, 0, 0, time.UTC))
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParseRate("20"), exchangeRate.Rate)
+
+		// inverse of the registered pair
+		exchangeRate, err = GetEffectiveRate("VED", "USD", time.Date(2023, 3, 25, 0, 0, 0, 0, time.UTC))
+		assert.Nil(t, err)
+		assert.Equal(t, "VED", exchangeRate.FromCurrency)
+		assert.Equal(t, "USD", exchangeRate.ToCurrency)
+		assert.Equal(t, money.MustParseRate("0.025"), exchangeRate.Rate)
+
+		_, err = GetEffectiveRate("USD", "VED", time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC))
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CU007, err.Error())
+
+		exchangeRate, err = GetEffectiveRate("USD", "USD", time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, money.OneRate, exchangeRate.Rate)
+	})
+
+	t.Run("Convert amounts between currencies", func(t *testing.T) {
+		converted, err := Convert(money.MustParse("12.5"), "USD", "VED", time.Date(2023, 3, 6, 0, 0, 0, 0, time.UTC), money.HalfUp)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(250), converted)
+
+		converted, err = Convert(money.FromUnits(100), "VED", "USD", time.Date(2023, 3, 6, 0, 0, 0, 0, time.UTC), money.HalfUp)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(5), converted)
+
+		// amounts are divided by the registered rate, the rounded inverse 0.333333 would give 333333.00
+		_, err = CreateExchangeRate(ExchangeRateFields{
+			FromCurrency: "USD",
+			ToCurrency:   "VED",
+			Date:         time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC),
+			Rate:         money.MustParseRate("3"),
+		}, uuid.UUID{})
+		assert.Nil(t, err)
+		converted, err = Convert(money.FromUnits(1000000), "VED", "USD", time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC), money.HalfUp)
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParse("333333.33"), converted)
+
+		_, err = Convert(money.FromUnits(100), "VED", "USD", time.Date(2022, 3, 6, 0, 0, 0, 0, time.UTC), money.HalfUp)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CU007, err.Error())
+	})
+
+	deleteAllExchangeRates()
 }
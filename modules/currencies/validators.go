@@ -12,3 +12,22 @@ func CheckValidCurrency(currency string) error {
 	}
 	return nil
 }
+
+func checkExchangeRateFields(fields ExchangeRateFields) error {
+	if err := CheckValidCurrency(fields.FromCurrency); err != nil {
+		return err
+	}
+	if err := CheckValidCurrency(fields.ToCurrency); err != nil {
+		return err
+	}
+	if fields.FromCurrency == fields.ToCurrency {
+		return fmt.Errorf("Currencies should be different")
+	}
+	if fields.Date.IsZero() {
+		return fmt.Errorf("Exchange rate should have a date")
+	}
+	if fields.Rate.Sign() <= 0 {
+		return fmt.Errorf("Rate should be greater than zero")
+	}
+	return nil
+}
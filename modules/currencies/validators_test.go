@@ -2,8 +2,10 @@ package currencies
 
 import (
 	"testing"
+	"time"
 
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/money"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,3 +38,22 @@ func TestCheckValidCurrency(t *testing.T) {
 	err = CheckValidCurrency(badCurrency3)
 	assert.Equal(t, errors_handler.CU002, err.Error())
 }
+
+func TestCheckExchangeRateFields(t *testing.T) {
+	fields := ExchangeRateFields{FromCurrency: "usd"}
+	err := checkExchangeRateFields(fields)
+	assert.Equal(t, errors_handler.CU002, err.Error())
+	fields.FromCurrency = "USD"
+	fields.ToCurrency = "USD"
+	err = checkExchangeRateFields(fields)
+	assert.Equal(t, "Currencies should be different", err.Error())
+	fields.ToCurrency = "VED"
+	err = checkExchangeRateFields(fields)
+	assert.Equal(t, "Exchange rate should have a date", err.Error())
+	fields.Date = time.Now()
+	err = checkExchangeRateFields(fields)
+	assert.Equal(t, "Rate should be greater than zero", err.Error())
+	fields.Rate = money.MustParseRate("20")
+	err = checkExchangeRateFields(fields)
+	assert.Nil(t, err)
+}
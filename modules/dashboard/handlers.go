@@ -0,0 +1,37 @@
+package dashboard
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/grabielcruz/transportation_back/modules/currencies"
+	"github.com/julienschmidt/httprouter"
+)
+
+// GetDashboardHandler sends the totals of the company per currency. The query string has the base currency
+// of the grand total and may have the date of the rates used to convert to it, today by default
+func GetDashboardHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+	baseCurrency := query.Get("currency")
+	if err := currencies.CheckValidCurrency(baseCurrency); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	date := time.Now()
+	var err error
+	if query.Get("date") != "" {
+		date, err = time.Parse(config.DateLayout, query.Get("date"))
+		if err != nil {
+			common.SendInvalidQueryStringError(w, err.Error())
+			return
+		}
+	}
+	dashboard, err := GetDashboard(baseCurrency, date)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, dashboard)
+}
@@ -0,0 +1,71 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/money_accounts"
+	"github.com/grabielcruz/transportation_back/money"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDashboardHandlers(t *testing.T) {
+	envPath := filepath.Clean("../../.env_test")
+	sqlPath := filepath.Clean("../../database/database.sql")
+	database.SetupDB(envPath)
+	database.CreateTables(sqlPath)
+	defer database.CloseConnection()
+	router := httprouter.New()
+	Routes(router)
+
+	t.Run("Get dashboard", func(t *testing.T) {
+		createAccount(t, "USD", money.FromUnits(100))
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/dashboard?currency=USD&date=2023-01-01", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		d := Dashboard{}
+		err = json.Unmarshal(w.Body.Bytes(), &d)
+		assert.Nil(t, err)
+		assert.Equal(t, "USD", d.BaseCurrency)
+		assert.Equal(t, "2023-01-01", d.Date.Format("2006-01-02"))
+		assert.Equal(t, money.FromUnits(100), d.GrandTotal)
+	})
+
+	t.Run("Error when base currency is missing", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/dashboard", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var errResponse errors_handler.ErrorResponse
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "VA001", errResponse.Code)
+	})
+
+	t.Run("Error when date is invalid", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/dashboard?currency=USD&date=yesterday", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var errResponse errors_handler.ErrorResponse
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "QS001", errResponse.Code)
+	})
+
+	money_accounts.DeleteAllMoneyAccounts()
+}
@@ -0,0 +1,27 @@
+package dashboard
+
+import (
+	"time"
+
+	"github.com/grabielcruz/transportation_back/money"
+)
+
+// CurrencyTotals is the position of the company in one currency. Cash is the sum of the balances of the
+// money accounts, receivables and payables come from the pending bills, payables are shown as positive numbers.
+// Net = Cash + Receivables - Payables
+type CurrencyTotals struct {
+	Currency    string      `json:"currency"`
+	Cash        money.Money `json:"cash"`
+	Receivables money.Money `json:"receivables"`
+	Payables    money.Money `json:"payables"`
+	Net         money.Money `json:"net"`
+}
+
+// Dashboard has the totals per currency and the net of all of them converted to BaseCurrency
+// with the rates effective on Date
+type Dashboard struct {
+	Currencies   []CurrencyTotals `json:"currencies"`
+	BaseCurrency string           `json:"base_currency"`
+	Date         time.Time        `json:"date"`
+	GrandTotal   money.Money      `json:"grand_total"`
+}
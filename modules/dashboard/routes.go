@@ -0,0 +1,7 @@
+package dashboard
+
+import "github.com/julienschmidt/httprouter"
+
+func Routes(router *httprouter.Router) {
+	router.GET("/dashboard", GetDashboardHandler)
+}
@@ -0,0 +1,76 @@
+package dashboard
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/grabielcruz/transportation_back/modules/currencies"
+	"github.com/grabielcruz/transportation_back/money"
+)
+
+// GetDashboard sums the money accounts and the pending bills per currency, the net of every currency
+// is converted to base_currency with the rates effective on date and added to the grand total
+func GetDashboard(base_currency string, date time.Time) (Dashboard, error) {
+	d := Dashboard{Currencies: []CurrencyTotals{}, BaseCurrency: base_currency, Date: date}
+	totals := map[string]*CurrencyTotals{}
+	get := func(currency string) *CurrencyTotals {
+		if _, ok := totals[currency]; !ok {
+			totals[currency] = &CurrencyTotals{Currency: currency}
+		}
+		return totals[currency]
+	}
+
+	rows, err := database.DB.Query("SELECT currency, SUM(balance) FROM money_accounts WHERE id <> $1 GROUP BY currency;", uuid.UUID{})
+	if err != nil {
+		return d, fmt.Errorf(errors_handler.DB005)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var currency string
+		var cash money.Money
+		err = rows.Scan(&currency, &cash)
+		if err != nil {
+			return d, fmt.Errorf(errors_handler.DB005)
+		}
+		get(currency).Cash = cash
+	}
+
+	rows, err = database.DB.Query("SELECT currency, COALESCE(SUM(amount) FILTER (WHERE amount > 0), 0), COALESCE(SUM(-amount) FILTER (WHERE amount < 0), 0) FROM pending_bills WHERE id <> $1 GROUP BY currency;", uuid.UUID{})
+	if err != nil {
+		return d, fmt.Errorf(errors_handler.DB005)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var currency string
+		var receivables, payables money.Money
+		err = rows.Scan(&currency, &receivables, &payables)
+		if err != nil {
+			return d, fmt.Errorf(errors_handler.DB005)
+		}
+		ct := get(currency)
+		ct.Receivables = receivables
+		ct.Payables = payables
+	}
+
+	for _, ct := range totals {
+		ct.Net = ct.Cash + ct.Receivables - ct.Payables
+		d.Currencies = append(d.Currencies, *ct)
+	}
+	sort.Slice(d.Currencies, func(i, j int) bool {
+		return d.Currencies[i].Currency < d.Currencies[j].Currency
+	})
+
+	for _, ct := range d.Currencies {
+		converted, err := currencies.Convert(ct.Net, ct.Currency, base_currency, date, config.ConversionRounding)
+		if err != nil {
+			return d, err
+		}
+		d.GrandTotal += converted
+	}
+	return d, nil
+}
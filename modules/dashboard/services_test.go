@@ -0,0 +1,104 @@
+package dashboard
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/bills"
+	"github.com/grabielcruz/transportation_back/modules/currencies"
+	"github.com/grabielcruz/transportation_back/modules/money_accounts"
+	"github.com/grabielcruz/transportation_back/modules/persons"
+	"github.com/grabielcruz/transportation_back/money"
+	"github.com/stretchr/testify/assert"
+)
+
+// createAccount creates an account in currency holding balance
+func createAccount(t *testing.T, currency string, balance money.Money) money_accounts.MoneyAccount {
+	fields := money_accounts.GenerateAccountFields()
+	fields.Currency = currency
+	account, err := money_accounts.CreateMoneyAccount(fields, uuid.UUID{})
+	assert.Nil(t, err)
+	_, err = database.DB.Exec("UPDATE money_accounts SET balance = $1 WHERE id = $2;", balance, account.ID)
+	assert.Nil(t, err)
+	return account
+}
+
+// createBill creates a pending bill of the person in currency
+func createBill(t *testing.T, person_id uuid.UUID, currency string, amount money.Money) {
+	fields := bills.GenerateBillFields(person_id)
+	fields.Currency = currency
+	fields.Amount = amount
+	_, err := bills.CreatePendingBill(fields, uuid.UUID{})
+	assert.Nil(t, err)
+}
+
+func TestDashboardServices(t *testing.T) {
+	envPath := filepath.Clean("../../.env_test")
+	sqlPath := filepath.Clean("../../database/database.sql")
+	database.SetupDB(envPath)
+	database.CreateTables(sqlPath)
+	defer database.CloseConnection()
+	person, err := persons.CreatePerson(persons.GeneratePersonFields(), uuid.UUID{})
+	assert.Nil(t, err)
+
+	t.Run("Get an empty dashboard", func(t *testing.T) {
+		d, err := GetDashboard("USD", time.Now())
+		assert.Nil(t, err)
+		assert.Len(t, d.Currencies, 0)
+		assert.Equal(t, "USD", d.BaseCurrency)
+		assert.Equal(t, money.Zero, d.GrandTotal)
+	})
+
+	t.Run("Get totals per currency and the grand total in the base currency", func(t *testing.T) {
+		createAccount(t, "USD", money.FromUnits(100))
+		createAccount(t, "USD", money.MustParse("50.5"))
+		createAccount(t, "VED", money.FromUnits(1000))
+		createBill(t, person.ID, "USD", money.FromUnits(20))
+		createBill(t, person.ID, "USD", money.FromUnits(-30))
+		createBill(t, person.ID, "VED", money.FromUnits(-500))
+
+		rateDate := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		// 1 USD = 10 VED
+		rate, err := currencies.CreateExchangeRate(currencies.ExchangeRateFields{FromCurrency: "USD", ToCurrency: "VED", Date: rateDate, Rate: money.MustParseRate("10")}, uuid.UUID{})
+		assert.Nil(t, err)
+
+		d, err := GetDashboard("USD", time.Now())
+		assert.Nil(t, err)
+		assert.Len(t, d.Currencies, 2)
+		assert.Equal(t, CurrencyTotals{
+			Currency:    "USD",
+			Cash:        money.MustParse("150.5"),
+			Receivables: money.FromUnits(20),
+			Payables:    money.FromUnits(30),
+			Net:         money.MustParse("140.5"),
+		}, d.Currencies[0])
+		assert.Equal(t, CurrencyTotals{
+			Currency:    "VED",
+			Cash:        money.FromUnits(1000),
+			Receivables: money.Zero,
+			Payables:    money.FromUnits(500),
+			Net:         money.FromUnits(500),
+		}, d.Currencies[1])
+		assert.Equal(t, money.MustParse("190.5"), d.GrandTotal)
+
+		d, err = GetDashboard("VED", time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(1905), d.GrandTotal)
+
+		// no rate registered before the date
+		_, err = GetDashboard("USD", rateDate.AddDate(0, 0, -1))
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CU007, err.Error())
+
+		_, err = currencies.DeleteExchangeRate(rate.ID, uuid.UUID{})
+		assert.Nil(t, err)
+	})
+
+	bills.EmptyBills()
+	money_accounts.DeleteAllMoneyAccounts()
+	persons.DeleteAllPersons()
+}
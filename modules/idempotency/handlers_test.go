@@ -0,0 +1,102 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyHandlers(t *testing.T) {
+	envPath := filepath.Clean("../../.env_test")
+	sqlPath := filepath.Clean("../../database/database.sql")
+	database.SetupDB(envPath)
+	database.CreateTables(sqlPath)
+	defer database.CloseConnection()
+
+	// the handler creates a record per call and fails when the body is empty, its json response has a content type
+	calls := 0
+	router := httprouter.New()
+	router.POST("/records", Handle(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil || len(body) == 0 {
+			common.SendValidationError(w, "Body is required")
+			return
+		}
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		common.SendJson(w, http.StatusCreated, map[string]any{"call": calls, "body": string(body)})
+	}))
+
+	send := func(key string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/records", strings.NewReader(body))
+		assert.Nil(t, err)
+		if key != "" {
+			req.Header.Set(HeaderName, key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	errorCode := func(w *httptest.ResponseRecorder) string {
+		var errResponse errors_handler.ErrorResponse
+		err := json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		return errResponse.Code
+	}
+
+	t.Run("Replay the response of a retry with the same body", func(t *testing.T) {
+		calls = 0
+		w := send("first", "one")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "", w.Header().Get(ReplayedHeaderName))
+		w2 := send("first", "one")
+		assert.Equal(t, http.StatusCreated, w2.Code)
+		assert.Equal(t, "true", w2.Header().Get(ReplayedHeaderName))
+		assert.Equal(t, "application/json", w2.Header().Get("Content-Type"))
+		assert.Equal(t, w.Body.String(), w2.Body.String())
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Error when using the same key with a different body", func(t *testing.T) {
+		w := send("second", "one")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w2 := send("second", "two")
+		assert.Equal(t, http.StatusConflict, w2.Code)
+		assert.Equal(t, "IK001", errorCode(w2))
+	})
+
+	t.Run("Retry with the same key after a failed request", func(t *testing.T) {
+		calls = 0
+		w := send("third", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w2 := send("third", "one")
+		assert.Equal(t, http.StatusCreated, w2.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Requests without key are not replayed", func(t *testing.T) {
+		calls = 0
+		send("", "one")
+		send("", "one")
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("Error when the key is too long", func(t *testing.T) {
+		w := send(strings.Repeat("k", 256), "one")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "IK003", errorCode(w))
+	})
+
+	DeleteAllIdempotencyKeys()
+}
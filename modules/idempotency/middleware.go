@@ -0,0 +1,112 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/common"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/users"
+	"github.com/julienschmidt/httprouter"
+)
+
+const maxKeyLength = 255
+
+// responseRecorder keeps a copy of the response sent by the handler
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+// WriteHeader keeps the headers as they are sent, later changes are not part of the response
+func (rr *responseRecorder) WriteHeader(status int) {
+	rr.status = status
+	rr.header = rr.ResponseWriter.Header().Clone()
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	if rr.header == nil {
+		rr.header = rr.ResponseWriter.Header().Clone()
+	}
+	rr.body.Write(data)
+	return rr.ResponseWriter.Write(data)
+}
+
+// requestHash identifies the request a key was used for, by its route and body
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Handle makes a creating handler idempotent for the requests with an Idempotency-Key header.
+// The first request with a key runs the handler and its successful response is stored with its headers, then a retry
+// with the same body gets the stored response again and a request with a different body gets a conflict.
+// Failed responses are not stored so the request can be retried with the same key.
+// Requests without the header run the handler as usual
+func Handle(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		key := r.Header.Get(HeaderName)
+		if key == "" {
+			next(w, r, ps)
+			return
+		}
+		if len(key) > maxKeyLength {
+			common.SendServiceError(w, errors_handler.IK003)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			common.SendReadError(w)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		userId := uuid.UUID{}
+		if claims, err := users.GetClaims(r); err == nil {
+			userId = claims.UserId
+		}
+		hash := requestHash(r, body)
+		stored, claimed, err := claimKey(userId, key, hash)
+		if err != nil {
+			common.SendServiceError(w, err.Error())
+			return
+		}
+		if !claimed {
+			switch {
+			case stored.RequestHash != hash:
+				common.SendConflictError(w, errors_handler.IK001)
+			case stored.StatusCode == 0:
+				common.SendConflictError(w, errors_handler.IK002)
+			default:
+				for name, values := range stored.Headers {
+					w.Header()[name] = values
+				}
+				w.Header().Set(ReplayedHeaderName, "true")
+				w.WriteHeader(stored.StatusCode)
+				w.Write(stored.Response)
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r, ps)
+		if recorder.status >= 200 && recorder.status < 300 {
+			err = saveResponse(userId, key, recorder.status, recorder.body.Bytes(), recorder.header)
+		} else {
+			err = releaseKey(userId, key)
+		}
+		if err != nil {
+			log.Println("idempotency key:", err)
+		}
+	}
+}
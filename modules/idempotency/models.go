@@ -0,0 +1,25 @@
+package idempotency
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HeaderName is the header carrying the key chosen by the client for a creating request
+const HeaderName = "Idempotency-Key"
+
+// ReplayedHeaderName is set on the responses replayed from a stored key
+const ReplayedHeaderName = "Idempotent-Replayed"
+
+// StoredKey is a key claimed by a request, StatusCode is 0 until its response is stored
+type StoredKey struct {
+	UserId      uuid.UUID
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Response    []byte
+	CreatedAt   time.Time
+	Headers     http.Header
+}
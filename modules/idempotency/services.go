@@ -0,0 +1,81 @@
+package idempotency
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/config"
+)
+
+// scanner is implemented by sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanStoredKey(row scanner) (StoredKey, error) {
+	k := StoredKey{}
+	var headers sql.NullString
+	err := row.Scan(&k.UserId, &k.Key, &k.RequestHash, &k.StatusCode, &k.Response, &k.CreatedAt, &headers)
+	if err != nil || !headers.Valid {
+		return k, err
+	}
+	err = json.Unmarshal([]byte(headers.String), &k.Headers)
+	return k, err
+}
+
+// claimKey stores the key for the request when it is new or expired and returns true, otherwise it returns
+// the key stored by the previous request. Expired keys of every user are removed on the way
+func claimKey(user_id uuid.UUID, key string, request_hash string) (StoredKey, bool, error) {
+	_, err := database.DB.Exec("DELETE FROM idempotency_keys WHERE created_at < $1;", time.Now().Add(-config.IdempotencyKeyTTL))
+	if err != nil {
+		return StoredKey{}, false, errors_handler.MapDBErrors(err)
+	}
+
+	row := database.DB.QueryRow("INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING RETURNING *;", user_id, key, request_hash)
+	stored, err := scanStoredKey(row)
+	if err == nil {
+		return stored, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return stored, false, fmt.Errorf(errors_handler.DB007)
+	}
+	// nothing was inserted, the key was already there
+	row = database.DB.QueryRow("SELECT * FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2;", user_id, key)
+	stored, err = scanStoredKey(row)
+	if err != nil {
+		return stored, false, errors_handler.MapDBErrors(err)
+	}
+	return stored, false, nil
+}
+
+// saveResponse stores the response of the request that claimed the key with its headers, so it is replayed to the retries
+func saveResponse(user_id uuid.UUID, key string, status_code int, response []byte, headers http.Header) error {
+	encodedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+	_, err = database.DB.Exec("UPDATE idempotency_keys SET status_code = $1, response = $2, headers = $3 WHERE user_id = $4 AND idempotency_key = $5;", status_code, response, encodedHeaders, user_id, key)
+	if err != nil {
+		return errors_handler.MapDBErrors(err)
+	}
+	return nil
+}
+
+// releaseKey removes the key of a request that failed, so it can be retried with the same key
+func releaseKey(user_id uuid.UUID, key string) error {
+	_, err := database.DB.Exec("DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2;", user_id, key)
+	if err != nil {
+		return errors_handler.MapDBErrors(err)
+	}
+	return nil
+}
+
+func DeleteAllIdempotencyKeys() {
+	database.DB.Exec("DELETE FROM idempotency_keys;")
+}
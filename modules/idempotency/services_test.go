@@ -0,0 +1,83 @@
+package idempotency
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/database"
+	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyServices(t *testing.T) {
+	envPath := filepath.Clean("../../.env_test")
+	sqlPath := filepath.Clean("../../database/database.sql")
+	database.SetupDB(envPath)
+	database.CreateTables(sqlPath)
+	defer database.CloseConnection()
+
+	t.Run("Claim a key, store its response and get it back", func(t *testing.T) {
+		userId := uuid.New()
+		stored, claimed, err := claimKey(userId, "key", "hash")
+		assert.Nil(t, err)
+		assert.True(t, claimed)
+		assert.Equal(t, 0, stored.StatusCode)
+
+		stored, claimed, err = claimKey(userId, "key", "hash")
+		assert.Nil(t, err)
+		assert.False(t, claimed)
+		assert.Equal(t, 0, stored.StatusCode)
+
+		err = saveResponse(userId, "key", 201, []byte(`{"id": 1}`), http.Header{"Content-Type": {"application/json"}})
+		assert.Nil(t, err)
+		stored, claimed, err = claimKey(userId, "key", "other hash")
+		assert.Nil(t, err)
+		assert.False(t, claimed)
+		assert.Equal(t, "hash", stored.RequestHash)
+		assert.Equal(t, 201, stored.StatusCode)
+		assert.Equal(t, []byte(`{"id": 1}`), stored.Response)
+		assert.Equal(t, "application/json", stored.Headers.Get("Content-Type"))
+
+		// the same key of another user is a different key
+		_, claimed, err = claimKey(uuid.New(), "key", "hash")
+		assert.Nil(t, err)
+		assert.True(t, claimed)
+	})
+
+	DeleteAllIdempotencyKeys()
+
+	t.Run("Claim again a released key", func(t *testing.T) {
+		userId := uuid.New()
+		_, claimed, err := claimKey(userId, "key", "hash")
+		assert.Nil(t, err)
+		assert.True(t, claimed)
+		err = releaseKey(userId, "key")
+		assert.Nil(t, err)
+		_, claimed, err = claimKey(userId, "key", "other hash")
+		assert.Nil(t, err)
+		assert.True(t, claimed)
+	})
+
+	DeleteAllIdempotencyKeys()
+
+	t.Run("Claim again an expired key", func(t *testing.T) {
+		userId := uuid.New()
+		_, claimed, err := claimKey(userId, "key", "hash")
+		assert.Nil(t, err)
+		assert.True(t, claimed)
+		err = saveResponse(userId, "key", 201, []byte(`{}`), http.Header{})
+		assert.Nil(t, err)
+		_, err = database.DB.Exec("UPDATE idempotency_keys SET created_at = $1;", time.Now().Add(-config.IdempotencyKeyTTL-time.Minute))
+		assert.Nil(t, err)
+
+		stored, claimed, err := claimKey(userId, "key", "other hash")
+		assert.Nil(t, err)
+		assert.True(t, claimed)
+		assert.Equal(t, "other hash", stored.RequestHash)
+	})
+
+	DeleteAllIdempotencyKeys()
+}
@@ -1,6 +1,7 @@
 package money_accounts
 
 import (
+	"github.com/grabielcruz/transportation_back/money"
 	"github.com/grabielcruz/transportation_back/utility"
 )
 
@@ -13,7 +14,7 @@ func GenerateAccountFields() MoneyAccountFields {
 	return fields
 }
 
-func GenerateAccountBalace() float64 {
+func GenerateAccountBalace() money.Money {
 	return utility.GetRandomBalance()
 }
 
@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
+	"github.com/grabielcruz/transportation_back/modules/config"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -31,7 +34,7 @@ func CreateMoneyAccountHandler(w http.ResponseWriter, r *http.Request, _ httprou
 		common.SendValidationError(w, err.Error())
 		return
 	}
-	account, err = CreateMoneyAccount(fields)
+	account, err = CreateMoneyAccount(fields, audit_log.ActorId(r))
 	if err != nil {
 		common.SendServiceError(w, err.Error())
 		return
@@ -50,6 +53,7 @@ func GetOneMoneyAccountHandler(w http.ResponseWriter, r *http.Request, ps httpro
 		common.SendServiceError(w, err.Error())
 		return
 	}
+	common.SetETag(w, account.UpdatedAt)
 	common.SendJson(w, http.StatusOK, account)
 }
 
@@ -60,6 +64,11 @@ func UpdateMoneyAccountHandler(w http.ResponseWriter, r *http.Request, ps httpro
 		common.SendInvalidUUIDError(w, err.Error())
 		return
 	}
+	ifMatch, err := common.ParseIfMatch(r)
+	if err != nil {
+		common.SendPreconditionFailedError(w)
+		return
+	}
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		common.SendReadError(w)
@@ -73,11 +82,12 @@ func UpdateMoneyAccountHandler(w http.ResponseWriter, r *http.Request, ps httpro
 		common.SendValidationError(w, err.Error())
 		return
 	}
-	account, err := UpdateMoneyAccount(id, fields)
+	account, err := UpdateMoneyAccount(id, fields, ifMatch, audit_log.ActorId(r))
 	if err != nil {
 		common.SendServiceError(w, err.Error())
 		return
 	}
+	common.SetETag(w, account.UpdatedAt)
 	common.SendJson(w, http.StatusOK, account)
 }
 
@@ -87,10 +97,52 @@ func DeleteOneMoneyAccountHandler(w http.ResponseWriter, r *http.Request, ps htt
 		common.SendInvalidUUIDError(w, err.Error())
 		return
 	}
-	deletedId, err := DeleteOneMoneyAccount(id)
+	ifMatch, err := common.ParseIfMatch(r)
+	if err != nil {
+		common.SendPreconditionFailedError(w)
+		return
+	}
+	deletedId, err := DeleteOneMoneyAccount(id, ifMatch, audit_log.ActorId(r))
 	if err != nil {
 		common.SendServiceError(w, err.Error())
 		return
 	}
 	common.SendJson(w, http.StatusOK, deletedId)
 }
+
+// GetStatementHandler returns the statement of the account between the dates from and to of the query string,
+// from defaults to the zero date and to defaults to today
+func GetStatementHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := uuid.Parse(ps.ByName("id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	from := time.Time{}
+	to := time.Now()
+	if query.Get("from") != "" {
+		from, err = time.Parse(config.DateLayout, query.Get("from"))
+		if err != nil {
+			common.SendInvalidQueryStringError(w, err.Error())
+			return
+		}
+	}
+	if query.Get("to") != "" {
+		to, err = time.Parse(config.DateLayout, query.Get("to"))
+		if err != nil {
+			common.SendInvalidQueryStringError(w, err.Error())
+			return
+		}
+	}
+	if err := checkStatementPeriod(from, to); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	statement, err := GetStatement(id, from, to)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, statement)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/grabielcruz/transportation_back/common"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/config"
 	"github.com/grabielcruz/transportation_back/utility"
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
@@ -64,9 +65,9 @@ func TestMoneyAccountsHandlers(t *testing.T) {
 	DeleteAllMoneyAccounts()
 
 	t.Run("Create three money accounts and get an slice of accounts", func(t *testing.T) {
-		CreateMoneyAccount(GenerateAccountFields())
-		CreateMoneyAccount(GenerateAccountFields())
-		CreateMoneyAccount(GenerateAccountFields())
+		CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
+		CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
+		CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
 		w := httptest.NewRecorder()
 		req, err := http.NewRequest(http.MethodGet, "/money_accounts", nil)
 		assert.Nil(t, err)
@@ -128,7 +129,7 @@ func TestMoneyAccountsHandlers(t *testing.T) {
 
 	t.Run("Create one money account and get it", func(t *testing.T) {
 		fields := GenerateAccountFields()
-		newMoneyAccount, err := CreateMoneyAccount(fields)
+		newMoneyAccount, err := CreateMoneyAccount(fields, uuid.UUID{})
 		assert.Nil(t, err)
 		wantedId := newMoneyAccount.ID
 		w := httptest.NewRecorder()
@@ -199,7 +200,7 @@ func TestMoneyAccountsHandlers(t *testing.T) {
 
 	t.Run("It should create and update one money account", func(t *testing.T) {
 		createFields := GenerateAccountFields()
-		newMoneyAccount, err := CreateMoneyAccount(createFields)
+		newMoneyAccount, err := CreateMoneyAccount(createFields, uuid.UUID{})
 		assert.Nil(t, err)
 		wantedId := newMoneyAccount.ID
 		buf := bytes.Buffer{}
@@ -332,7 +333,7 @@ func TestMoneyAccountsHandlers(t *testing.T) {
 
 	t.Run("It should create an account and delete it", func(t *testing.T) {
 		fields := GenerateAccountFields()
-		newMoneyAccount, err := CreateMoneyAccount(fields)
+		newMoneyAccount, err := CreateMoneyAccount(fields, uuid.UUID{})
 		assert.Nil(t, err)
 		newId := newMoneyAccount.ID
 
@@ -414,4 +415,52 @@ func TestMoneyAccountsHandlers(t *testing.T) {
 		assert.Equal(t, errors_handler.DB001, errResponse2.Error)
 		assert.Equal(t, "DB001", errResponse2.Code)
 	})
+
+	t.Run("Get statement of one account", func(t *testing.T) {
+		newMoneyAccount, err := CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
+		assert.Nil(t, err)
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/money_accounts/"+newMoneyAccount.ID.String()+"/statement?from=2022-01-01&to=2022-01-31", nil)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		statement := Statement{}
+		err = json.Unmarshal(w.Body.Bytes(), &statement)
+		assert.Nil(t, err)
+		assert.Equal(t, newMoneyAccount.ID, statement.AccountId)
+		assert.Equal(t, "2022-01-01", statement.From.Format(config.DateLayout))
+		assert.Equal(t, "2022-01-31", statement.To.Format(config.DateLayout))
+		assert.Len(t, statement.Movements, 0)
+	})
+
+	t.Run("Error when getting statement with bad dates", func(t *testing.T) {
+		newMoneyAccount, err := CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
+		assert.Nil(t, err)
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/money_accounts/"+newMoneyAccount.ID.String()+"/statement?from=01-01-2022", nil)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "QS001", errResponse.Code)
+
+		w2 := httptest.NewRecorder()
+		req2, err := http.NewRequest(http.MethodGet, "/money_accounts/"+newMoneyAccount.ID.String()+"/statement?from=2022-02-01&to=2022-01-01", nil)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusBadRequest, w2.Code)
+		errResponse2 := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w2.Body.Bytes(), &errResponse2)
+		assert.Nil(t, err)
+		assert.Equal(t, "From date should not be after to date", errResponse2.Error)
+		assert.Equal(t, "VA001", errResponse2.Code)
+	})
+
+	DeleteAllMoneyAccounts()
 }
@@ -1,14 +1,20 @@
 package money_accounts
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/money"
 )
 
 type MoneyAccount struct {
 	ID uuid.UUID `json:"id"`
 	MoneyAccountFields
-	Balance float64 `json:"balance"`
+	Balance money.Money `json:"balance"`
 	common.Timestamps
 }
 
@@ -16,6 +22,37 @@ type MoneyAccountFields struct {
 	Name     string `json:"name"`
 	Currency string `json:"currency"`
 	Details  string `json:"details"`
+	// applied to transactions registered without an explicit fee, nil means no fee
+	FeeRule *FeeRule `json:"fee_rule"`
+	// how far below zero the balance can go, zero means the balance can not be negative
+	CreditLimit money.Money `json:"credit_limit"`
+}
+
+// kinds of fee rules
+const (
+	FixedFee      = "FIXED"
+	PercentageFee = "PERCENTAGE"
+	TieredFee     = "TIERED"
+)
+
+// FeeRule describes how the fee of a transaction is computed from its amount, the fee always has the sign of the amount.
+// FIXED charges Amount, PERCENTAGE charges Rate of the amount limited by Min and Max when they are not zero,
+// TIERED uses the first tier whose UpTo is not less than the amount
+type FeeRule struct {
+	Kind   string      `json:"kind"`
+	Amount money.Money `json:"amount"`
+	// fraction of the amount, between 0 and 1
+	Rate  money.Rate  `json:"rate"`
+	Min   money.Money `json:"min"`
+	Max   money.Money `json:"max"`
+	Tiers []FeeTier   `json:"tiers"`
+}
+
+// FeeTier charges Amount plus Rate of the amount for amounts up to UpTo, zero UpTo means no upper limit
+type FeeTier struct {
+	UpTo   money.Money `json:"up_to"`
+	Amount money.Money `json:"amount"`
+	Rate   money.Rate  `json:"rate"`
 }
 
 type badAccountFields struct {
@@ -25,7 +62,55 @@ type badAccountFields struct {
 }
 
 type AccountNameAndBalance struct {
-	ID      uuid.UUID `json:"id"`
-	Name    string    `json:"name"`
-	Balance float64   `json:"balance"`
+	ID      uuid.UUID   `json:"id"`
+	Name    string      `json:"name"`
+	Balance money.Money `json:"balance"`
+}
+
+// Statement lists the movements of an account dated between From and To, both included.
+// ClosingBalance = OpeningBalance + TotalCredits - TotalDebits + TotalFees
+type Statement struct {
+	AccountId      uuid.UUID           `json:"account_id"`
+	Currency       string              `json:"currency"`
+	From           time.Time           `json:"from"`
+	To             time.Time           `json:"to"`
+	OpeningBalance money.Money         `json:"opening_balance"`
+	Movements      []StatementMovement `json:"movements"`
+	// sum of the positive amounts
+	TotalCredits money.Money `json:"total_credits"`
+	// sum of the negative amounts, as a positive number
+	TotalDebits money.Money `json:"total_debits"`
+	// sum of the fees, a fee has the sign of its amount
+	TotalFees      money.Money `json:"total_fees"`
+	ClosingBalance money.Money `json:"closing_balance"`
+}
+
+type StatementMovement struct {
+	TransactionId uuid.UUID   `json:"transaction_id"`
+	Date          time.Time   `json:"date"`
+	Description   string      `json:"description"`
+	PersonId      uuid.UUID   `json:"person_id"`
+	PersonName    string      `json:"person_name"`
+	Amount        money.Money `json:"amount"`
+	Fee           money.Money `json:"fee"`
+	AmountWithFee money.Money `json:"amount_with_fee"`
+	// running balance of the statement, it follows the date of the movements
+	Balance   money.Money `json:"balance"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Scan implements sql.Scanner, fee rules are stored as JSONB
+func (r *FeeRule) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	}
+	return fmt.Errorf("Can not scan %T into fee rule", src)
+}
+
+// Value implements driver.Valuer
+func (r FeeRule) Value() (driver.Value, error) {
+	return json.Marshal(r)
 }
@@ -1,13 +1,15 @@
 package money_accounts
 
 import (
+	"github.com/grabielcruz/transportation_back/modules/idempotency"
 	"github.com/julienschmidt/httprouter"
 )
 
 func Routes(router *httprouter.Router) {
 	router.GET("/money_accounts", GetMoneyAccountsHandler)
 	router.GET("/money_accounts/:id", GetOneMoneyAccountHandler)
-	router.POST("/money_accounts", CreateMoneyAccountHandler)
+	router.POST("/money_accounts", idempotency.Handle(CreateMoneyAccountHandler))
 	router.PATCH("/money_accounts/:id", UpdateMoneyAccountHandler)
 	router.DELETE("/money_accounts/:id", DeleteOneMoneyAccountHandler)
+	router.GET("/money_accounts/:id/statement", GetStatementHandler)
 }
@@ -1,6 +1,7 @@
 package money_accounts
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 
@@ -8,6 +9,9 @@ import (
 	"github.com/grabielcruz/transportation_back/common"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
+	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/grabielcruz/transportation_back/money"
 )
 
 func GetMoneyAccounts() []MoneyAccount {
@@ -21,7 +25,7 @@ func GetMoneyAccounts() []MoneyAccount {
 
 	for rows.Next() {
 		var ma MoneyAccount
-		err = rows.Scan(&ma.ID, &ma.Name, &ma.Balance, &ma.Details, &ma.Currency, &ma.CreatedAt, &ma.UpdatedAt)
+		err = rows.Scan(&ma.ID, &ma.Name, &ma.Balance, &ma.Details, &ma.Currency, &ma.CreatedAt, &ma.UpdatedAt, &ma.FeeRule, &ma.CreditLimit)
 		if err != nil {
 			errors_handler.HandleError(err)
 		}
@@ -33,15 +37,30 @@ func GetMoneyAccounts() []MoneyAccount {
 	return moneyAccounts
 }
 
-func CreateMoneyAccount(fields MoneyAccountFields) (MoneyAccount, error) {
+func CreateMoneyAccount(fields MoneyAccountFields, actor_id uuid.UUID) (MoneyAccount, error) {
 	var nma MoneyAccount
-	row := database.DB.QueryRow(
-		"INSERT INTO money_accounts (name, details, currency) VALUES ($1, $2, $3) RETURNING *;",
-		fields.Name, fields.Details, fields.Currency)
-	err := row.Scan(&nma.ID, &nma.Name, &nma.Balance, &nma.Details, &nma.Currency, &nma.CreatedAt, &nma.UpdatedAt)
+	tx, err := database.DB.Begin()
 	if err != nil {
+		tx.Rollback()
+		return nma, fmt.Errorf(errors_handler.DB002)
+	}
+	row := tx.QueryRow(
+		"INSERT INTO money_accounts (name, details, currency, fee_rule, credit_limit) VALUES ($1, $2, $3, $4, $5) RETURNING *;",
+		fields.Name, fields.Details, fields.Currency, fields.FeeRule, fields.CreditLimit)
+	err = row.Scan(&nma.ID, &nma.Name, &nma.Balance, &nma.Details, &nma.Currency, &nma.CreatedAt, &nma.UpdatedAt, &nma.FeeRule, &nma.CreditLimit)
+	if err != nil {
+		tx.Rollback()
 		return nma, errors_handler.MapDBErrors(err)
 	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.MoneyAccountEntity, nma.ID.String(), audit_log.CreateAction, nil, nma)
+	if err != nil {
+		tx.Rollback()
+		return nma, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nma, fmt.Errorf(errors_handler.DB003)
+	}
 	return nma, nil
 }
 
@@ -51,7 +70,7 @@ func GetOneMoneyAccount(account_id uuid.UUID) (MoneyAccount, error) {
 		return ma, fmt.Errorf(errors_handler.DB001)
 	}
 	row := database.DB.QueryRow("SELECT * FROM money_accounts WHERE id = $1;", account_id)
-	err := row.Scan(&ma.ID, &ma.Name, &ma.Balance, &ma.Details, &ma.Currency, &ma.CreatedAt, &ma.UpdatedAt)
+	err := row.Scan(&ma.ID, &ma.Name, &ma.Balance, &ma.Details, &ma.Currency, &ma.CreatedAt, &ma.UpdatedAt, &ma.FeeRule, &ma.CreditLimit)
 	if err != nil {
 		return ma, errors_handler.MapDBErrors(err)
 	}
@@ -71,21 +90,88 @@ func GetAccountsCurrency(account_id uuid.UUID) (string, error) {
 	return currency, nil
 }
 
-func UpdateMoneyAccount(account_id uuid.UUID, fields MoneyAccountFields) (MoneyAccount, error) {
+// UpdateMoneyAccount overwrites the account but its balance and currency, when if_match is not zero
+// it fails with PC001 unless the account was last updated at if_match. Changes of the balance
+// do not change updated_at
+func UpdateMoneyAccount(account_id uuid.UUID, fields MoneyAccountFields, if_match time.Time, actor_id uuid.UUID) (MoneyAccount, error) {
 	var uma MoneyAccount
 	if account_id == (uuid.UUID{}) {
 		return uma, fmt.Errorf(errors_handler.DB001)
 	}
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return uma, fmt.Errorf(errors_handler.DB002)
+	}
+	// the lock keeps the balance from changing until the new credit limit is written
+	ma, err := lockMoneyAccount(tx, account_id)
+	if err != nil {
+		tx.Rollback()
+		return uma, err
+	}
+	if !if_match.IsZero() && !ma.UpdatedAt.Equal(if_match) {
+		tx.Rollback()
+		return uma, fmt.Errorf(errors_handler.PC001)
+	}
+	if ma.Balance < -fields.CreditLimit {
+		tx.Rollback()
+		return uma, fmt.Errorf(errors_handler.MA002)
+	}
 	// should not update currency
-	row := database.DB.QueryRow("UPDATE money_accounts SET name = $1, details = $2, updated_at = $3 WHERE id = $4 RETURNING *;",
-		fields.Name, fields.Details, time.Now(), account_id)
-	err := row.Scan(&uma.ID, &uma.Name, &uma.Balance, &uma.Details, &uma.Currency, &uma.CreatedAt, &uma.UpdatedAt)
+	row := tx.QueryRow("UPDATE money_accounts SET name = $1, details = $2, fee_rule = $3, credit_limit = $4, updated_at = $5 WHERE id = $6 RETURNING *;",
+		fields.Name, fields.Details, fields.FeeRule, fields.CreditLimit, time.Now(), account_id)
+	err = row.Scan(&uma.ID, &uma.Name, &uma.Balance, &uma.Details, &uma.Currency, &uma.CreatedAt, &uma.UpdatedAt, &uma.FeeRule, &uma.CreditLimit)
 	if err != nil {
+		tx.Rollback()
 		return uma, errors_handler.MapDBErrors(err)
 	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.MoneyAccountEntity, account_id.String(), audit_log.UpdateAction, ma, uma)
+	if err != nil {
+		tx.Rollback()
+		return uma, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return uma, fmt.Errorf(errors_handler.DB003)
+	}
 	return uma, nil
 }
 
+// Fee returns the fee the rule charges on amount, with the sign of amount
+func (r FeeRule) Fee(amount money.Money) money.Money {
+	abs := amount.Abs()
+	fee := money.Zero
+	switch r.Kind {
+	case FixedFee:
+		fee = r.Amount
+	case PercentageFee:
+		fee = abs.MulRate(r.Rate, config.FeeRounding)
+		if fee < r.Min {
+			fee = r.Min
+		}
+		if r.Max > 0 && fee > r.Max {
+			fee = r.Max
+		}
+	case TieredFee:
+		if len(r.Tiers) == 0 {
+			break
+		}
+		// amounts above every limit use the last tier
+		tier := r.Tiers[len(r.Tiers)-1]
+		for _, t := range r.Tiers {
+			if t.UpTo == 0 || abs <= t.UpTo {
+				tier = t
+				break
+			}
+		}
+		fee = tier.Amount + abs.MulRate(tier.Rate, config.FeeRounding)
+	}
+	if amount < 0 {
+		return -fee
+	}
+	return fee
+}
+
 func getAccountsName(account_id uuid.UUID) (string, error) {
 	name := ""
 	if account_id == (uuid.UUID{}) {
@@ -99,27 +185,144 @@ func getAccountsName(account_id uuid.UUID) (string, error) {
 	return name, nil
 }
 
-func DeleteOneMoneyAccount(account_id uuid.UUID) (common.ID, error) {
+// DeleteOneMoneyAccount removes the account, when if_match is not zero it fails with PC001 unless
+// the account was last updated at if_match
+func DeleteOneMoneyAccount(account_id uuid.UUID, if_match time.Time, actor_id uuid.UUID) (common.ID, error) {
 	id := common.ID{}
 	if account_id == (uuid.UUID{}) {
 		return id, fmt.Errorf(errors_handler.DB001)
 	}
-	row := database.DB.QueryRow("DELETE FROM money_accounts WHERE id = $1 RETURNING id;", account_id)
-	err := row.Scan(&id.ID)
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return id, fmt.Errorf(errors_handler.DB002)
+	}
+	before, err := lockMoneyAccount(tx, account_id)
 	if err != nil {
+		tx.Rollback()
+		return id, err
+	}
+	if !if_match.IsZero() && !before.UpdatedAt.Equal(if_match) {
+		tx.Rollback()
+		return id, fmt.Errorf(errors_handler.PC001)
+	}
+	row := tx.QueryRow("DELETE FROM money_accounts WHERE id = $1 RETURNING id;", account_id)
+	err = row.Scan(&id.ID)
+	if err != nil {
+		tx.Rollback()
 		return id, errors_handler.MapDBErrors(err)
 	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.MoneyAccountEntity, account_id.String(), audit_log.DeleteAction, before, nil)
+	if err != nil {
+		tx.Rollback()
+		return id, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return id, fmt.Errorf(errors_handler.DB003)
+	}
 	return id, nil
 }
 
+// lockMoneyAccount reads the account inside tx and locks it until tx ends
+func lockMoneyAccount(tx *sql.Tx, account_id uuid.UUID) (MoneyAccount, error) {
+	var ma MoneyAccount
+	row := tx.QueryRow("SELECT * FROM money_accounts WHERE id = $1 FOR UPDATE;", account_id)
+	err := row.Scan(&ma.ID, &ma.Name, &ma.Balance, &ma.Details, &ma.Currency, &ma.CreatedAt, &ma.UpdatedAt, &ma.FeeRule, &ma.CreditLimit)
+	if err != nil {
+		return ma, errors_handler.MapDBErrors(err)
+	}
+	return ma, nil
+}
+
+// GetStatement returns the movements of the account dated from the beginning of from to the end of to.
+// Movements are sorted by date and then by the order in which they were registered, so a back-dated
+// transaction shows where its date puts it and the running balance is computed in that order.
+// The stored balance column is chained in registration order, it is checked over the whole account history
+func GetStatement(account_id uuid.UUID, from time.Time, to time.Time) (Statement, error) {
+	st := Statement{AccountId: account_id, From: from, To: to, Movements: []StatementMovement{}}
+	if account_id == (uuid.UUID{}) {
+		return st, fmt.Errorf(errors_handler.DB001)
+	}
+	// to includes the whole day
+	until := to.AddDate(0, 0, 1)
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return st, fmt.Errorf(errors_handler.DB002)
+	}
+
+	row := tx.QueryRow("SELECT currency FROM money_accounts WHERE id = $1;", account_id)
+	err = row.Scan(&st.Currency)
+	if err != nil {
+		tx.Rollback()
+		return st, errors_handler.MapDBErrors(err)
+	}
+
+	mismatches := 0
+	row = tx.QueryRow("SELECT COUNT(*) FROM (SELECT balance, SUM(amount_with_fee) OVER (ORDER BY created_at) AS running FROM transactions WHERE account_id = $1) AS chain WHERE balance <> running;", account_id)
+	err = row.Scan(&mismatches)
+	if err != nil {
+		tx.Rollback()
+		return st, fmt.Errorf(errors_handler.DB005)
+	}
+	if mismatches > 0 {
+		tx.Rollback()
+		return st, fmt.Errorf(errors_handler.MA001)
+	}
+
+	row = tx.QueryRow("SELECT COALESCE(SUM(amount_with_fee), 0) FROM transactions WHERE account_id = $1 AND date < $2;", account_id, from)
+	err = row.Scan(&st.OpeningBalance)
+	if err != nil {
+		tx.Rollback()
+		return st, fmt.Errorf(errors_handler.DB005)
+	}
+
+	rows, err := tx.Query("SELECT t.id, t.date, t.description, t.person_id, p.name, t.amount, t.amount_with_fee, t.created_at FROM transactions t JOIN persons p ON p.id = t.person_id WHERE t.account_id = $1 AND t.date >= $2 AND t.date < $3 ORDER BY t.date, t.created_at;", account_id, from, until)
+	if err != nil {
+		tx.Rollback()
+		return st, fmt.Errorf(errors_handler.DB005)
+	}
+	defer rows.Close()
+
+	balance := st.OpeningBalance
+	for rows.Next() {
+		m := StatementMovement{}
+		err = rows.Scan(&m.TransactionId, &m.Date, &m.Description, &m.PersonId, &m.PersonName, &m.Amount, &m.AmountWithFee, &m.CreatedAt)
+		if err != nil {
+			tx.Rollback()
+			return st, fmt.Errorf(errors_handler.DB005)
+		}
+		m.Fee = m.AmountWithFee - m.Amount
+		if m.Amount > 0 {
+			st.TotalCredits += m.Amount
+		} else {
+			st.TotalDebits -= m.Amount
+		}
+		st.TotalFees += m.Fee
+
+		balance += m.AmountWithFee
+		m.Balance = balance
+		st.Movements = append(st.Movements, m)
+	}
+	st.ClosingBalance = balance
+
+	err = tx.Commit()
+	if err != nil {
+		return st, fmt.Errorf(errors_handler.DB003)
+	}
+	return st, nil
+}
+
 // ResetAccountsBalance sets the accounts with the specify id to zero
 func ResetAccountsBalance(account_id uuid.UUID) (common.ID, error) {
-	newBalance := float64(0)
+	newBalance := money.Zero
 	id, err := setAccountsBalance(account_id, newBalance)
 	return id, err
 }
 
-func setAccountsBalance(account_id uuid.UUID, balance float64) (common.ID, error) {
+func setAccountsBalance(account_id uuid.UUID, balance money.Money) (common.ID, error) {
 	id := common.ID{}
 	if account_id == (uuid.UUID{}) {
 		return id, fmt.Errorf(errors_handler.DB001)
@@ -3,14 +3,39 @@ package money_accounts
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/money"
 	"github.com/grabielcruz/transportation_back/utility"
 	"github.com/stretchr/testify/assert"
 )
 
+func TestFeeRuleFee(t *testing.T) {
+	fixed := FeeRule{Kind: FixedFee, Amount: money.MustParse("1.5")}
+	assert.Equal(t, money.MustParse("1.5"), fixed.Fee(money.FromUnits(200)))
+	assert.Equal(t, money.MustParse("-1.5"), fixed.Fee(money.FromUnits(-200)))
+
+	percentage := FeeRule{Kind: PercentageFee, Rate: money.MustParseRate("0.015")}
+	assert.Equal(t, money.MustParse("0.15"), percentage.Fee(money.MustParse("10.33")))
+	percentage.Min = money.FromUnits(1)
+	percentage.Max = money.FromUnits(5)
+	assert.Equal(t, money.FromUnits(1), percentage.Fee(money.FromUnits(10)))
+	assert.Equal(t, money.MustParse("3"), percentage.Fee(money.FromUnits(200)))
+	assert.Equal(t, money.FromUnits(-5), percentage.Fee(money.FromUnits(-1000)))
+
+	tiered := FeeRule{Kind: TieredFee, Tiers: []FeeTier{
+		{UpTo: money.FromUnits(100), Amount: money.FromUnits(1)},
+		{UpTo: money.FromUnits(1000), Amount: money.FromUnits(2), Rate: money.MustParseRate("0.01")},
+	}}
+	assert.Equal(t, money.FromUnits(1), tiered.Fee(money.FromUnits(100)))
+	assert.Equal(t, money.FromUnits(7), tiered.Fee(money.FromUnits(500)))
+	// above every limit the last tier is used
+	assert.Equal(t, money.FromUnits(22), tiered.Fee(money.FromUnits(2000)))
+}
+
 // TestMoneyAccountServices contains a group of test related
 // to the crud of moneyAccount
 func TestMoneyAccountServices(t *testing.T) {
@@ -27,19 +52,19 @@ func TestMoneyAccountServices(t *testing.T) {
 
 	t.Run("Create one money account", func(t *testing.T) {
 		accountFields := GenerateAccountFields()
-		createdMoneyAccount, err := CreateMoneyAccount(accountFields)
+		createdMoneyAccount, err := CreateMoneyAccount(accountFields, uuid.UUID{})
 		assert.Nil(t, err)
 		assert.Equal(t, accountFields.Name, createdMoneyAccount.Name)
 		assert.Equal(t, accountFields.Details, createdMoneyAccount.Details)
 		assert.Equal(t, accountFields.Currency, createdMoneyAccount.Currency)
-		assert.Equal(t, createdMoneyAccount.Balance, float64(0))
+		assert.Equal(t, createdMoneyAccount.Balance, money.Zero)
 	})
 
 	DeleteAllMoneyAccounts()
 
 	t.Run("Create two money accounts and get an slice of accounts", func(t *testing.T) {
-		CreateMoneyAccount(GenerateAccountFields())
-		CreateMoneyAccount(GenerateAccountFields())
+		CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
+		CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
 		moneyAccounts := GetMoneyAccounts()
 		assert.Len(t, moneyAccounts, 2)
 	})
@@ -47,7 +72,7 @@ func TestMoneyAccountServices(t *testing.T) {
 	DeleteAllMoneyAccounts()
 
 	t.Run("Create one money account and get it", func(t *testing.T) {
-		createdMoneyAccount, err := CreateMoneyAccount(GenerateAccountFields())
+		createdMoneyAccount, err := CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
 		assert.Nil(t, err)
 		obtainedMoneyAccount, err := GetOneMoneyAccount(createdMoneyAccount.ID)
 		assert.Nil(t, err)
@@ -72,9 +97,9 @@ func TestMoneyAccountServices(t *testing.T) {
 	})
 
 	t.Run("Create one money account and delete it", func(t *testing.T) {
-		createdMoneyAccount, err := CreateMoneyAccount(GenerateAccountFields())
+		createdMoneyAccount, err := CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
 		assert.Nil(t, err)
-		deletedId, err := DeleteOneMoneyAccount(createdMoneyAccount.ID)
+		deletedId, err := DeleteOneMoneyAccount(createdMoneyAccount.ID, time.Time{}, uuid.UUID{})
 		assert.Nil(t, err)
 		assert.Equal(t, createdMoneyAccount.ID, deletedId.ID)
 		_, err = GetOneMoneyAccount(createdMoneyAccount.ID)
@@ -87,22 +112,22 @@ func TestMoneyAccountServices(t *testing.T) {
 	t.Run("Error when attempting to delete an unexisting account", func(t *testing.T) {
 		// with zero uuid
 		zeroUUID := uuid.UUID{}
-		_, err := DeleteOneMoneyAccount(zeroUUID)
+		_, err := DeleteOneMoneyAccount(zeroUUID, time.Time{}, uuid.UUID{})
 		assert.NotNil(t, err)
 
 		// with random uuid
 		randomUUID, err := uuid.NewRandom()
 		assert.Nil(t, err)
-		_, err = DeleteOneMoneyAccount(randomUUID)
+		_, err = DeleteOneMoneyAccount(randomUUID, time.Time{}, uuid.UUID{})
 		assert.NotNil(t, err)
 	})
 
 	t.Run("It should create and update one money account", func(t *testing.T) {
 		createFields := GenerateAccountFields()
 		updateFields := GenerateAccountFields()
-		createdAccount, err := CreateMoneyAccount(createFields)
+		createdAccount, err := CreateMoneyAccount(createFields, uuid.UUID{})
 		assert.Nil(t, err)
-		updatedAccount, err := UpdateMoneyAccount(createdAccount.ID, updateFields)
+		updatedAccount, err := UpdateMoneyAccount(createdAccount.ID, updateFields, time.Time{}, uuid.UUID{})
 		assert.Nil(t, err)
 		assert.Equal(t, updatedAccount.ID, createdAccount.ID)
 		assert.Equal(t, updateFields.Name, updatedAccount.Name)
@@ -113,22 +138,42 @@ func TestMoneyAccountServices(t *testing.T) {
 
 	DeleteAllMoneyAccounts()
 
+	t.Run("Error when updating or deleting an account modified since it was read", func(t *testing.T) {
+		createdAccount, err := CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
+		assert.Nil(t, err)
+		updatedAccount, err := UpdateMoneyAccount(createdAccount.ID, GenerateAccountFields(), createdAccount.UpdatedAt, uuid.UUID{})
+		assert.Nil(t, err)
+
+		_, err = UpdateMoneyAccount(createdAccount.ID, GenerateAccountFields(), createdAccount.UpdatedAt, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.PC001, err.Error())
+		_, err = DeleteOneMoneyAccount(createdAccount.ID, createdAccount.UpdatedAt, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.PC001, err.Error())
+
+		id, err := DeleteOneMoneyAccount(createdAccount.ID, updatedAccount.UpdatedAt, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, createdAccount.ID, id.ID)
+	})
+
+	DeleteAllMoneyAccounts()
+
 	t.Run("It should generate error when trying to update an unexisting account", func(t *testing.T) {
 		// with zero uuid
 		zeroUUID := uuid.UUID{}
 		zeroFields := MoneyAccountFields{}
-		_, err := UpdateMoneyAccount(zeroUUID, zeroFields)
+		_, err := UpdateMoneyAccount(zeroUUID, zeroFields, time.Time{}, uuid.UUID{})
 		assert.NotNil(t, err)
 
 		// with random uuid
 		randId, err := uuid.NewRandom()
 		assert.Nil(t, err)
-		_, err = UpdateMoneyAccount(randId, zeroFields)
+		_, err = UpdateMoneyAccount(randId, zeroFields, time.Time{}, uuid.UUID{})
 		assert.NotNil(t, err)
 	})
 
 	t.Run("Create one money account and get its name", func(t *testing.T) {
-		newMoneyAccount, err := CreateMoneyAccount(GenerateAccountFields())
+		newMoneyAccount, err := CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
 		assert.Nil(t, err)
 		name, err := getAccountsName(newMoneyAccount.ID)
 		assert.Nil(t, err)
@@ -152,7 +197,7 @@ func TestMoneyAccountServices(t *testing.T) {
 	})
 
 	t.Run("Set accounts balance", func(t *testing.T) {
-		newMoneyAccount, err := CreateMoneyAccount(GenerateAccountFields())
+		newMoneyAccount, err := CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
 		assert.Nil(t, err)
 		newBalance := utility.GetRandomBalance()
 		updatedId, err := setAccountsBalance(newMoneyAccount.ID, newBalance)
@@ -182,14 +227,14 @@ func TestMoneyAccountServices(t *testing.T) {
 	})
 
 	t.Run("Reset accounts balance", func(t *testing.T) {
-		newMoneyAccount, err := CreateMoneyAccount(GenerateAccountFields())
+		newMoneyAccount, err := CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
 		assert.Nil(t, err)
 		updatedId, err := ResetAccountsBalance(newMoneyAccount.ID)
 		assert.Nil(t, err)
 		assert.Equal(t, newMoneyAccount.ID, updatedId.ID)
 		updatedAccount, err := GetOneMoneyAccount(newMoneyAccount.ID)
 		assert.Nil(t, err)
-		assert.Equal(t, float64(0), updatedAccount.Balance)
+		assert.Equal(t, money.Zero, updatedAccount.Balance)
 	})
 
 	DeleteAllMoneyAccounts()
@@ -209,4 +254,50 @@ func TestMoneyAccountServices(t *testing.T) {
 		assert.Equal(t, errors_handler.DB001, err.Error())
 	})
 
+	t.Run("Get statement of an account without transactions", func(t *testing.T) {
+		newMoneyAccount, err := CreateMoneyAccount(GenerateAccountFields(), uuid.UUID{})
+		assert.Nil(t, err)
+		statement, err := GetStatement(newMoneyAccount.ID, time.Time{}, time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, newMoneyAccount.ID, statement.AccountId)
+		assert.Equal(t, newMoneyAccount.Currency, statement.Currency)
+		assert.Len(t, statement.Movements, 0)
+		assert.Equal(t, money.Zero, statement.OpeningBalance)
+		assert.Equal(t, money.Zero, statement.ClosingBalance)
+	})
+
+	DeleteAllMoneyAccounts()
+
+	t.Run("Error when getting statement of unexisting account", func(t *testing.T) {
+		// with zero uuid
+		_, err := GetStatement(uuid.UUID{}, time.Time{}, time.Now())
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+
+		// with random uuid
+		randId, err := uuid.NewRandom()
+		assert.Nil(t, err)
+		_, err = GetStatement(randId, time.Time{}, time.Now())
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+	})
+
+	t.Run("Create money account with a fee rule and update it", func(t *testing.T) {
+		fields := GenerateAccountFields()
+		fields.FeeRule = &FeeRule{Kind: PercentageFee, Rate: money.MustParseRate("0.01"), Min: money.FromUnits(1)}
+		newMoneyAccount, err := CreateMoneyAccount(fields, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, fields.FeeRule, newMoneyAccount.FeeRule)
+
+		sameAccount, err := GetOneMoneyAccount(newMoneyAccount.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, fields.FeeRule, sameAccount.FeeRule)
+
+		fields.FeeRule = nil
+		updatedAccount, err := UpdateMoneyAccount(newMoneyAccount.ID, fields, time.Time{}, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Nil(t, updatedAccount.FeeRule)
+	})
+
+	DeleteAllMoneyAccounts()
 }
@@ -1,6 +1,11 @@
 package money_accounts
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+
+	"github.com/grabielcruz/transportation_back/money"
+)
 
 func checkAccountFields(fields MoneyAccountFields) error {
 	if fields.Name == "" {
@@ -9,5 +14,56 @@ func checkAccountFields(fields MoneyAccountFields) error {
 	if fields.Currency == "" {
 		return fmt.Errorf("Currency is required")
 	}
+	if fields.CreditLimit < 0 {
+		return fmt.Errorf("Credit limit should not be negative")
+	}
+	if fields.FeeRule != nil {
+		return checkFeeRule(*fields.FeeRule)
+	}
+	return nil
+}
+
+func checkFeeRule(rule FeeRule) error {
+	switch rule.Kind {
+	case FixedFee:
+		if rule.Amount < 0 {
+			return fmt.Errorf("Fixed fee should not be negative")
+		}
+	case PercentageFee:
+		if rule.Rate.Sign() < 0 || rule.Rate.Cmp(money.OneRate) > 0 {
+			return fmt.Errorf("Fee rate should be between 0 and 1")
+		}
+		if rule.Min < 0 || rule.Max < 0 {
+			return fmt.Errorf("Fee limits should not be negative")
+		}
+		if rule.Max > 0 && rule.Max < rule.Min {
+			return fmt.Errorf("Maximum fee should not be less than the minimum")
+		}
+	case TieredFee:
+		if len(rule.Tiers) == 0 {
+			return fmt.Errorf("Tiered fee should have at least one tier")
+		}
+		for i, tier := range rule.Tiers {
+			if tier.Rate.Sign() < 0 || tier.Rate.Cmp(money.OneRate) > 0 {
+				return fmt.Errorf("Fee rate should be between 0 and 1")
+			}
+			if tier.Amount < 0 || tier.UpTo < 0 {
+				return fmt.Errorf("Fee tiers should not have negative amounts")
+			}
+			last := i == len(rule.Tiers)-1
+			if (tier.UpTo == 0 && !last) || (i > 0 && tier.UpTo != 0 && tier.UpTo <= rule.Tiers[i-1].UpTo) {
+				return fmt.Errorf("Fee tiers should be sorted by up_to, only the last one can be unlimited")
+			}
+		}
+	default:
+		return fmt.Errorf("Fee rule kind should be FIXED, PERCENTAGE or TIERED")
+	}
+	return nil
+}
+
+func checkStatementPeriod(from time.Time, to time.Time) error {
+	if from.After(to) {
+		return fmt.Errorf("From date should not be after to date")
+	}
 	return nil
 }
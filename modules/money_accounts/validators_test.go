@@ -2,7 +2,9 @@ package money_accounts
 
 import (
 	"testing"
+	"time"
 
+	"github.com/grabielcruz/transportation_back/money"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -13,4 +15,61 @@ func TestCheckAccountFields(t *testing.T) {
 	fields.Name = "John"
 	err = checkAccountFields(fields)
 	assert.Equal(t, "Currency is required", err.Error())
+	fields.Currency = "USD"
+	fields.CreditLimit = money.FromUnits(-1)
+	err = checkAccountFields(fields)
+	assert.Equal(t, "Credit limit should not be negative", err.Error())
+	fields.CreditLimit = money.FromUnits(100)
+	err = checkAccountFields(fields)
+	assert.Nil(t, err)
+}
+
+func TestCheckStatementPeriod(t *testing.T) {
+	today := time.Now()
+	err := checkStatementPeriod(today, today)
+	assert.Nil(t, err)
+	err = checkStatementPeriod(today.AddDate(0, 0, 1), today)
+	assert.Equal(t, "From date should not be after to date", err.Error())
+}
+
+func TestCheckFeeRule(t *testing.T) {
+	rule := FeeRule{Kind: "OTHER"}
+	err := checkFeeRule(rule)
+	assert.Equal(t, "Fee rule kind should be FIXED, PERCENTAGE or TIERED", err.Error())
+
+	rule = FeeRule{Kind: FixedFee, Amount: money.FromUnits(-1)}
+	err = checkFeeRule(rule)
+	assert.Equal(t, "Fixed fee should not be negative", err.Error())
+	rule.Amount = money.FromUnits(1)
+	assert.Nil(t, checkFeeRule(rule))
+
+	rule = FeeRule{Kind: PercentageFee, Rate: money.MustParseRate("1.5")}
+	err = checkFeeRule(rule)
+	assert.Equal(t, "Fee rate should be between 0 and 1", err.Error())
+	rule.Rate = money.MustParseRate("0.01")
+	rule.Min = money.FromUnits(-1)
+	err = checkFeeRule(rule)
+	assert.Equal(t, "Fee limits should not be negative", err.Error())
+	rule.Min = money.FromUnits(5)
+	rule.Max = money.FromUnits(2)
+	err = checkFeeRule(rule)
+	assert.Equal(t, "Maximum fee should not be less than the minimum", err.Error())
+	rule.Max = money.FromUnits(20)
+	assert.Nil(t, checkFeeRule(rule))
+
+	rule = FeeRule{Kind: TieredFee}
+	err = checkFeeRule(rule)
+	assert.Equal(t, "Tiered fee should have at least one tier", err.Error())
+	rule.Tiers = []FeeTier{{UpTo: money.FromUnits(100), Amount: money.FromUnits(1)}, {UpTo: money.FromUnits(50)}}
+	err = checkFeeRule(rule)
+	assert.Equal(t, "Fee tiers should be sorted by up_to, only the last one can be unlimited", err.Error())
+	rule.Tiers = []FeeTier{{Amount: money.FromUnits(1)}, {UpTo: money.FromUnits(50)}}
+	err = checkFeeRule(rule)
+	assert.Equal(t, "Fee tiers should be sorted by up_to, only the last one can be unlimited", err.Error())
+	rule.Tiers = []FeeTier{{UpTo: money.FromUnits(100), Amount: money.FromUnits(1)}, {Rate: money.MustParseRate("0.01")}}
+	assert.Nil(t, checkFeeRule(rule))
+
+	fields := MoneyAccountFields{Name: "John", Currency: "USD", FeeRule: &FeeRule{Kind: "OTHER"}}
+	err = checkAccountFields(fields)
+	assert.Equal(t, "Fee rule kind should be FIXED, PERCENTAGE or TIERED", err.Error())
 }
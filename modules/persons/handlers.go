@@ -7,6 +7,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -31,7 +32,7 @@ func CreatePersonHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 		common.SendValidationError(w, err.Error())
 		return
 	}
-	person, err = CreatePerson(fields)
+	person, err = CreatePerson(fields, audit_log.ActorId(r))
 	if err != nil {
 		common.SendServiceError(w, err.Error())
 		return
@@ -50,6 +51,7 @@ func GetOnePersonHandler(w http.ResponseWriter, r *http.Request, ps httprouter.P
 		common.SendServiceError(w, err.Error())
 		return
 	}
+	common.SetETag(w, person.UpdatedAt)
 	common.SendJson(w, http.StatusCreated, person)
 }
 
@@ -60,6 +62,11 @@ func UpdatePersonHandler(w http.ResponseWriter, r *http.Request, ps httprouter.P
 		common.SendInvalidUUIDError(w, err.Error())
 		return
 	}
+	ifMatch, err := common.ParseIfMatch(r)
+	if err != nil {
+		common.SendPreconditionFailedError(w)
+		return
+	}
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		common.SendReadError(w)
@@ -73,11 +80,12 @@ func UpdatePersonHandler(w http.ResponseWriter, r *http.Request, ps httprouter.P
 		common.SendValidationError(w, err.Error())
 		return
 	}
-	person, err := UpdatePerson(id, fields)
+	person, err := UpdatePerson(id, fields, ifMatch, audit_log.ActorId(r))
 	if err != nil {
 		common.SendServiceError(w, err.Error())
 		return
 	}
+	common.SetETag(w, person.UpdatedAt)
 	common.SendJson(w, http.StatusOK, person)
 }
 
@@ -87,7 +95,12 @@ func DeleteOnePersonHandler(w http.ResponseWriter, r *http.Request, ps httproute
 		common.SendInvalidUUIDError(w, err.Error())
 		return
 	}
-	deletedId, err := DeleteOnePerson(id)
+	ifMatch, err := common.ParseIfMatch(r)
+	if err != nil {
+		common.SendPreconditionFailedError(w)
+		return
+	}
+	deletedId, err := DeleteOnePerson(id, ifMatch, audit_log.ActorId(r))
 	if err != nil {
 		common.SendServiceError(w, err.Error())
 		return
@@ -12,6 +12,7 @@ import (
 	"github.com/grabielcruz/transportation_back/common"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
 	"github.com/grabielcruz/transportation_back/utility"
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
@@ -64,9 +65,9 @@ func TestPersonsHandlers(t *testing.T) {
 	DeleteAllPersons()
 
 	t.Run("Create three persons and get an slice of three persons", func(t *testing.T) {
-		CreatePerson(GeneratePersonFields())
-		CreatePerson(GeneratePersonFields())
-		CreatePerson(GeneratePersonFields())
+		CreatePerson(GeneratePersonFields(), uuid.UUID{})
+		CreatePerson(GeneratePersonFields(), uuid.UUID{})
+		CreatePerson(GeneratePersonFields(), uuid.UUID{})
 		w := httptest.NewRecorder()
 		req, err := http.NewRequest(http.MethodGet, "/persons", nil)
 		assert.Nil(t, err)
@@ -128,7 +129,7 @@ func TestPersonsHandlers(t *testing.T) {
 
 	t.Run("Create one person and get it", func(t *testing.T) {
 		fields := GeneratePersonFields()
-		newPerson, err := CreatePerson(fields)
+		newPerson, err := CreatePerson(fields, uuid.UUID{})
 		assert.Nil(t, err)
 		wantedId := newPerson.ID
 
@@ -144,6 +145,51 @@ func TestPersonsHandlers(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, fields.Name, person.Name)
 		assert.Equal(t, fields.Document, person.Document)
+		assert.Equal(t, common.ETag(newPerson.UpdatedAt), w.Header().Get("ETag"))
+	})
+
+	t.Run("Error when updating or deleting with a stale If-Match", func(t *testing.T) {
+		newPerson, err := CreatePerson(GeneratePersonFields(), uuid.UUID{})
+		assert.Nil(t, err)
+		etag := common.ETag(newPerson.UpdatedAt)
+
+		patch := func(etag string) *httptest.ResponseRecorder {
+			body, err := json.Marshal(GeneratePersonFields())
+			assert.Nil(t, err)
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPatch, "/persons/"+newPerson.ID.String(), bytes.NewReader(body))
+			assert.Nil(t, err)
+			req.Header.Set("If-Match", etag)
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		w := patch(etag)
+		assert.Equal(t, http.StatusOK, w.Code)
+		newEtag := w.Header().Get("ETag")
+		assert.NotEqual(t, etag, newEtag)
+
+		for _, w2 := range []*httptest.ResponseRecorder{patch(etag), patch("not an etag")} {
+			assert.Equal(t, http.StatusPreconditionFailed, w2.Code)
+			var errResponse errors_handler.ErrorResponse
+			err = json.Unmarshal(w2.Body.Bytes(), &errResponse)
+			assert.Nil(t, err)
+			assert.Equal(t, "PC001", errResponse.Code)
+		}
+
+		w3 := httptest.NewRecorder()
+		req3, err := http.NewRequest(http.MethodDelete, "/persons/"+newPerson.ID.String(), nil)
+		assert.Nil(t, err)
+		req3.Header.Set("If-Match", etag)
+		router.ServeHTTP(w3, req3)
+		assert.Equal(t, http.StatusPreconditionFailed, w3.Code)
+
+		w4 := httptest.NewRecorder()
+		req4, err := http.NewRequest(http.MethodDelete, "/persons/"+newPerson.ID.String(), nil)
+		assert.Nil(t, err)
+		req4.Header.Set("If-Match", newEtag)
+		router.ServeHTTP(w4, req4)
+		assert.Equal(t, http.StatusOK, w4.Code)
 	})
 
 	t.Run("Get error when sending bad id", func(t *testing.T) {
@@ -196,7 +242,7 @@ func TestPersonsHandlers(t *testing.T) {
 
 	t.Run("It should create and update one person", func(t *testing.T) {
 		createFields := GeneratePersonFields()
-		newPerson, err := CreatePerson(createFields)
+		newPerson, err := CreatePerson(createFields, uuid.UUID{})
 		assert.Nil(t, err)
 		wantedId := newPerson.ID
 		buf := bytes.Buffer{}
@@ -216,6 +262,19 @@ func TestPersonsHandlers(t *testing.T) {
 		assert.Equal(t, updateFields.Name, updatedPerson.Name)
 		assert.Equal(t, updateFields.Document, updatedPerson.Document)
 		assert.Greater(t, updatedPerson.UpdatedAt, updatedPerson.CreatedAt)
+
+		entries, err := audit_log.GetAuditLog(audit_log.AuditLogFilter{EntityType: audit_log.PersonEntity, EntityId: wantedId.String()}, 10, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, entries.Count)
+		assert.Equal(t, audit_log.UpdateAction, entries.Entries[0].Action)
+		before := Person{}
+		err = json.Unmarshal(entries.Entries[0].Before, &before)
+		assert.Nil(t, err)
+		assert.Equal(t, createFields.Name, before.Name)
+		after := Person{}
+		err = json.Unmarshal(entries.Entries[0].After, &after)
+		assert.Nil(t, err)
+		assert.Equal(t, updateFields.Name, after.Name)
 	})
 
 	t.Run("Error when sending bad id on patch", func(t *testing.T) {
@@ -320,7 +379,7 @@ func TestPersonsHandlers(t *testing.T) {
 
 	t.Run("It should create a person and delete it", func(t *testing.T) {
 		fields := GeneratePersonFields()
-		newPerson, err := CreatePerson(fields)
+		newPerson, err := CreatePerson(fields, uuid.UUID{})
 		assert.Nil(t, err)
 		newId := newPerson.ID
 
@@ -1,10 +1,13 @@
 package persons
 
-import "github.com/julienschmidt/httprouter"
+import (
+	"github.com/grabielcruz/transportation_back/modules/idempotency"
+	"github.com/julienschmidt/httprouter"
+)
 
 func Routes(router *httprouter.Router) {
 	router.GET("/persons", GetPersonsHandler)
-	router.POST("/persons", CreatePersonHandler)
+	router.POST("/persons", idempotency.Handle(CreatePersonHandler))
 	router.GET("/persons/:id", GetOnePersonHandler)
 	router.PATCH("/persons/:id", UpdatePersonHandler)
 	router.DELETE("/persons/:id", DeleteOnePersonHandler)
@@ -1,6 +1,7 @@
 package persons
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/grabielcruz/transportation_back/common"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
 )
 
 func GetPersons() []Person {
@@ -32,15 +34,30 @@ func GetPersons() []Person {
 	return persons
 }
 
-func CreatePerson(fields PersonFields) (Person, error) {
+func CreatePerson(fields PersonFields, actor_id uuid.UUID) (Person, error) {
 	p := Person{}
-	row := database.DB.QueryRow(
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return p, fmt.Errorf(errors_handler.DB002)
+	}
+	row := tx.QueryRow(
 		"INSERT INTO persons (name, document) VALUES ($1, $2) RETURNING *;",
 		fields.Name, fields.Document)
-	err := row.Scan(&p.ID, &p.Name, &p.Document, &p.CreatedAt, &p.UpdatedAt)
+	err = row.Scan(&p.ID, &p.Name, &p.Document, &p.CreatedAt, &p.UpdatedAt)
 	if err != nil {
+		tx.Rollback()
 		return p, errors_handler.MapDBErrors(err)
 	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.PersonEntity, p.ID.String(), audit_log.CreateAction, nil, p)
+	if err != nil {
+		tx.Rollback()
+		return p, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return p, fmt.Errorf(errors_handler.DB003)
+	}
 	return p, nil
 }
 
@@ -57,33 +74,96 @@ func GetOnePerson(person_id uuid.UUID) (Person, error) {
 	return p, nil
 }
 
-func UpdatePerson(person_id uuid.UUID, fields PersonFields) (Person, error) {
+// UpdatePerson overwrites the person, when if_match is not zero it fails with PC001 unless
+// the person was last updated at if_match
+func UpdatePerson(person_id uuid.UUID, fields PersonFields, if_match time.Time, actor_id uuid.UUID) (Person, error) {
 	p := Person{}
 	if person_id == (uuid.UUID{}) {
 		return p, fmt.Errorf(errors_handler.DB001)
 	}
-	row := database.DB.QueryRow("UPDATE persons SET name = $1, document = $2, updated_at = $3 WHERE id = $4 RETURNING *;",
-		fields.Name, fields.Document, time.Now(), person_id)
-	err := row.Scan(&p.ID, &p.Name, &p.Document, &p.CreatedAt, &p.UpdatedAt)
+	tx, err := database.DB.Begin()
 	if err != nil {
+		tx.Rollback()
+		return p, fmt.Errorf(errors_handler.DB002)
+	}
+	before, err := lockPerson(tx, person_id)
+	if err != nil {
+		tx.Rollback()
+		return p, err
+	}
+	row := tx.QueryRow("UPDATE persons SET name = $1, document = $2, updated_at = $3 WHERE id = $4 AND ($5::timestamptz IS NULL OR updated_at = $5) RETURNING *;",
+		fields.Name, fields.Document, time.Now(), person_id, sql.NullTime{Time: if_match, Valid: !if_match.IsZero()})
+	err = row.Scan(&p.ID, &p.Name, &p.Document, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			// the person is locked, so only the updated_at guard can drop the row
+			return p, fmt.Errorf(errors_handler.PC001)
+		}
 		return p, errors_handler.MapDBErrors(err)
 	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.PersonEntity, person_id.String(), audit_log.UpdateAction, before, p)
+	if err != nil {
+		tx.Rollback()
+		return p, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return p, fmt.Errorf(errors_handler.DB003)
+	}
 	return p, nil
 }
 
-func DeleteOnePerson(person_id uuid.UUID) (common.ID, error) {
+// DeleteOnePerson removes the person, when if_match is not zero it fails with PC001 unless
+// the person was last updated at if_match
+func DeleteOnePerson(person_id uuid.UUID, if_match time.Time, actor_id uuid.UUID) (common.ID, error) {
 	id := common.ID{}
 	if person_id == (uuid.UUID{}) {
 		return id, fmt.Errorf(errors_handler.DB001)
 	}
-	row := database.DB.QueryRow("DELETE FROM persons WHERE id = $1 RETURNING id;", person_id)
-	err := row.Scan(&id.ID)
+	tx, err := database.DB.Begin()
 	if err != nil {
+		tx.Rollback()
+		return id, fmt.Errorf(errors_handler.DB002)
+	}
+	before, err := lockPerson(tx, person_id)
+	if err != nil {
+		tx.Rollback()
+		return id, err
+	}
+	row := tx.QueryRow("DELETE FROM persons WHERE id = $1 AND ($2::timestamptz IS NULL OR updated_at = $2) RETURNING id;", person_id, sql.NullTime{Time: if_match, Valid: !if_match.IsZero()})
+	err = row.Scan(&id.ID)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return id, fmt.Errorf(errors_handler.PC001)
+		}
 		return id, errors_handler.MapDBErrors(err)
 	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.PersonEntity, person_id.String(), audit_log.DeleteAction, before, nil)
+	if err != nil {
+		tx.Rollback()
+		return id, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return id, fmt.Errorf(errors_handler.DB003)
+	}
 	return id, nil
 }
 
+// lockPerson reads the person inside tx and locks it until tx ends, it is the state
+// recorded as before in the audit log
+func lockPerson(tx *sql.Tx, person_id uuid.UUID) (Person, error) {
+	p := Person{}
+	row := tx.QueryRow("SELECT * FROM persons WHERE id = $1 FOR UPDATE;", person_id)
+	err := row.Scan(&p.ID, &p.Name, &p.Document, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return p, errors_handler.MapDBErrors(err)
+	}
+	return p, nil
+}
+
 func GetPersonsName(person_id uuid.UUID) (string, error) {
 	var name string = ""
 	if person_id == (uuid.UUID{}) {
@@ -1,12 +1,15 @@
 package persons
 
 import (
+	"encoding/json"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,7 +28,7 @@ func TestPersonService(t *testing.T) {
 
 	t.Run("Create one person", func(t *testing.T) {
 		personFields := GeneratePersonFields()
-		createdPerson, err := CreatePerson(personFields)
+		createdPerson, err := CreatePerson(personFields, uuid.UUID{})
 		assert.Nil(t, err)
 		assert.Equal(t, personFields.Name, createdPerson.Name)
 		assert.Equal(t, personFields.Document, createdPerson.Document)
@@ -34,8 +37,8 @@ func TestPersonService(t *testing.T) {
 	DeleteAllPersons()
 
 	t.Run("Create two person and get an slice of persons", func(t *testing.T) {
-		CreatePerson(GeneratePersonFields())
-		CreatePerson(GeneratePersonFields())
+		CreatePerson(GeneratePersonFields(), uuid.UUID{})
+		CreatePerson(GeneratePersonFields(), uuid.UUID{})
 		persons := GetPersons()
 		assert.Len(t, persons, 2)
 	})
@@ -43,7 +46,7 @@ func TestPersonService(t *testing.T) {
 	DeleteAllPersons()
 
 	t.Run("Create one person and get it", func(t *testing.T) {
-		newPerson, err := CreatePerson(GeneratePersonFields())
+		newPerson, err := CreatePerson(GeneratePersonFields(), uuid.UUID{})
 		assert.Nil(t, err)
 		obtainedPerson, err := GetOnePerson(newPerson.ID)
 		assert.Nil(t, err)
@@ -70,9 +73,9 @@ func TestPersonService(t *testing.T) {
 	t.Run("It should create and update one person", func(t *testing.T) {
 		createFields := GeneratePersonFields()
 		updateFields := GeneratePersonFields()
-		newPerson, err := CreatePerson(createFields)
+		newPerson, err := CreatePerson(createFields, uuid.UUID{})
 		assert.Nil(t, err)
-		updatedPerson, err := UpdatePerson(newPerson.ID, updateFields)
+		updatedPerson, err := UpdatePerson(newPerson.ID, updateFields, time.Time{}, uuid.UUID{})
 		assert.Nil(t, err)
 		assert.Equal(t, newPerson.ID, updatedPerson.ID)
 		assert.Equal(t, updateFields.Name, updatedPerson.Name)
@@ -86,20 +89,20 @@ func TestPersonService(t *testing.T) {
 		// with zero uuid
 		zeroUUID := uuid.UUID{}
 		zeroFields := PersonFields{}
-		_, err := UpdatePerson(zeroUUID, zeroFields)
+		_, err := UpdatePerson(zeroUUID, zeroFields, time.Time{}, uuid.UUID{})
 		assert.NotNil(t, err)
 
 		// with random uuid
 		randId, err := uuid.NewRandom()
 		assert.Nil(t, err)
-		_, err = UpdatePerson(randId, zeroFields)
+		_, err = UpdatePerson(randId, zeroFields, time.Time{}, uuid.UUID{})
 		assert.NotNil(t, err)
 	})
 
 	t.Run("Create a person and delete it", func(t *testing.T) {
-		newPerson, err := CreatePerson(GeneratePersonFields())
+		newPerson, err := CreatePerson(GeneratePersonFields(), uuid.UUID{})
 		assert.Nil(t, err)
-		deletedId, err := DeleteOnePerson(newPerson.ID)
+		deletedId, err := DeleteOnePerson(newPerson.ID, time.Time{}, uuid.UUID{})
 		assert.Nil(t, err)
 		assert.Equal(t, newPerson.ID, deletedId.ID)
 		_, err = GetOnePerson(deletedId.ID)
@@ -110,18 +113,18 @@ func TestPersonService(t *testing.T) {
 	t.Run("Error when attempting to delete an unexisting person", func(t *testing.T) {
 		// with zero uuid
 		zeroUUID := uuid.UUID{}
-		_, err := DeleteOnePerson(zeroUUID)
+		_, err := DeleteOnePerson(zeroUUID, time.Time{}, uuid.UUID{})
 		assert.NotNil(t, err)
 
 		// with random uuid
 		randId, err := uuid.NewRandom()
 		assert.Nil(t, err)
-		_, err = DeleteOnePerson(randId)
+		_, err = DeleteOnePerson(randId, time.Time{}, uuid.UUID{})
 		assert.NotNil(t, err)
 	})
 
 	t.Run("Create one person and get its name", func(t *testing.T) {
-		newPerson, err := CreatePerson(GeneratePersonFields())
+		newPerson, err := CreatePerson(GeneratePersonFields(), uuid.UUID{})
 		assert.Nil(t, err)
 		name, err := GetPersonsName(newPerson.ID)
 		assert.Nil(t, err)
@@ -148,12 +151,41 @@ func TestPersonService(t *testing.T) {
 		fields2 := GeneratePersonFields()
 		fields2.Document = "v7777777"
 
-		_, err := CreatePerson(fields1)
+		_, err := CreatePerson(fields1, uuid.UUID{})
 		assert.Nil(t, err)
 
-		_, err = CreatePerson(fields2)
+		_, err = CreatePerson(fields2, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.PE001, err.Error())
 	})
 
+	DeleteAllPersons()
+
+	t.Run("Record the writes in the audit log with their actor", func(t *testing.T) {
+		actor := uuid.New()
+		newPerson, err := CreatePerson(GeneratePersonFields(), actor)
+		assert.Nil(t, err)
+		updatedPerson, err := UpdatePerson(newPerson.ID, GeneratePersonFields(), newPerson.UpdatedAt, actor)
+		assert.Nil(t, err)
+
+		// the failed write leaves no entry
+		_, err = UpdatePerson(newPerson.ID, GeneratePersonFields(), newPerson.UpdatedAt, actor)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.PC001, err.Error())
+
+		response, err := audit_log.GetAuditLog(audit_log.AuditLogFilter{EntityType: audit_log.PersonEntity, EntityId: newPerson.ID.String()}, 10, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, response.Count)
+		assert.Equal(t, audit_log.UpdateAction, response.Entries[0].Action)
+		assert.Equal(t, actor, response.Entries[0].ActorId)
+		before := Person{}
+		assert.Nil(t, json.Unmarshal(response.Entries[0].Before, &before))
+		assert.Equal(t, newPerson.Name, before.Name)
+		after := Person{}
+		assert.Nil(t, json.Unmarshal(response.Entries[0].After, &after))
+		assert.Equal(t, updatedPerson.Name, after.Name)
+		assert.Equal(t, audit_log.CreateAction, response.Entries[1].Action)
+		assert.Equal(t, actor, response.Entries[1].ActorId)
+	})
+
 }
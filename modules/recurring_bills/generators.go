@@ -0,0 +1,17 @@
+package recurring_bills
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/modules/bills"
+)
+
+func GenerateRecurringBillFields(person_id uuid.UUID) RecurringBillFields {
+	fields := RecurringBillFields{
+		Template:   bills.GenerateBillFields(person_id),
+		Recurrence: Recurrence{Frequency: MonthlyRecurrence, Day: 1},
+		StartDate:  time.Now().AddDate(0, -2, 0),
+	}
+	return fields
+}
@@ -0,0 +1,169 @@
+package recurring_bills
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/julienschmidt/httprouter"
+)
+
+func GetRecurringBillsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil {
+		common.SendInvalidQueryStringError(w, err.Error())
+		return
+	}
+	offset, err := strconv.Atoi(query.Get("offset"))
+	if err != nil {
+		common.SendInvalidQueryStringError(w, err.Error())
+		return
+	}
+	response, err := GetRecurringBills(limit, offset)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, response)
+}
+
+func CreateRecurringBillHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	fields := RecurringBillFields{}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		common.SendReadError(w)
+		return
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		common.SendUnmarshalError(w)
+		return
+	}
+	if err := checkRecurringBillFields(fields); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	rb, err := CreateRecurringBill(fields)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusCreated, rb)
+}
+
+func GetRecurringBillHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := uuid.Parse(ps.ByName("id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	rb, err := GetRecurringBill(id)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, rb)
+}
+
+func DeleteRecurringBillHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := uuid.Parse(ps.ByName("id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	rb, err := DeleteRecurringBill(id)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, rb)
+}
+
+func PauseRecurringBillHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := uuid.Parse(ps.ByName("id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	rb, err := PauseRecurringBill(id, time.Now())
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, rb)
+}
+
+func ResumeRecurringBillHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := uuid.Parse(ps.ByName("id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	rb, err := ResumeRecurringBill(id, time.Now())
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, rb)
+}
+
+// PreviewRecurringBillHandler sends the next dates the bill is due from the date in the query string,
+// today by default. count defaults to config.Limit
+func PreviewRecurringBillHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := uuid.Parse(ps.ByName("id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	from := time.Now()
+	if query.Get("from") != "" {
+		from, err = time.Parse(config.DateLayout, query.Get("from"))
+		if err != nil {
+			common.SendInvalidQueryStringError(w, err.Error())
+			return
+		}
+	}
+	count := config.Limit
+	if query.Get("count") != "" {
+		count, err = strconv.Atoi(query.Get("count"))
+		if err != nil {
+			common.SendInvalidQueryStringError(w, err.Error())
+			return
+		}
+	}
+	if err := checkPreviewCount(count); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	preview, err := PreviewRecurringBill(id, from, count)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, preview)
+}
+
+// RunSchedulerHandler creates the bills due on or before the date in the query string, today by default
+func RunSchedulerHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	date := time.Now()
+	var err error
+	if r.URL.Query().Get("date") != "" {
+		date, err = time.Parse(config.DateLayout, r.URL.Query().Get("date"))
+		if err != nil {
+			common.SendInvalidQueryStringError(w, err.Error())
+			return
+		}
+	}
+	run, err := CreateDueBills(date)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, run)
+}
@@ -0,0 +1,134 @@
+package recurring_bills
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/bills"
+	"github.com/grabielcruz/transportation_back/modules/persons"
+	"github.com/grabielcruz/transportation_back/utility"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecurringBillsHandlers(t *testing.T) {
+	envPath := filepath.Clean("../../.env_test")
+	sqlPath := filepath.Clean("../../database/database.sql")
+	database.SetupDB(envPath)
+	database.CreateTables(sqlPath)
+	defer database.CloseConnection()
+	router := httprouter.New()
+	Routes(router)
+	person, err := persons.CreatePerson(persons.GeneratePersonFields(), uuid.UUID{})
+	assert.Nil(t, err)
+
+	t.Run("Create, pause, resume and preview a recurring bill", func(t *testing.T) {
+		fields := GenerateRecurringBillFields(person.ID)
+		fields.Recurrence = Recurrence{Frequency: MonthlyRecurrence, Day: 28}
+		body, err := json.Marshal(fields)
+		assert.Nil(t, err)
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/recurring_bills", bytes.NewReader(body))
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		rb := RecurringBill{}
+		err = json.Unmarshal(w.Body.Bytes(), &rb)
+		assert.Nil(t, err)
+		assert.Equal(t, fields.Recurrence, rb.Recurrence)
+
+		w2 := httptest.NewRecorder()
+		req2, err := http.NewRequest(http.MethodPost, "/recurring_bills/"+rb.ID.String()+"/pause", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusOK, w2.Code)
+		pausedBill := RecurringBill{}
+		err = json.Unmarshal(w2.Body.Bytes(), &pausedBill)
+		assert.Nil(t, err)
+		assert.True(t, pausedBill.Paused)
+
+		w3 := httptest.NewRecorder()
+		req3, err := http.NewRequest(http.MethodPost, "/recurring_bills/"+rb.ID.String()+"/resume", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w3, req3)
+		assert.Equal(t, http.StatusOK, w3.Code)
+
+		w4 := httptest.NewRecorder()
+		req4, err := http.NewRequest(http.MethodGet, "/recurring_bills/"+rb.ID.String()+"/preview?from=2030-01-01&count=3", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w4, req4)
+		assert.Equal(t, http.StatusOK, w4.Code)
+		preview := Preview{}
+		err = json.Unmarshal(w4.Body.Bytes(), &preview)
+		assert.Nil(t, err)
+		assert.Equal(t, []time.Time{date(2030, 1, 28), date(2030, 2, 28), date(2030, 3, 28)}, preview.Dates)
+	})
+
+	deleteAllRecurringBills()
+
+	t.Run("Run scheduler", func(t *testing.T) {
+		fields := GenerateRecurringBillFields(person.ID)
+		fields.Recurrence = Recurrence{Frequency: DailyRecurrence, Interval: 10}
+		fields.StartDate = date(2023, 1, 1)
+		_, err := CreateRecurringBill(fields)
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/run_recurring_bills?date=2023-01-31", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		run := SchedulerRun{}
+		err = json.Unmarshal(w.Body.Bytes(), &run)
+		assert.Nil(t, err)
+		assert.Len(t, run.Bills, 4)
+		assert.Equal(t, date(2023, 1, 31), run.Bills[3].Date.UTC())
+	})
+
+	bills.EmptyBills()
+	deleteAllRecurringBills()
+
+	t.Run("Error when creating a recurring bill with bad fields", func(t *testing.T) {
+		fields := GenerateRecurringBillFields(person.ID)
+		fields.Recurrence = Recurrence{Frequency: "YEARLY"}
+		body, err := json.Marshal(fields)
+		assert.Nil(t, err)
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/recurring_bills", bytes.NewReader(body))
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "Frequency should be MONTHLY, WEEKLY or DAILY", errResponse.Error)
+		assert.Equal(t, "VA001", errResponse.Code)
+	})
+
+	t.Run("Error when sending bad id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/recurring_bills/"+utility.GetRandomString(10), nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "UI001", errResponse.Code)
+	})
+
+	// at the end of all recurring bills handlers tests
+	persons.DeleteAllPersons()
+}
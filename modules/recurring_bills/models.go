@@ -0,0 +1,61 @@
+package recurring_bills
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/modules/bills"
+)
+
+// frequencies of a recurrence
+const (
+	MonthlyRecurrence = "MONTHLY"
+	WeeklyRecurrence  = "WEEKLY"
+	DailyRecurrence   = "DAILY"
+)
+
+// Recurrence tells when a recurring bill is due. MONTHLY is due on Day of every month, or on the last day
+// of the shorter months. WEEKLY is due every week on the weekday of the start date.
+// DAILY is due every Interval days counted from the start date
+type Recurrence struct {
+	Frequency string `json:"frequency"`
+	Day       int    `json:"day"`
+	Interval  int    `json:"interval"`
+}
+
+type RecurringBill struct {
+	ID uuid.UUID `json:"id"`
+	RecurringBillFields
+	Paused bool `json:"paused"`
+	// date since the bill is paused, nil when it is not
+	PausedAt *time.Time `json:"paused_at"`
+	common.Timestamps
+}
+
+// RecurringBillFields holds the template of the pending bills that are generated, its date is the date
+// of every occurrence and its parents are ignored. EndDate is nil when the bill has no end
+type RecurringBillFields struct {
+	Template   bills.BillFields `json:"template"`
+	Recurrence Recurrence       `json:"recurrence"`
+	StartDate  time.Time        `json:"start_date"`
+	EndDate    *time.Time       `json:"end_date"`
+}
+
+type RecurringBillResponse struct {
+	RecurringBills []RecurringBill `json:"recurring_bills"`
+	common.Pagination
+}
+
+// Preview lists the next dates a recurring bill is due
+type Preview struct {
+	RecurringBillId uuid.UUID   `json:"recurring_bill_id"`
+	Paused          bool        `json:"paused"`
+	Dates           []time.Time `json:"dates"`
+}
+
+// SchedulerRun holds the pending bills created by one run of the scheduler
+type SchedulerRun struct {
+	Date  time.Time    `json:"date"`
+	Bills []bills.Bill `json:"bills"`
+}
@@ -0,0 +1,17 @@
+package recurring_bills
+
+import (
+	"github.com/grabielcruz/transportation_back/modules/idempotency"
+	"github.com/julienschmidt/httprouter"
+)
+
+func Routes(router *httprouter.Router) {
+	router.GET("/recurring_bills", GetRecurringBillsHandler)
+	router.POST("/recurring_bills", idempotency.Handle(CreateRecurringBillHandler))
+	router.GET("/recurring_bills/:id", GetRecurringBillHandler)
+	router.DELETE("/recurring_bills/:id", DeleteRecurringBillHandler)
+	router.POST("/recurring_bills/:id/pause", PauseRecurringBillHandler)
+	router.POST("/recurring_bills/:id/resume", ResumeRecurringBillHandler)
+	router.GET("/recurring_bills/:id/preview", PreviewRecurringBillHandler)
+	router.POST("/run_recurring_bills", RunSchedulerHandler)
+}
@@ -0,0 +1,374 @@
+package recurring_bills
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
+	"github.com/grabielcruz/transportation_back/modules/bills"
+	"github.com/grabielcruz/transportation_back/modules/closed_periods"
+	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/grabielcruz/transportation_back/money"
+)
+
+// scanner is implemented by sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRecurringBill(row scanner) (RecurringBill, error) {
+	rb := RecurringBill{}
+	err := row.Scan(&rb.ID, &rb.Template.PersonId, &rb.Template.Description, &rb.Template.Currency, &rb.Template.Amount, &rb.Recurrence.Frequency, &rb.Recurrence.Day, &rb.Recurrence.Interval, &rb.StartDate, &rb.EndDate, &rb.Paused, &rb.PausedAt, &rb.CreatedAt, &rb.UpdatedAt)
+	return rb, err
+}
+
+func GetRecurringBills(limit int, offset int) (RecurringBillResponse, error) {
+	response := RecurringBillResponse{RecurringBills: []RecurringBill{}}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.DB002)
+	}
+
+	row := tx.QueryRow("SELECT COUNT(*) FROM recurring_bills;")
+	err = row.Scan(&response.Count)
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.DB004)
+	}
+
+	rows, err := tx.Query("SELECT * FROM recurring_bills ORDER BY created_at DESC LIMIT $1 OFFSET $2;", limit, offset)
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.DB005)
+	}
+	for rows.Next() {
+		rb, err := scanRecurringBill(rows)
+		if err != nil {
+			rows.Close()
+			tx.Rollback()
+			return response, fmt.Errorf(errors_handler.DB005)
+		}
+		response.RecurringBills = append(response.RecurringBills, rb)
+	}
+	rows.Close()
+
+	response.Limit = limit
+	response.Offset = offset
+
+	err = tx.Commit()
+	if err != nil {
+		return response, fmt.Errorf(errors_handler.DB003)
+	}
+	return response, nil
+}
+
+func CreateRecurringBill(fields RecurringBillFields) (RecurringBill, error) {
+	if fields.Template.Amount == money.Zero {
+		return RecurringBill{}, fmt.Errorf(errors_handler.BL002)
+	}
+	var endDate *time.Time
+	if fields.EndDate != nil {
+		end := toDate(*fields.EndDate)
+		endDate = &end
+	}
+	row := database.DB.QueryRow("INSERT INTO recurring_bills (person_id, description, currency, amount, frequency, day_of_month, interval_days, start_date, end_date) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING *;", fields.Template.PersonId, fields.Template.Description, fields.Template.Currency, fields.Template.Amount, fields.Recurrence.Frequency, fields.Recurrence.Day, fields.Recurrence.Interval, toDate(fields.StartDate), endDate)
+	rb, err := scanRecurringBill(row)
+	if err != nil {
+		return rb, errors_handler.MapDBErrors(err)
+	}
+	return rb, nil
+}
+
+func GetRecurringBill(recurring_bill_id uuid.UUID) (RecurringBill, error) {
+	row := database.DB.QueryRow("SELECT * FROM recurring_bills WHERE id = $1;", recurring_bill_id)
+	rb, err := scanRecurringBill(row)
+	if err != nil {
+		return rb, errors_handler.MapDBErrors(err)
+	}
+	return rb, nil
+}
+
+// DeleteRecurringBill removes the schedule and its occurrences, the pending bills already created are kept
+func DeleteRecurringBill(recurring_bill_id uuid.UUID) (RecurringBill, error) {
+	row := database.DB.QueryRow("DELETE FROM recurring_bills WHERE id = $1 RETURNING *;", recurring_bill_id)
+	rb, err := scanRecurringBill(row)
+	if err != nil {
+		return rb, errors_handler.MapDBErrors(err)
+	}
+	return rb, nil
+}
+
+// PauseRecurringBill stops the scheduler from creating the bills due from date on
+func PauseRecurringBill(recurring_bill_id uuid.UUID, date time.Time) (RecurringBill, error) {
+	rb, err := GetRecurringBill(recurring_bill_id)
+	if err != nil {
+		return rb, err
+	}
+	if rb.Paused {
+		return rb, fmt.Errorf(errors_handler.RB001)
+	}
+	row := database.DB.QueryRow("UPDATE recurring_bills SET paused = true, paused_at = $1, updated_at = $2 WHERE id = $3 AND paused = false RETURNING *;", toDate(date), time.Now(), recurring_bill_id)
+	rb, err = scanRecurringBill(row)
+	if err != nil {
+		// paused meanwhile
+		return rb, fmt.Errorf(errors_handler.RB001)
+	}
+	return rb, nil
+}
+
+// ResumeRecurringBill lets the scheduler create the bills due from date on. The occurrences due while
+// the bill was paused are registered as skipped, so they are never created
+func ResumeRecurringBill(recurring_bill_id uuid.UUID, date time.Time) (RecurringBill, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return RecurringBill{}, fmt.Errorf(errors_handler.DB002)
+	}
+
+	row := tx.QueryRow("SELECT * FROM recurring_bills WHERE id = $1 FOR UPDATE;", recurring_bill_id)
+	rb, err := scanRecurringBill(row)
+	if err != nil {
+		tx.Rollback()
+		return rb, errors_handler.MapDBErrors(err)
+	}
+	if !rb.Paused {
+		tx.Rollback()
+		return rb, fmt.Errorf(errors_handler.RB002)
+	}
+
+	resumedAt := toDate(date)
+	for _, d := range rb.occurrences(*rb.PausedAt, resumedAt.AddDate(0, 0, -1), 0) {
+		_, err = tx.Exec("INSERT INTO recurring_bill_occurrences (recurring_bill_id, date) VALUES ($1, $2) ON CONFLICT DO NOTHING;", rb.ID, d)
+		if err != nil {
+			tx.Rollback()
+			return rb, fmt.Errorf(errors_handler.DB007)
+		}
+	}
+
+	row = tx.QueryRow("UPDATE recurring_bills SET paused = false, paused_at = NULL, updated_at = $1 WHERE id = $2 RETURNING *;", time.Now(), rb.ID)
+	rb, err = scanRecurringBill(row)
+	if err != nil {
+		tx.Rollback()
+		return rb, fmt.Errorf(errors_handler.DB009)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return rb, fmt.Errorf(errors_handler.DB003)
+	}
+	return rb, nil
+}
+
+// PreviewRecurringBill returns the next count dates the bill is due from date on, no matter if it is paused
+func PreviewRecurringBill(recurring_bill_id uuid.UUID, from time.Time, count int) (Preview, error) {
+	preview := Preview{RecurringBillId: recurring_bill_id}
+	rb, err := GetRecurringBill(recurring_bill_id)
+	if err != nil {
+		return preview, err
+	}
+	preview.Paused = rb.Paused
+	preview.Dates = rb.occurrences(toDate(from), toDate(from).AddDate(config.PreviewYears, 0, 0), count)
+	return preview, nil
+}
+
+// CreateDueBills creates the pending bills of every active recurring bill due on or before date.
+// It can run any number of times, each occurrence is registered once in recurring_bill_occurrences
+// so its bill is never created twice
+func CreateDueBills(date time.Time) (SchedulerRun, error) {
+	run := SchedulerRun{Date: toDate(date), Bills: []bills.Bill{}}
+
+	rows, err := database.DB.Query("SELECT * FROM recurring_bills WHERE paused = false AND start_date <= $1 ORDER BY created_at;", run.Date)
+	if err != nil {
+		return run, fmt.Errorf(errors_handler.DB005)
+	}
+	recurringBills := []RecurringBill{}
+	for rows.Next() {
+		rb, err := scanRecurringBill(rows)
+		if err != nil {
+			rows.Close()
+			return run, fmt.Errorf(errors_handler.DB005)
+		}
+		recurringBills = append(recurringBills, rb)
+	}
+	rows.Close()
+
+	for _, rb := range recurringBills {
+		registered, err := getOccurrenceDates(rb.ID)
+		if err != nil {
+			return run, err
+		}
+		for _, d := range rb.occurrences(rb.StartDate, run.Date, 0) {
+			if registered[d.Format(config.DateLayout)] {
+				continue
+			}
+			bill, created, err := createOccurrence(rb, d)
+			if err != nil {
+				return run, err
+			}
+			if created {
+				run.Bills = append(run.Bills, bill)
+			}
+		}
+	}
+	return run, nil
+}
+
+// StartScheduler runs CreateDueBills now and then every interval, it is meant to run on its own goroutine
+func StartScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		run, err := CreateDueBills(time.Now())
+		if err != nil {
+			log.Println("recurring bills:", err)
+		} else if len(run.Bills) > 0 {
+			log.Printf("recurring bills: %v pending bills created\n", len(run.Bills))
+		}
+		<-ticker.C
+	}
+}
+
+func getOccurrenceDates(recurring_bill_id uuid.UUID) (map[string]bool, error) {
+	dates := map[string]bool{}
+	rows, err := database.DB.Query("SELECT date FROM recurring_bill_occurrences WHERE recurring_bill_id = $1;", recurring_bill_id)
+	if err != nil {
+		return dates, fmt.Errorf(errors_handler.DB005)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		d := time.Time{}
+		err = rows.Scan(&d)
+		if err != nil {
+			return dates, fmt.Errorf(errors_handler.DB005)
+		}
+		dates[d.Format(config.DateLayout)] = true
+	}
+	return dates, nil
+}
+
+// createOccurrence registers the occurrence and creates its pending bill, recorded in the audit log
+// as a write of the system. created is false when the occurrence was already registered
+func createOccurrence(rb RecurringBill, date time.Time) (bills.Bill, bool, error) {
+	bill := bills.Bill{}
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return bill, false, fmt.Errorf(errors_handler.DB002)
+	}
+
+	result, err := tx.Exec("INSERT INTO recurring_bill_occurrences (recurring_bill_id, date) VALUES ($1, $2) ON CONFLICT DO NOTHING;", rb.ID, date)
+	if err != nil {
+		tx.Rollback()
+		return bill, false, fmt.Errorf(errors_handler.DB007)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil || inserted == 0 {
+		tx.Rollback()
+		return bill, false, nil
+	}
+
+	// a date inside a closed period keeps its occurrence without bill, so it is skipped for good
+	err = closed_periods.CheckOpenPeriod(tx, uuid.UUID{}, date)
+	if err != nil && err.Error() == errors_handler.CP001 {
+		err = tx.Commit()
+		if err != nil {
+			return bill, false, fmt.Errorf(errors_handler.DB003)
+		}
+		return bill, false, nil
+	}
+	if err != nil {
+		tx.Rollback()
+		return bill, false, err
+	}
+
+	fields := rb.Template
+	fields.Date = date
+	fields.ParentTransactionId = uuid.UUID{}
+	fields.ParentBillCrossId = uuid.UUID{}
+	bill, err = bills.InsertPendingBill(tx, fields)
+	if err != nil {
+		tx.Rollback()
+		return bill, false, err
+	}
+
+	_, err = tx.Exec("UPDATE recurring_bill_occurrences SET bill_id = $1 WHERE recurring_bill_id = $2 AND date = $3;", bill.ID, rb.ID, date)
+	if err != nil {
+		tx.Rollback()
+		return bill, false, fmt.Errorf(errors_handler.DB009)
+	}
+
+	// the bill is created by the scheduler, not by the user who may have triggered the run
+	_, err = audit_log.Record(tx, audit_log.SystemActor, audit_log.BillEntity, bill.ID.String(), audit_log.CreateAction, nil, bill)
+	if err != nil {
+		tx.Rollback()
+		return bill, false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return bill, false, fmt.Errorf(errors_handler.DB003)
+	}
+	return bill, true, nil
+}
+
+// occurrences returns the dates the bill is due between from and to, both included, limited by its start
+// and end dates. When limit is greater than zero at most limit dates are returned
+func (rb RecurringBill) occurrences(from time.Time, to time.Time, limit int) []time.Time {
+	dates := []time.Time{}
+	start := toDate(rb.StartDate)
+	from = toDate(from)
+	to = toDate(to)
+	if rb.EndDate != nil && toDate(*rb.EndDate).Before(to) {
+		to = toDate(*rb.EndDate)
+	}
+	for i := 0; limit <= 0 || len(dates) < limit; i++ {
+		d := rb.Recurrence.nth(start, i)
+		if d.After(to) {
+			break
+		}
+		if d.Before(start) || d.Before(from) {
+			continue
+		}
+		dates = append(dates, d)
+	}
+	return dates
+}
+
+// nth returns the i-th date of the recurrence counted from start, the first monthly date may be before start
+func (r Recurrence) nth(start time.Time, i int) time.Time {
+	switch r.Frequency {
+	case MonthlyRecurrence:
+		firstOfMonth := time.Date(start.Year(), start.Month()+time.Month(i), 1, 0, 0, 0, 0, time.UTC)
+		lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+		day := r.Day
+		if day > lastDay {
+			day = lastDay
+		}
+		return firstOfMonth.AddDate(0, 0, day-1)
+	case WeeklyRecurrence:
+		return start.AddDate(0, 0, 7*i)
+	case DailyRecurrence:
+		interval := r.Interval
+		if interval < 1 {
+			interval = 1
+		}
+		return start.AddDate(0, 0, interval*i)
+	}
+	// unknown frequencies are never due
+	return time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+}
+
+// toDate drops the time of t, dates are compared in UTC as they are stored in DATE columns
+func toDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func deleteAllRecurringBills() {
+	database.DB.Exec("DELETE FROM recurring_bills;")
+}
@@ -0,0 +1,251 @@
+package recurring_bills
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
+	"github.com/grabielcruz/transportation_back/modules/bills"
+	"github.com/grabielcruz/transportation_back/modules/closed_periods"
+	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/grabielcruz/transportation_back/modules/persons"
+	"github.com/grabielcruz/transportation_back/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestOccurrences(t *testing.T) {
+	rb := RecurringBill{}
+	rb.StartDate = date(2023, 1, 15)
+
+	rb.Recurrence = Recurrence{Frequency: MonthlyRecurrence, Day: 31}
+	dates := rb.occurrences(rb.StartDate, date(2023, 4, 30), 0)
+	assert.Equal(t, []time.Time{date(2023, 1, 31), date(2023, 2, 28), date(2023, 3, 31), date(2023, 4, 30)}, dates)
+
+	// the day before the start date is due from the next month
+	rb.Recurrence = Recurrence{Frequency: MonthlyRecurrence, Day: 10}
+	dates = rb.occurrences(rb.StartDate, date(2023, 3, 10), 0)
+	assert.Equal(t, []time.Time{date(2023, 2, 10), date(2023, 3, 10)}, dates)
+
+	rb.Recurrence = Recurrence{Frequency: WeeklyRecurrence}
+	dates = rb.occurrences(date(2023, 1, 20), date(2023, 2, 5), 0)
+	assert.Equal(t, []time.Time{date(2023, 1, 22), date(2023, 1, 29), date(2023, 2, 5)}, dates)
+
+	rb.Recurrence = Recurrence{Frequency: DailyRecurrence, Interval: 10}
+	dates = rb.occurrences(rb.StartDate, date(2024, 1, 1), 3)
+	assert.Equal(t, []time.Time{date(2023, 1, 15), date(2023, 1, 25), date(2023, 2, 4)}, dates)
+
+	endDate := date(2023, 1, 30)
+	rb.EndDate = &endDate
+	dates = rb.occurrences(rb.StartDate, date(2024, 1, 1), 0)
+	assert.Equal(t, []time.Time{date(2023, 1, 15), date(2023, 1, 25)}, dates)
+}
+
+func TestRecurringBillServices(t *testing.T) {
+	envPath := filepath.Clean("../../.env_test")
+	sqlPath := filepath.Clean("../../database/database.sql")
+	database.SetupDB(envPath)
+	database.CreateTables(sqlPath)
+	defer database.CloseConnection()
+	person, err := persons.CreatePerson(persons.GeneratePersonFields(), uuid.UUID{})
+	assert.Nil(t, err)
+
+	t.Run("Get empty response of recurring bills initially", func(t *testing.T) {
+		response, err := GetRecurringBills(config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Len(t, response.RecurringBills, 0)
+		assert.Equal(t, 0, response.Count)
+	})
+
+	t.Run("Create a recurring bill and get it", func(t *testing.T) {
+		fields := GenerateRecurringBillFields(person.ID)
+		newRecurringBill, err := CreateRecurringBill(fields)
+		assert.Nil(t, err)
+		assert.Equal(t, fields.Template.PersonId, newRecurringBill.Template.PersonId)
+		assert.Equal(t, fields.Template.Amount, newRecurringBill.Template.Amount)
+		assert.Equal(t, fields.Recurrence, newRecurringBill.Recurrence)
+		assert.Equal(t, toDate(fields.StartDate), newRecurringBill.StartDate.UTC())
+		assert.Nil(t, newRecurringBill.EndDate)
+		assert.False(t, newRecurringBill.Paused)
+
+		sameRecurringBill, err := GetRecurringBill(newRecurringBill.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, newRecurringBill.ID, sameRecurringBill.ID)
+
+		response, err := GetRecurringBills(config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, response.Count)
+	})
+
+	deleteAllRecurringBills()
+
+	t.Run("Error when creating a recurring bill of unexisting person", func(t *testing.T) {
+		_, err := CreateRecurringBill(GenerateRecurringBillFields(uuid.New()))
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.PE002, err.Error())
+	})
+
+	t.Run("Scheduler creates due bills only once", func(t *testing.T) {
+		fields := GenerateRecurringBillFields(person.ID)
+		fields.Template.Amount = money.FromUnits(-250)
+		fields.Recurrence = Recurrence{Frequency: MonthlyRecurrence, Day: 5}
+		fields.StartDate = date(2023, 1, 1)
+		rb, err := CreateRecurringBill(fields)
+		assert.Nil(t, err)
+
+		run, err := CreateDueBills(date(2023, 3, 4))
+		assert.Nil(t, err)
+		assert.Len(t, run.Bills, 2)
+		assert.Equal(t, date(2023, 1, 5), run.Bills[0].Date.UTC())
+		assert.Equal(t, date(2023, 2, 5), run.Bills[1].Date.UTC())
+		assert.Equal(t, fields.Template.Amount, run.Bills[0].Amount)
+		assert.Equal(t, fields.Template.Description, run.Bills[0].Description)
+
+		// the bills are recorded as written by the system
+		entries, err := audit_log.GetAuditLog(audit_log.AuditLogFilter{EntityType: audit_log.BillEntity, EntityId: run.Bills[0].ID.String()}, 10, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, entries.Count)
+		assert.Equal(t, audit_log.CreateAction, entries.Entries[0].Action)
+		assert.Equal(t, audit_log.SystemActor, entries.Entries[0].ActorId)
+
+		run, err = CreateDueBills(date(2023, 3, 4))
+		assert.Nil(t, err)
+		assert.Len(t, run.Bills, 0)
+
+		run, err = CreateDueBills(date(2023, 3, 5))
+		assert.Nil(t, err)
+		assert.Len(t, run.Bills, 1)
+
+		billResponse, err := bills.GetPendingBills(person.ID, true, true, config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, billResponse.Count)
+
+		// deleted bills are not created again
+		_, err = bills.DeleteBill(run.Bills[0].ID, time.Time{}, uuid.UUID{})
+		assert.Nil(t, err)
+		run, err = CreateDueBills(date(2023, 3, 5))
+		assert.Nil(t, err)
+		assert.Len(t, run.Bills, 0)
+
+		_, err = DeleteRecurringBill(rb.ID)
+		assert.Nil(t, err)
+	})
+
+	bills.EmptyBills()
+	deleteAllRecurringBills()
+
+	t.Run("Pause and resume a recurring bill skips the dates due meanwhile", func(t *testing.T) {
+		fields := GenerateRecurringBillFields(person.ID)
+		fields.Recurrence = Recurrence{Frequency: WeeklyRecurrence}
+		fields.StartDate = date(2023, 1, 2)
+		rb, err := CreateRecurringBill(fields)
+		assert.Nil(t, err)
+
+		run, err := CreateDueBills(date(2023, 1, 2))
+		assert.Nil(t, err)
+		assert.Len(t, run.Bills, 1)
+
+		pausedBill, err := PauseRecurringBill(rb.ID, date(2023, 1, 3))
+		assert.Nil(t, err)
+		assert.True(t, pausedBill.Paused)
+		assert.Equal(t, date(2023, 1, 3), pausedBill.PausedAt.UTC())
+
+		_, err = PauseRecurringBill(rb.ID, date(2023, 1, 4))
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.RB001, err.Error())
+
+		run, err = CreateDueBills(date(2023, 1, 20))
+		assert.Nil(t, err)
+		assert.Len(t, run.Bills, 0)
+
+		resumedBill, err := ResumeRecurringBill(rb.ID, date(2023, 1, 20))
+		assert.Nil(t, err)
+		assert.False(t, resumedBill.Paused)
+		assert.Nil(t, resumedBill.PausedAt)
+
+		_, err = ResumeRecurringBill(rb.ID, date(2023, 1, 20))
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.RB002, err.Error())
+
+		// 9 and 16 were skipped
+		run, err = CreateDueBills(date(2023, 1, 30))
+		assert.Nil(t, err)
+		assert.Len(t, run.Bills, 2)
+		assert.Equal(t, date(2023, 1, 23), run.Bills[0].Date.UTC())
+		assert.Equal(t, date(2023, 1, 30), run.Bills[1].Date.UTC())
+	})
+
+	bills.EmptyBills()
+	deleteAllRecurringBills()
+
+	t.Run("Scheduler skips the dates inside a closed period", func(t *testing.T) {
+		fields := GenerateRecurringBillFields(person.ID)
+		fields.Recurrence = Recurrence{Frequency: MonthlyRecurrence, Day: 10}
+		fields.StartDate = date(2023, 1, 1)
+		rb, err := CreateRecurringBill(fields)
+		assert.Nil(t, err)
+
+		cp, err := closed_periods.CreateClosedPeriod(closed_periods.ClosedPeriodFields{ClosedUntil: date(2023, 2, 28)})
+		assert.Nil(t, err)
+
+		run, err := CreateDueBills(date(2023, 3, 10))
+		assert.Nil(t, err)
+		assert.Len(t, run.Bills, 1)
+		assert.Equal(t, date(2023, 3, 10), run.Bills[0].Date.UTC())
+
+		// the skipped dates are not created after the period is opened again
+		_, err = closed_periods.DeleteClosedPeriod(cp.ID)
+		assert.Nil(t, err)
+		run, err = CreateDueBills(date(2023, 3, 10))
+		assert.Nil(t, err)
+		assert.Len(t, run.Bills, 0)
+
+		_, err = DeleteRecurringBill(rb.ID)
+		assert.Nil(t, err)
+	})
+
+	bills.EmptyBills()
+	deleteAllRecurringBills()
+
+	t.Run("Preview next dates of a recurring bill", func(t *testing.T) {
+		fields := GenerateRecurringBillFields(person.ID)
+		fields.Recurrence = Recurrence{Frequency: DailyRecurrence, Interval: 15}
+		fields.StartDate = date(2023, 1, 1)
+		endDate := date(2023, 2, 28)
+		fields.EndDate = &endDate
+		rb, err := CreateRecurringBill(fields)
+		assert.Nil(t, err)
+
+		preview, err := PreviewRecurringBill(rb.ID, date(2023, 1, 10), 2)
+		assert.Nil(t, err)
+		assert.Equal(t, []time.Time{date(2023, 1, 16), date(2023, 1, 31)}, preview.Dates)
+
+		preview, err = PreviewRecurringBill(rb.ID, date(2023, 1, 10), 10)
+		assert.Nil(t, err)
+		assert.Equal(t, []time.Time{date(2023, 1, 16), date(2023, 1, 31), date(2023, 2, 15)}, preview.Dates)
+	})
+
+	deleteAllRecurringBills()
+
+	t.Run("Error when getting unexisting recurring bill", func(t *testing.T) {
+		_, err := GetRecurringBill(uuid.New())
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+		_, err = PreviewRecurringBill(uuid.New(), time.Now(), 1)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+		_, err = ResumeRecurringBill(uuid.New(), time.Now())
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+	})
+
+	// at the end of all recurring bills services tests
+	persons.DeleteAllPersons()
+}
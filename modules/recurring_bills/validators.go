@@ -0,0 +1,51 @@
+package recurring_bills
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/modules/currencies"
+	"github.com/grabielcruz/transportation_back/money"
+)
+
+func checkRecurringBillFields(fields RecurringBillFields) error {
+	if fields.Template.PersonId == (uuid.UUID{}) {
+		return fmt.Errorf("Person id should be not zero uuid")
+	}
+	if fields.Template.Description == "" {
+		return fmt.Errorf("Description is required")
+	}
+	if fields.Template.Amount == money.Zero {
+		return fmt.Errorf("Amount should be different from zero")
+	}
+	if err := currencies.CheckValidCurrency(fields.Template.Currency); err != nil {
+		return err
+	}
+	if fields.StartDate.IsZero() {
+		return fmt.Errorf("Start date is required")
+	}
+	if fields.EndDate != nil && toDate(*fields.EndDate).Before(toDate(fields.StartDate)) {
+		return fmt.Errorf("End date should not be before start date")
+	}
+	switch fields.Recurrence.Frequency {
+	case MonthlyRecurrence:
+		if fields.Recurrence.Day < 1 || fields.Recurrence.Day > 31 {
+			return fmt.Errorf("Day of the month should be between 1 and 31")
+		}
+	case WeeklyRecurrence:
+	case DailyRecurrence:
+		if fields.Recurrence.Interval < 1 {
+			return fmt.Errorf("Interval should be at least one day")
+		}
+	default:
+		return fmt.Errorf("Frequency should be MONTHLY, WEEKLY or DAILY")
+	}
+	return nil
+}
+
+func checkPreviewCount(count int) error {
+	if count < 1 || count > 100 {
+		return fmt.Errorf("Count should be between 1 and 100")
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package recurring_bills
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/money"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRecurringBillFields(t *testing.T) {
+	fields := RecurringBillFields{}
+	err := checkRecurringBillFields(fields)
+	assert.Equal(t, "Person id should be not zero uuid", err.Error())
+	fields.Template.PersonId = uuid.New()
+	err = checkRecurringBillFields(fields)
+	assert.Equal(t, "Description is required", err.Error())
+	fields.Template.Description = "parking"
+	err = checkRecurringBillFields(fields)
+	assert.Equal(t, "Amount should be different from zero", err.Error())
+	fields.Template.Amount = money.FromUnits(-30)
+	err = checkRecurringBillFields(fields)
+	assert.Equal(t, "Currency code should be 3 upper case letters", err.Error())
+	fields.Template.Currency = "USD"
+	err = checkRecurringBillFields(fields)
+	assert.Equal(t, "Start date is required", err.Error())
+	fields.StartDate = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	endDate := fields.StartDate.AddDate(0, 0, -1)
+	fields.EndDate = &endDate
+	err = checkRecurringBillFields(fields)
+	assert.Equal(t, "End date should not be before start date", err.Error())
+	fields.EndDate = nil
+	err = checkRecurringBillFields(fields)
+	assert.Equal(t, "Frequency should be MONTHLY, WEEKLY or DAILY", err.Error())
+	fields.Recurrence.Frequency = MonthlyRecurrence
+	err = checkRecurringBillFields(fields)
+	assert.Equal(t, "Day of the month should be between 1 and 31", err.Error())
+	fields.Recurrence.Day = 31
+	assert.Nil(t, checkRecurringBillFields(fields))
+	fields.Recurrence.Frequency = DailyRecurrence
+	err = checkRecurringBillFields(fields)
+	assert.Equal(t, "Interval should be at least one day", err.Error())
+	fields.Recurrence.Interval = 15
+	assert.Nil(t, checkRecurringBillFields(fields))
+	fields.Recurrence.Frequency = WeeklyRecurrence
+	assert.Nil(t, checkRecurringBillFields(fields))
+}
+
+func TestCheckPreviewCount(t *testing.T) {
+	err := checkPreviewCount(0)
+	assert.Equal(t, "Count should be between 1 and 100", err.Error())
+	err = checkPreviewCount(101)
+	assert.Equal(t, "Count should be between 1 and 100", err.Error())
+	assert.Nil(t, checkPreviewCount(5))
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -68,11 +69,12 @@ func CreateTransactionHandler(w http.ResponseWriter, r *http.Request, ps httprou
 		common.SendUnmarshalError(w)
 		return
 	}
+	fields.ApplyFeeRule = !hasFee(body)
 	if err := checkTransactionFields(fields); err != nil {
 		common.SendValidationError(w, err.Error())
 		return
 	}
-	transaction, err = CreateTransaction(fields, person_id, true)
+	transaction, err = CreateTransaction(fields, person_id, true, audit_log.ActorId(r))
 	if err != nil {
 		common.SendServiceError(w, err.Error())
 		return
@@ -80,11 +82,186 @@ func CreateTransactionHandler(w http.ResponseWriter, r *http.Request, ps httprou
 	common.SendJson(w, http.StatusCreated, transaction)
 }
 
-func DeleteLastTransactionHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	trashedTransaction, err := DeleteLastTransaction()
+func ClosePendingBillHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	completed, err := strconv.ParseBool(ps.ByName("completed"))
+	if err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	closePendingBill(w, r, ps, completed)
+}
+
+// PartialPaymentHandler applies a transaction to a pending bill, closing only the paid portion
+func PartialPaymentHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	closePendingBill(w, r, ps, false)
+}
+
+// closePendingBill is shared by the routes closing a pending bill, completed
+// closes it whole and otherwise only the paid portion is closed
+func closePendingBill(w http.ResponseWriter, r *http.Request, ps httprouter.Params, completed bool) {
+	bill_id, err := uuid.Parse(ps.ByName("bill_id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	fields := TransactionFields{}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		common.SendReadError(w)
+		return
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		common.SendUnmarshalError(w)
+		return
+	}
+	fields.ApplyFeeRule = !hasFee(body)
+	if err := checkTransactionFields(fields); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	response, err := ClosePendingBill(bill_id, fields, completed, audit_log.ActorId(r))
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusCreated, response)
+}
+
+func RevertClosedBillHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	bill_id, err := uuid.Parse(ps.ByName("bill_id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	response, err := RevertClosedBill(bill_id, audit_log.ActorId(r))
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusCreated, response)
+}
+
+func UpdateTransactionHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	transaction_id, err := uuid.Parse(ps.ByName("transaction_id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	fields := TransactionUpdateFields{}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		common.SendReadError(w)
+		return
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		common.SendUnmarshalError(w)
+		return
+	}
+	fields.KeepFee = !hasFee(body)
+	if err := checkTransactionUpdateFields(fields); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	transaction, err := UpdateTransaction(transaction_id, fields, audit_log.ActorId(r))
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, transaction)
+}
+
+func DeleteTransactionHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	transaction_id, err := uuid.Parse(ps.ByName("transaction_id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	trashedTransaction, err := DeleteTransaction(transaction_id, audit_log.ActorId(r))
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, trashedTransaction)
+}
+
+func DeleteLastAccountTransactionHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	account_id, err := uuid.Parse(ps.ByName("account_id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	trashedTransaction, err := DeleteLastAccountTransaction(account_id, audit_log.ActorId(r))
 	if err != nil {
 		common.SendServiceError(w, err.Error())
 		return
 	}
 	common.SendJson(w, http.StatusOK, trashedTransaction)
 }
+
+func CreateTransferHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	fields := TransferFields{}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		common.SendReadError(w)
+		return
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		common.SendUnmarshalError(w)
+		return
+	}
+	if err := checkTransferFields(fields); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	response, err := CreateTransfer(fields, audit_log.ActorId(r))
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusCreated, response)
+}
+
+func GetTransferHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	transfer_id, err := uuid.Parse(ps.ByName("transfer_id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	response, err := GetTransfer(transfer_id)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, response)
+}
+
+// CheckLedgerHandler only reports the inconsistencies of the ledger
+func CheckLedgerHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	report, err := CheckLedger(false)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, report)
+}
+
+// RepairLedgerHandler reports the inconsistencies of the ledger and fixes them
+func RepairLedgerHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	report, err := CheckLedger(true)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, report)
+}
+
+// hasFee tells whether the body of the request sets the fee, without it new transactions apply the fee rule
+// of the account and edited ones keep their stored fee
+func hasFee(body []byte) bool {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return true
+	}
+	fee, ok := raw["fee"]
+	return ok && string(fee) != "null"
+}
@@ -8,14 +8,17 @@ import (
 	"net/http/httptest"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
 	"github.com/grabielcruz/transportation_back/modules/bills"
 	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/grabielcruz/transportation_back/modules/idempotency"
 	"github.com/grabielcruz/transportation_back/modules/money_accounts"
 	"github.com/grabielcruz/transportation_back/modules/persons"
+	"github.com/grabielcruz/transportation_back/money"
 	"github.com/grabielcruz/transportation_back/utility"
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
@@ -31,9 +34,9 @@ func TestTransactionsHandlers(t *testing.T) {
 	Routes(router)
 	bills.Routes(router)
 
-	account, err := money_accounts.CreateMoneyAccount(money_accounts.GenerateAccountFields())
+	account, err := money_accounts.CreateMoneyAccount(money_accounts.GenerateAccountFields(), uuid.UUID{})
 	assert.Nil(t, err)
-	person, err := persons.CreatePerson(persons.GeneratePersonFields())
+	person, err := persons.CreatePerson(persons.GeneratePersonFields(), uuid.UUID{})
 	assert.Nil(t, err)
 
 	t.Run("Get a transaction response with zero transactions initially", func(t *testing.T) {
@@ -79,6 +82,33 @@ func TestTransactionsHandlers(t *testing.T) {
 	money_accounts.ResetAccountsBalance(account.ID)
 	deleteAllTransactions()
 
+	t.Run("Retrying a transaction with the same idempotency key creates it once", func(t *testing.T) {
+		fields := GenerateTransactionFields(account.ID)
+		body, err := json.Marshal(fields)
+		assert.Nil(t, err)
+		key := uuid.New().String()
+
+		responses := []*httptest.ResponseRecorder{}
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/transaction_to_pending_bill/"+person.ID.String(), bytes.NewReader(body))
+			assert.Nil(t, err)
+			req.Header.Set(idempotency.HeaderName, key)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusCreated, w.Code)
+			responses = append(responses, w)
+		}
+		assert.Equal(t, responses[0].Body.String(), responses[1].Body.String())
+		assert.Equal(t, "true", responses[1].Header().Get(idempotency.ReplayedHeaderName))
+
+		transations, err := GetTransactions(account.ID, config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, transations.Count)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
 	t.Run("Error when creating a transaction with an unexisting account", func(t *testing.T) {
 		buf := bytes.Buffer{}
 		fields := GenerateTransactionFields(uuid.UUID{})
@@ -169,7 +199,7 @@ func TestTransactionsHandlers(t *testing.T) {
 		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
 		assert.Nil(t, err)
 		assert.NotNil(t, errResponse.Error)
-		assert.Equal(t, errors_handler.TR002, errResponse.Error)
+		assert.Equal(t, fmt.Sprintf(errors_handler.TR002, money.Zero), errResponse.Error)
 		assert.Equal(t, "TR002", errResponse.Code)
 	})
 
@@ -204,7 +234,7 @@ func TestTransactionsHandlers(t *testing.T) {
 	t.Run("Error when sending zero amount", func(t *testing.T) {
 		buf := bytes.Buffer{}
 		fields := GenerateTransactionFields(account.ID)
-		fields.Amount = float64(0)
+		fields.Amount = money.Zero
 		err := json.NewEncoder(&buf).Encode(fields)
 		assert.Nil(t, err)
 
@@ -229,7 +259,7 @@ func TestTransactionsHandlers(t *testing.T) {
 	t.Run("Error when sending negative fee", func(t *testing.T) {
 		buf := bytes.Buffer{}
 		fields := GenerateTransactionFields(account.ID)
-		fields.Fee = -0.1
+		fields.Fee = money.MustParseRate("-0.1")
 		err := json.NewEncoder(&buf).Encode(fields)
 		assert.Nil(t, err)
 
@@ -254,7 +284,7 @@ func TestTransactionsHandlers(t *testing.T) {
 	t.Run("Error when sending fee greater than one", func(t *testing.T) {
 		buf := bytes.Buffer{}
 		fields := GenerateTransactionFields(account.ID)
-		fields.Fee = 1.05
+		fields.Fee = money.MustParseRate("1.05")
 		err := json.NewEncoder(&buf).Encode(fields)
 		assert.Nil(t, err)
 
@@ -278,7 +308,7 @@ func TestTransactionsHandlers(t *testing.T) {
 
 	t.Run("Create one transaction and get it in paginated response", func(t *testing.T) {
 		fields := GenerateTransactionFields(account.ID)
-		newTransaction, err := CreateTransaction(fields, person.ID, true)
+		newTransaction, err := CreateTransaction(fields, person.ID, true, uuid.UUID{})
 		assert.Nil(t, err)
 
 		url := fmt.Sprintf("/transactions/%v?limit=%v&offset=%v", account.ID.String(), config.Limit, config.Offset)
@@ -309,7 +339,7 @@ func TestTransactionsHandlers(t *testing.T) {
 		// creating
 		buf := bytes.Buffer{}
 		fields := GenerateTransactionFields(account.ID)
-		fields.Fee = 0
+		fields.Fee = money.Rate{}
 		err := json.NewEncoder(&buf).Encode(fields)
 		assert.Nil(t, err)
 
@@ -355,7 +385,7 @@ func TestTransactionsHandlers(t *testing.T) {
 		// creating
 		buf := bytes.Buffer{}
 		fields := GenerateTransactionFields(account.ID)
-		fields.Fee = 0
+		fields.Fee = money.Rate{}
 		err := json.NewEncoder(&buf).Encode(fields)
 		assert.Nil(t, err)
 
@@ -440,7 +470,7 @@ func TestTransactionsHandlers(t *testing.T) {
 
 			transactionFields := GenerateTransactionFields(account.ID)
 			transactionFields.Amount = v
-			_, err := CreateTransaction(transactionFields, personId, true)
+			_, err := CreateTransaction(transactionFields, personId, true, uuid.UUID{})
 			assert.Nil(t, err)
 		}
 		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
@@ -506,8 +536,8 @@ func TestTransactionsHandlers(t *testing.T) {
 
 	t.Run("Error when creating a transaction without fee, delete it and then getting it", func(t *testing.T) {
 		fields := GenerateTransactionFields(account.ID)
-		fields.Fee = 0
-		newTransaction, err := CreateTransaction(fields, person.ID, true)
+		fields.Fee = money.Rate{}
+		newTransaction, err := CreateTransaction(fields, person.ID, true, uuid.UUID{})
 		assert.Nil(t, err)
 
 		// pending bill
@@ -520,7 +550,7 @@ func TestTransactionsHandlers(t *testing.T) {
 		assert.Equal(t, newPendingBill.Description, newTransaction.Description)
 
 		// delete
-		req, err := http.NewRequest(http.MethodDelete, "/transactions", nil)
+		req, err := http.NewRequest(http.MethodDelete, "/last_transaction/"+account.ID.String(), nil)
 		assert.Nil(t, err)
 
 		w := httptest.NewRecorder()
@@ -574,7 +604,7 @@ func TestTransactionsHandlers(t *testing.T) {
 
 	t.Run("Error when creating a transaction with fee, delete it and then getting it", func(t *testing.T) {
 		fields := GenerateTransactionFields(account.ID)
-		newTransaction, err := CreateTransaction(fields, person.ID, true)
+		newTransaction, err := CreateTransaction(fields, person.ID, true, uuid.UUID{})
 		assert.Nil(t, err)
 
 		// pending bill
@@ -587,7 +617,7 @@ func TestTransactionsHandlers(t *testing.T) {
 		assert.Equal(t, newPendingBill.Description, newTransaction.Description)
 
 		// delete
-		req, err := http.NewRequest(http.MethodDelete, "/transactions", nil)
+		req, err := http.NewRequest(http.MethodDelete, "/last_transaction/"+account.ID.String(), nil)
 		assert.Nil(t, err)
 
 		w := httptest.NewRecorder()
@@ -640,7 +670,7 @@ func TestTransactionsHandlers(t *testing.T) {
 	deleteAllTransactions()
 
 	t.Run("Error when deleting last transaction with no transactions", func(t *testing.T) {
-		req, err := http.NewRequest(http.MethodDelete, "/transactions", nil)
+		req, err := http.NewRequest(http.MethodDelete, "/last_transaction/"+account.ID.String(), nil)
 		assert.Nil(t, err)
 
 		w := httptest.NewRecorder()
@@ -657,7 +687,7 @@ func TestTransactionsHandlers(t *testing.T) {
 	t.Run("Error when deleting pending bill associated with transaction", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
 		transactionFields.Fee = utility.GetRandomFee()
-		newTransaction, err := CreateTransaction(transactionFields, person.ID, true)
+		newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
 		assert.Nil(t, err)
 		// this deletion should be forbidden
 		w := httptest.NewRecorder()
@@ -703,6 +733,519 @@ func TestTransactionsHandlers(t *testing.T) {
 		assert.Len(t, transactions.Transactions, 1)
 	})
 
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Close pending bill partially and then completely", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		buf := bytes.Buffer{}
+		fields := GenerateTransactionFields(account.ID)
+		fields.Amount = money.FromUnits(20)
+		fields.Fee = money.Rate{}
+		err = json.NewEncoder(&buf).Encode(fields)
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/close_pending_bill/%v/%v", pendingBill.ID, false)
+		req, err := http.NewRequest(http.MethodPost, url, &buf)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		response := ClosedBillResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(20), response.ClosedBill.Amount)
+		assert.Equal(t, bills.SolvedStatus, response.ClosedBill.Status)
+		assert.Equal(t, pendingBill.ID, response.PendingBill.ID)
+		assert.Equal(t, money.FromUnits(30), response.PendingBill.Amount)
+
+		buf = bytes.Buffer{}
+		fields.Amount = money.FromUnits(30)
+		err = json.NewEncoder(&buf).Encode(fields)
+		assert.Nil(t, err)
+
+		w2 := httptest.NewRecorder()
+		url = fmt.Sprintf("/close_pending_bill/%v/%v", pendingBill.ID, true)
+		req2, err := http.NewRequest(http.MethodPost, url, &buf)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusCreated, w2.Code)
+
+		response = ClosedBillResponse{}
+		err = json.Unmarshal(w2.Body.Bytes(), &response)
+		assert.Nil(t, err)
+		assert.Equal(t, pendingBill.ID, response.ClosedBill.ID)
+		assert.Equal(t, money.FromUnits(30), response.ClosedBill.Amount)
+		assert.Equal(t, uuid.UUID{}, response.PendingBill.ID)
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(50), updatedAccount.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when closing pending bill with bad completed flag", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		err := json.NewEncoder(&buf).Encode(GenerateTransactionFields(account.ID))
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/close_pending_bill/%v/%v", uuid.UUID{}, "asalto")
+		req, err := http.NewRequest(http.MethodPost, url, &buf)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "strconv.ParseBool: parsing \"asalto\": invalid syntax", errResponse.Error)
+		assert.Equal(t, "VA001", errResponse.Code)
+	})
+
+	t.Run("Error when closing unexisting pending bill", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		fields := GenerateTransactionFields(account.ID)
+		fields.Amount = money.FromUnits(10)
+		err := json.NewEncoder(&buf).Encode(fields)
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/close_pending_bill/%v/%v", uuid.UUID{}, true)
+		req, err := http.NewRequest(http.MethodPost, url, &buf)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, errors_handler.DB001, errResponse.Error)
+		assert.Equal(t, "DB001", errResponse.Code)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Revert closed bill", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		fields := GenerateTransactionFields(account.ID)
+		fields.Amount = money.FromUnits(50)
+		fields.Fee = money.Rate{}
+		closing, err := ClosePendingBill(pendingBill.ID, fields, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/revert_closed_bill/"+closing.ClosedBill.ID.String(), nil)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		response := ClosedBillResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Nil(t, err)
+		assert.Equal(t, bills.RevertedStatus, response.ClosedBill.Status)
+		assert.Equal(t, response.Transaction.ID, response.ClosedBill.RevertTransactionId)
+		assert.Equal(t, money.FromUnits(-50), response.Transaction.Amount)
+		assert.Equal(t, money.FromUnits(50), response.PendingBill.Amount)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when reverting with bad bill id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/revert_closed_bill/1234", nil)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "invalid UUID length: 4", errResponse.Error)
+		assert.Equal(t, "UI001", errResponse.Code)
+	})
+
+	t.Run("Pay pending bill partially and get its settlements", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		buf := bytes.Buffer{}
+		fields := GenerateTransactionFields(account.ID)
+		fields.Amount = money.FromUnits(15)
+		fields.Fee = money.Rate{}
+		err = json.NewEncoder(&buf).Encode(fields)
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/partial_payment/"+pendingBill.ID.String(), &buf)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		response := ClosedBillResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(15), response.ClosedBill.Amount)
+		assert.Equal(t, pendingBill.ID, response.ClosedBill.OriginBillId)
+		assert.Equal(t, money.FromUnits(35), response.PendingBill.Amount)
+
+		w2 := httptest.NewRecorder()
+		url := fmt.Sprintf("/bill_settlements/%v?limit=%v&offset=%v", pendingBill.ID, config.Limit, config.Offset)
+		req2, err := http.NewRequest(http.MethodGet, url, nil)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusOK, w2.Code)
+
+		settlements := bills.BillResponse{}
+		err = json.Unmarshal(w2.Body.Bytes(), &settlements)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, settlements.Count)
+		assert.Equal(t, response.ClosedBill.ID, settlements.Bills[0].ID)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Update a transaction", func(t *testing.T) {
+		fields := GenerateTransactionFields(account.ID)
+		fields.Amount = money.FromUnits(10)
+		fields.Fee = money.Rate{}
+		newTransaction, err := CreateTransaction(fields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		updateFields := TransactionUpdateFields{
+			PersonId:    person.ID,
+			Date:        newTransaction.Date,
+			Amount:      money.MustParse("12.5"),
+			Fee:         money.Rate{},
+			Description: utility.GetRandomString(55),
+		}
+		buf := bytes.Buffer{}
+		err = json.NewEncoder(&buf).Encode(updateFields)
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPatch, "/transactions/"+newTransaction.ID.String(), &buf)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		updatedTransaction := Transaction{}
+		err = json.Unmarshal(w.Body.Bytes(), &updatedTransaction)
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParse("12.5"), updatedTransaction.Amount)
+		assert.Equal(t, money.MustParse("12.5"), updatedTransaction.Balance)
+		assert.Equal(t, updateFields.Description, updatedTransaction.Description)
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParse("12.5"), updatedAccount.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when updating a transaction with empty description", func(t *testing.T) {
+		updateFields := TransactionUpdateFields{
+			PersonId: person.ID,
+			Amount:   money.MustParse("12.5"),
+		}
+		buf := bytes.Buffer{}
+		err := json.NewEncoder(&buf).Encode(updateFields)
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPatch, "/transactions/"+uuid.UUID{}.String(), &buf)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "Transaction should have a description", errResponse.Error)
+		assert.Equal(t, "VA001", errResponse.Code)
+	})
+
+	t.Run("Delete a transaction by its id", func(t *testing.T) {
+		fields := GenerateTransactionFields(account.ID)
+		fields.Amount = money.FromUnits(10)
+		fields.Fee = money.Rate{}
+		firstTransaction, err := CreateTransaction(fields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		_, err = CreateTransaction(fields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodDelete, "/transactions/"+firstTransaction.ID.String(), nil)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		deletedTransaction := Transaction{}
+		err = json.Unmarshal(w.Body.Bytes(), &deletedTransaction)
+		assert.Nil(t, err)
+		assert.Equal(t, firstTransaction.ID, deletedTransaction.ID)
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(10), updatedAccount.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Delete last transaction of an account", func(t *testing.T) {
+		fields := GenerateTransactionFields(account.ID)
+		fields.Amount = money.FromUnits(10)
+		fields.Fee = money.Rate{}
+		_, err := CreateTransaction(fields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		lastTransaction, err := CreateTransaction(fields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodDelete, "/last_transaction/"+account.ID.String(), nil)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		deletedTransaction := Transaction{}
+		err = json.Unmarshal(w.Body.Bytes(), &deletedTransaction)
+		assert.Nil(t, err)
+		assert.Equal(t, lastTransaction.ID, deletedTransaction.ID)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when deleting transaction with bad id", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodDelete, "/transactions/1234", nil)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "invalid UUID length: 4", errResponse.Error)
+		assert.Equal(t, "UI001", errResponse.Code)
+	})
+
+	t.Run("Create a transfer and get it", func(t *testing.T) {
+		accountFields := money_accounts.GenerateAccountFields()
+		accountFields.Currency = account.Currency
+		otherAccount, err := money_accounts.CreateMoneyAccount(accountFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		fields := GenerateTransactionFields(account.ID)
+		fields.Amount = money.FromUnits(50)
+		fields.Fee = money.Rate{}
+		_, err = CreateTransaction(fields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transferFields := TransferFields{
+			FromAccountId: account.ID,
+			ToAccountId:   otherAccount.ID,
+			Date:          time.Now(),
+			Amount:        money.FromUnits(20),
+			Description:   "petty cash",
+		}
+		body, err := json.Marshal(transferFields)
+		assert.Nil(t, err)
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(body))
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		response := TransferResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(20), response.Transfer.CreditedAmount)
+		assert.Equal(t, money.FromUnits(30), response.FromTransaction.Balance)
+
+		w = httptest.NewRecorder()
+		req, err = http.NewRequest(http.MethodGet, "/transfers/"+response.Transfer.ID.String(), nil)
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		sameTransfer := TransferResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &sameTransfer)
+		assert.Nil(t, err)
+		assert.Equal(t, response.Transfer.ID, sameTransfer.Transfer.ID)
+
+		money_accounts.ResetAccountsBalance(otherAccount.ID)
+		deleteAllTransactions()
+		money_accounts.DeleteOneMoneyAccount(otherAccount.ID, time.Time{}, uuid.UUID{})
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when creating a transfer to the same account", func(t *testing.T) {
+		transferFields := TransferFields{
+			FromAccountId: account.ID,
+			ToAccountId:   account.ID,
+			Date:          time.Now(),
+			Amount:        money.FromUnits(20),
+			Description:   "petty cash",
+		}
+		body, err := json.Marshal(transferFields)
+		assert.Nil(t, err)
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(body))
+		assert.Nil(t, err)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		errResponse := errors_handler.ErrorResponse{}
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "Accounts should be different", errResponse.Error)
+		assert.Equal(t, "VA001", errResponse.Code)
+	})
+
+	t.Run("Check and repair ledger", func(t *testing.T) {
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(10)
+		transactionFields.Fee = money.Rate{}
+		newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		_, err = database.DB.Exec("UPDATE transactions SET balance = 7 WHERE id = $1;", newTransaction.ID)
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/admin/ledger_check", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		report := LedgerReport{}
+		err = json.Unmarshal(w.Body.Bytes(), &report)
+		assert.Nil(t, err)
+		assert.Len(t, report.Issues, 1)
+		assert.Equal(t, newTransaction.ID, report.Issues[0].TransactionId)
+		assert.False(t, report.Repaired)
+
+		w2 := httptest.NewRecorder()
+		req2, err := http.NewRequest(http.MethodPost, "/admin/ledger_repair", nil)
+		assert.Nil(t, err)
+		router.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusOK, w2.Code)
+		report2 := LedgerReport{}
+		err = json.Unmarshal(w2.Body.Bytes(), &report2)
+		assert.Nil(t, err)
+		assert.Len(t, report2.Issues, 1)
+		assert.True(t, report2.Repaired)
+
+		sameTransaction, err := GetTransaction(newTransaction.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(10), sameTransaction.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Create transaction without fee applies the fee rule of the account", func(t *testing.T) {
+		accountFields := money_accounts.GenerateAccountFields()
+		accountFields.FeeRule = &money_accounts.FeeRule{Kind: money_accounts.PercentageFee, Rate: money.MustParseRate("0.01"), Min: money.FromUnits(1)}
+		feeAccount, err := money_accounts.CreateMoneyAccount(accountFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		body := fmt.Sprintf(`{"account_id": "%v", "date": "2023-01-01T00:00:00Z", "amount": 50, "description": "cash"}`, feeAccount.ID)
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/transaction_to_pending_bill/"+person.ID.String(), bytes.NewBufferString(body))
+		assert.Nil(t, err)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		newTransaction := Transaction{}
+		err = json.Unmarshal(w.Body.Bytes(), &newTransaction)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(51), newTransaction.AmountWithFee)
+		assert.Equal(t, accountFields.FeeRule, newTransaction.FeeRule)
+
+		// explicit zero fee
+		body = fmt.Sprintf(`{"account_id": "%v", "date": "2023-01-01T00:00:00Z", "amount": 50, "fee": 0, "description": "cash"}`, feeAccount.ID)
+		w2 := httptest.NewRecorder()
+		req2, err := http.NewRequest(http.MethodPost, "/transaction_to_pending_bill/"+person.ID.String(), bytes.NewBufferString(body))
+		assert.Nil(t, err)
+		router.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusCreated, w2.Code)
+
+		explicit := Transaction{}
+		err = json.Unmarshal(w2.Body.Bytes(), &explicit)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(50), explicit.AmountWithFee)
+		assert.Nil(t, explicit.FeeRule)
+
+		// editing without fee keeps the stored fee or rule
+		body = fmt.Sprintf(`{"person_id": "%v", "date": "2023-01-01T00:00:00Z", "amount": 200, "description": "cash"}`, person.ID)
+		w3 := httptest.NewRecorder()
+		req3, err := http.NewRequest(http.MethodPatch, "/transactions/"+explicit.ID.String(), bytes.NewBufferString(body))
+		assert.Nil(t, err)
+		router.ServeHTTP(w3, req3)
+		assert.Equal(t, http.StatusOK, w3.Code)
+
+		updated := Transaction{}
+		err = json.Unmarshal(w3.Body.Bytes(), &updated)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(200), updated.AmountWithFee)
+		assert.Nil(t, updated.FeeRule)
+
+		w4 := httptest.NewRecorder()
+		req4, err := http.NewRequest(http.MethodPatch, "/transactions/"+newTransaction.ID.String(), bytes.NewBufferString(body))
+		assert.Nil(t, err)
+		router.ServeHTTP(w4, req4)
+		assert.Equal(t, http.StatusOK, w4.Code)
+
+		updated = Transaction{}
+		err = json.Unmarshal(w4.Body.Bytes(), &updated)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(202), updated.AmountWithFee)
+		assert.Equal(t, accountFields.FeeRule, updated.FeeRule)
+
+		money_accounts.ResetAccountsBalance(feeAccount.ID)
+		deleteAllTransactions()
+		money_accounts.DeleteOneMoneyAccount(feeAccount.ID, time.Time{}, uuid.UUID{})
+	})
+
 	// at the end of all transactions services tests
 	money_accounts.DeleteAllMoneyAccounts()
 	persons.DeleteAllPersons()
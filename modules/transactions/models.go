@@ -5,28 +5,49 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/modules/bills"
+	"github.com/grabielcruz/transportation_back/modules/money_accounts"
+	"github.com/grabielcruz/transportation_back/money"
 )
 
 type Transaction struct {
 	ID       uuid.UUID `json:"id"`
 	PersonId uuid.UUID `json:"person_id"`
 	TransactionFields
-	AmountWithFee float64   `json:"amount_with_fee"`
-	Currency      string    `json:"currency"`
-	PersonName    string    `json:"person_name"`
-	Balance       float64   `json:"balance"`
-	PendingBillId uuid.UUID `json:"pending_bill_id"`
-	ClosedBillId  uuid.UUID `json:"closed_bill_id"`
-	RevertBillId  uuid.UUID `json:"revert_bill_id"`
+	AmountWithFee money.Money `json:"amount_with_fee"`
+	Currency      string      `json:"currency"`
+	PersonName    string      `json:"person_name"`
+	Balance       money.Money `json:"balance"`
+	PendingBillId uuid.UUID   `json:"pending_bill_id"`
+	ClosedBillId  uuid.UUID   `json:"closed_bill_id"`
+	RevertBillId  uuid.UUID   `json:"revert_bill_id"`
+	// rule of the account used to compute the fee, nil when the fee was explicit
+	FeeRule *money_accounts.FeeRule `json:"fee_rule"`
 	common.Timestamps
 }
 
 type TransactionFields struct {
-	AccountId   uuid.UUID `json:"account_id"`
-	Date        time.Time `json:"date"`
-	Amount      float64   `json:"amount"`
-	Fee         float64   `json:"fee"`
-	Description string    `json:"description"`
+	AccountId   uuid.UUID   `json:"account_id"`
+	Date        time.Time   `json:"date"`
+	Amount      money.Money `json:"amount"`
+	Fee         money.Rate  `json:"fee"`
+	Description string      `json:"description"`
+	// when true Fee is ignored and the fee rule of the account is applied,
+	// handlers set it when the request has no fee
+	ApplyFeeRule bool `json:"-"`
+}
+
+// TransactionUpdateFields are the fields that can be edited in a registered transaction,
+// the account of a transaction can not be changed
+type TransactionUpdateFields struct {
+	PersonId    uuid.UUID   `json:"person_id"`
+	Date        time.Time   `json:"date"`
+	Amount      money.Money `json:"amount"`
+	Fee         money.Rate  `json:"fee"`
+	Description string      `json:"description"`
+	// when true Fee is ignored and the transaction keeps its stored fee or fee rule,
+	// the handler sets it when the request has no fee
+	KeepFee bool `json:"-"`
 }
 
 type TransationResponse struct {
@@ -34,6 +55,74 @@ type TransationResponse struct {
 	common.Pagination
 }
 
+// ClosedBillResponse is returned when a pending bill is settled by a transaction
+// or when a closed bill is reverted
+type ClosedBillResponse struct {
+	Transaction Transaction `json:"transaction"`
+	ClosedBill  bills.Bill  `json:"closed_bill"`
+	// the remainder of a bill that was not completed, or the bill opened again after a revert.
+	// Otherwise it has zero uuid
+	PendingBill bills.Bill `json:"pending_bill"`
+}
+
+// Transfer links the two transactions that move money from one account to another
+type Transfer struct {
+	ID uuid.UUID `json:"id"`
+	TransferFields
+	CreditedAmount    money.Money `json:"credited_amount"`
+	FromTransactionId uuid.UUID   `json:"from_transaction_id"`
+	ToTransactionId   uuid.UUID   `json:"to_transaction_id"`
+	common.Timestamps
+}
+
+// TransferFields holds the data of a transfer. Amount is always positive and it is debited from the first account,
+// the fee is charged on that side. Rate is only required when the accounts have different currencies,
+// the second account is credited with amount * rate
+type TransferFields struct {
+	FromAccountId uuid.UUID   `json:"from_account_id"`
+	ToAccountId   uuid.UUID   `json:"to_account_id"`
+	Date          time.Time   `json:"date"`
+	Amount        money.Money `json:"amount"`
+	Fee           money.Rate  `json:"fee"`
+	Rate          money.Rate  `json:"rate"`
+	Description   string      `json:"description"`
+}
+
+type TransferResponse struct {
+	Transfer        Transfer    `json:"transfer"`
+	FromTransaction Transaction `json:"from_transaction"`
+	ToTransaction   Transaction `json:"to_transaction"`
+}
+
+// Kinds of inconsistencies found by CheckLedger
+const (
+	// stored balance of a transaction differs from the one recomputed from the previous transactions
+	TransactionBalanceIssue = "TRANSACTION_BALANCE"
+	// balance of an account differs from the balance of its last transaction
+	AccountBalanceIssue = "ACCOUNT_BALANCE"
+)
+
+type LedgerIssue struct {
+	Kind          string      `json:"kind"`
+	AccountId     uuid.UUID   `json:"account_id"`
+	TransactionId uuid.UUID   `json:"transaction_id"`
+	Stored        money.Money `json:"stored"`
+	Expected      money.Money `json:"expected"`
+}
+
+// ledgerBalance is the state of a transaction or an account recorded in the audit log when its balance is repaired
+type ledgerBalance struct {
+	Balance money.Money `json:"balance"`
+}
+
+type LedgerReport struct {
+	Accounts     int           `json:"accounts"`
+	Transactions int           `json:"transactions"`
+	Issues       []LedgerIssue `json:"issues"`
+	// true when the issues were fixed
+	Repaired bool `json:"repaired"`
+}
+
 type badTransactionFields struct {
 	AccountId   uuid.UUID `json:"account_id"`
 	PersonId    uuid.UUID `json:"person_id"`
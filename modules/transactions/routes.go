@@ -1,8 +1,7 @@
 package transactions
 
 import (
-	"net/http"
-
+	"github.com/grabielcruz/transportation_back/modules/idempotency"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -11,14 +10,22 @@ func Routes(router *httprouter.Router) {
 	router.GET("/transaction/:transaction_id", GetTransactionHandler)
 
 	// always should have a person id none zero uuid, otherwise it will throw an error
-	router.POST("/transaction_to_pending_bill/:person_id", CreateTransactionHandler)
+	router.POST("/transaction_to_pending_bill/:person_id", idempotency.Handle(CreateTransactionHandler))
 
-	router.POST("/close_pending_bill/:bill_id/:completed", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {})
-	router.POST("/revert_closed_bill/:bill_id", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {})
+	router.POST("/close_pending_bill/:bill_id/:completed", idempotency.Handle(ClosePendingBillHandler))
+	router.POST("/partial_payment/:bill_id", idempotency.Handle(PartialPaymentHandler))
+	router.POST("/revert_closed_bill/:bill_id", idempotency.Handle(RevertClosedBillHandler))
 	// router.POST("/transactions/:person_id", CreateTransactionHandler)
 	// router.POST("/revert_pending_bill/:bill_id", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {})
 
-	// router.PATCH("/transactions/:transaction_id", UpdateLastTransactionHandler)
-	router.DELETE("/transactions", DeleteLastTransactionHandler)
+	router.PATCH("/transactions/:transaction_id", UpdateTransactionHandler)
+	router.DELETE("/transactions/:transaction_id", DeleteTransactionHandler)
+	router.DELETE("/last_transaction/:account_id", DeleteLastAccountTransactionHandler)
+
+	router.POST("/transfers", idempotency.Handle(CreateTransferHandler))
+	router.GET("/transfers/:transfer_id", GetTransferHandler)
+
+	router.GET("/admin/ledger_check", CheckLedgerHandler)
+	router.POST("/admin/ledger_repair", RepairLedgerHandler)
 
 }
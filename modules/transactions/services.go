@@ -1,15 +1,20 @@
 package transactions
 
 import (
+	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
 	"github.com/grabielcruz/transportation_back/modules/bills"
+	"github.com/grabielcruz/transportation_back/modules/closed_periods"
+	"github.com/grabielcruz/transportation_back/modules/config"
 	"github.com/grabielcruz/transportation_back/modules/money_accounts"
 	"github.com/grabielcruz/transportation_back/modules/persons"
-	"github.com/grabielcruz/transportation_back/utility"
+	"github.com/grabielcruz/transportation_back/money"
 )
 
 func GetTransactions(account_id uuid.UUID, limit int, offset int) (TransationResponse, error) {
@@ -36,7 +41,7 @@ func GetTransactions(account_id uuid.UUID, limit int, offset int) (TransationRes
 
 	for rows.Next() {
 		t := Transaction{}
-		err = rows.Scan(&t.ID, &t.AccountId, &t.PersonId, &t.Date, &t.Amount, &t.Fee, &t.AmountWithFee, &t.Description, &t.Balance, &t.PendingBillId, &t.ClosedBillId, &t.RevertBillId, &t.CreatedAt, &t.UpdatedAt)
+		err = rows.Scan(&t.ID, &t.AccountId, &t.PersonId, &t.Date, &t.Amount, &t.Fee, &t.AmountWithFee, &t.Description, &t.Balance, &t.PendingBillId, &t.ClosedBillId, &t.RevertBillId, &t.CreatedAt, &t.UpdatedAt, &t.FeeRule)
 		if err != nil {
 			tx.Rollback()
 			return transactionResponse, fmt.Errorf(errors_handler.DB005)
@@ -67,7 +72,7 @@ func GetTransactions(account_id uuid.UUID, limit int, offset int) (TransationRes
 // will always creates a pending bill when the property block_zero_person is set to true,
 // otherwise it should register a transaction with zero person uuid, and it will not create a new pending bill
 // This function is used by two separate handlers
-func CreateTransaction(fields TransactionFields, person_id uuid.UUID, block_zero_person bool) (Transaction, error) {
+func CreateTransaction(fields TransactionFields, person_id uuid.UUID, block_zero_person bool, actor_id uuid.UUID) (Transaction, error) {
 	tr := Transaction{}
 
 	if fields.AccountId == (uuid.UUID{}) {
@@ -78,53 +83,331 @@ func CreateTransaction(fields TransactionFields, person_id uuid.UUID, block_zero
 		return tr, fmt.Errorf(errors_handler.TR007)
 	}
 
-	if fields.Amount == float64(0) {
-		return tr, fmt.Errorf(errors_handler.TR008)
+	if err := checkTransactionValues(fields); err != nil {
+		return tr, err
 	}
 
-	if fields.Fee < float64(0) || fields.Fee > float64(1) {
-		return tr, fmt.Errorf(errors_handler.TR009)
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return tr, fmt.Errorf(errors_handler.DB002)
 	}
 
-	var oldBalance float64 = 0
-	var updatedBalance float64 = 0
+	tr, err = insertTransaction(tx, fields, person_id, nil)
+	if err != nil {
+		tx.Rollback()
+		return tr, err
+	}
+
+	// create pending bill from transaction
+	billFields := bills.BillFields{
+		PersonId:            tr.PersonId,
+		Date:                tr.Date,
+		Description:         tr.Description,
+		Currency:            tr.Currency,
+		Amount:              tr.Amount,
+		ParentTransactionId: tr.ID,
+		ParentBillCrossId:   uuid.UUID{},
+	}
+
+	pendingBill, err := bills.InsertPendingBill(tx, billFields)
+	if err != nil {
+		tx.Rollback()
+		return tr, err
+	}
+
+	row := tx.QueryRow("UPDATE transactions SET pending_bill_id = $1 WHERE id = $2 RETURNING pending_bill_id;", pendingBill.ID, tr.ID)
+	err = row.Scan(&tr.PendingBillId)
+	if err != nil {
+		tx.Rollback()
+		return tr, errors_handler.MapDBErrors(err)
+	}
+
+	_, err = audit_log.Record(tx, actor_id, audit_log.TransactionEntity, tr.ID.String(), audit_log.CreateAction, nil, tr)
+	if err != nil {
+		tx.Rollback()
+		return tr, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return tr, fmt.Errorf(errors_handler.DB003)
+	}
+
+	return tr, nil
+}
+
+// ClosePendingBill registers a transaction that settles the pending bill with bill_id, the transaction
+// should be in the same currency and have the same sign of the bill.
+// When completed is true the whole bill is moved to closed bills, no matter the amount of the transaction,
+// otherwise only the amount of the transaction is closed and the remainder keeps pending
+func ClosePendingBill(bill_id uuid.UUID, fields TransactionFields, completed bool, actor_id uuid.UUID) (ClosedBillResponse, error) {
+	response := ClosedBillResponse{}
+
+	if fields.AccountId == (uuid.UUID{}) {
+		return response, fmt.Errorf(errors_handler.TR001)
+	}
+
+	if err := checkTransactionValues(fields); err != nil {
+		return response, err
+	}
 
 	tx, err := database.DB.Begin()
 	if err != nil {
 		tx.Rollback()
-		return tr, fmt.Errorf(errors_handler.DB002)
+		return response, fmt.Errorf(errors_handler.DB002)
 	}
-	row := tx.QueryRow(`SELECT balance FROM money_accounts WHERE id = $1;`, fields.AccountId)
-	err = row.Scan(&oldBalance)
+
+	pendingBill, err := bills.GetPendingBillForUpdate(tx, bill_id)
 	if err != nil {
 		tx.Rollback()
-		return tr, fmt.Errorf(errors_handler.TR001)
+		return response, err
+	}
+	// the closed bill and the remainder keep the date of the pending bill
+	if err := closed_periods.CheckOpenPeriod(tx, uuid.UUID{}, pendingBill.Date); err != nil {
+		tx.Rollback()
+		return response, err
+	}
+
+	currency, err := money_accounts.GetAccountsCurrency(fields.AccountId)
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.TR001)
+	}
+	if currency != pendingBill.Currency {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.TR010)
+	}
+
+	amount := fields.Amount
+	if amount.Sign() != pendingBill.Amount.Sign() {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.BL004)
+	}
+	if amount.Abs() > pendingBill.Amount.Abs() {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.BL005)
 	}
-	amount := utility.RoundToTwoDecimalPlaces(fields.Amount)
-	fee := utility.RoundToTwoDecimalPlaces(fields.Fee)
-	amountWithFee := amount * (1 + fee)
-	newBalance := utility.RoundToTwoDecimalPlaces(oldBalance + utility.RoundToTwoDecimalPlaces(amountWithFee))
-	if newBalance < 0 {
+
+	response.Transaction, err = insertTransaction(tx, fields, pendingBill.PersonId, nil)
+	if err != nil {
 		tx.Rollback()
-		return tr, fmt.Errorf(errors_handler.TR002)
+		return response, err
+	}
+
+	remainder := pendingBill.Amount - amount
+	closedFields := pendingBill.BillFields
+	closed_bill_id := pendingBill.ID
+
+	if completed || remainder == 0 {
+		err = bills.RemovePendingBill(tx, pendingBill.ID)
+		if err != nil {
+			tx.Rollback()
+			return response, err
+		}
+	} else {
+		// the paid portion is closed under a new id, the pending bill keeps the remainder
+		closed_bill_id, err = uuid.NewRandom()
+		if err != nil {
+			tx.Rollback()
+			return response, err
+		}
+		closedFields.Amount = amount
+		response.PendingBill, err = bills.SetPendingBillAmount(tx, pendingBill.ID, remainder)
+		if err != nil {
+			tx.Rollback()
+			return response, err
+		}
+	}
+
+	response.ClosedBill, err = bills.InsertClosedBill(tx, closed_bill_id, closedFields, bills.SolvedStatus, response.Transaction.ID, uuid.UUID{}, pendingBill.ID)
+	if err != nil {
+		tx.Rollback()
+		return response, err
+	}
+
+	row := tx.QueryRow("UPDATE transactions SET closed_bill_id = $1 WHERE id = $2 RETURNING closed_bill_id;", closed_bill_id, response.Transaction.ID)
+	err = row.Scan(&response.Transaction.ClosedBillId)
+	if err != nil {
+		tx.Rollback()
+		return response, errors_handler.MapDBErrors(err)
+	}
+
+	err = recordClosedBillResponse(tx, actor_id, bill_id, audit_log.CloseAction, pendingBill, response)
+	if err != nil {
+		tx.Rollback()
+		return response, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return response, fmt.Errorf(errors_handler.DB003)
+	}
+
+	return response, nil
+}
+
+// RevertClosedBill registers a transaction that compensates the one that solved the closed bill with bill_id,
+// marks the closed bill as reverted and opens again an equivalent pending bill, so the debt comes back
+func RevertClosedBill(bill_id uuid.UUID, actor_id uuid.UUID) (ClosedBillResponse, error) {
+	response := ClosedBillResponse{}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.DB002)
+	}
+
+	closedBill, err := bills.GetClosedBillForUpdate(tx, bill_id)
+	if err != nil {
+		tx.Rollback()
+		return response, err
+	}
+	if closedBill.Status != bills.SolvedStatus || closedBill.TransactionId == (uuid.UUID{}) {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.BL006)
+	}
+	// the closed bill is marked as reverted and opened again with its own date
+	if err := closed_periods.CheckOpenPeriod(tx, uuid.UUID{}, closedBill.Date); err != nil {
+		tx.Rollback()
+		return response, err
+	}
+
+	st := Transaction{} // solving transaction
+	row := tx.QueryRow("SELECT * FROM transactions WHERE id = $1;", closedBill.TransactionId)
+	err = row.Scan(&st.ID, &st.AccountId, &st.PersonId, &st.Date, &st.Amount, &st.Fee, &st.AmountWithFee, &st.Description, &st.Balance, &st.PendingBillId, &st.ClosedBillId, &st.RevertBillId, &st.CreatedAt, &st.UpdatedAt, &st.FeeRule)
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.DB001)
+	}
+
+	revertFields := TransactionFields{
+		AccountId:   st.AccountId,
+		Date:        time.Now(),
+		Amount:      st.Amount * -1,
+		Fee:         st.Fee,
+		Description: "Revert: " + st.Description,
+	}
+	// the reversal cancels exactly what the solving transaction moved, even when the fee rule of the account changed since
+	reversed := &charge{fee: st.Fee, amountWithFee: st.AmountWithFee * -1, rule: st.FeeRule}
+	response.Transaction, err = insertTransaction(tx, revertFields, st.PersonId, reversed)
+	if err != nil {
+		tx.Rollback()
+		return response, err
+	}
+
+	row = tx.QueryRow("UPDATE transactions SET revert_bill_id = $1 WHERE id = $2 RETURNING revert_bill_id;", closedBill.ID, response.Transaction.ID)
+	err = row.Scan(&response.Transaction.RevertBillId)
+	if err != nil {
+		tx.Rollback()
+		return response, errors_handler.MapDBErrors(err)
+	}
+
+	response.ClosedBill, err = bills.SetClosedBillReverted(tx, closedBill.ID, response.Transaction.ID)
+	if err != nil {
+		tx.Rollback()
+		return response, err
+	}
+
+	// the debt comes back as a new pending bill with the same parents
+	response.PendingBill, err = bills.InsertPendingBill(tx, closedBill.BillFields)
+	if err != nil {
+		tx.Rollback()
+		return response, err
+	}
+
+	_, err = tx.Exec("UPDATE transactions SET pending_bill_id = $1 WHERE id = $2 AND id <> $3 AND pending_bill_id = $3;", response.PendingBill.ID, closedBill.ParentTransactionId, uuid.UUID{})
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.DB009)
+	}
+
+	err = recordClosedBillResponse(tx, actor_id, bill_id, audit_log.RevertAction, closedBill, response)
+	if err != nil {
+		tx.Rollback()
+		return response, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return response, fmt.Errorf(errors_handler.DB003)
+	}
+
+	return response, nil
+}
+
+// recordClosedBillResponse records the transaction created to close or revert the bill and the change of the bill
+func recordClosedBillResponse(tx *sql.Tx, actor_id uuid.UUID, bill_id uuid.UUID, action string, before bills.Bill, response ClosedBillResponse) error {
+	_, err := audit_log.Record(tx, actor_id, audit_log.TransactionEntity, response.Transaction.ID.String(), audit_log.CreateAction, nil, response.Transaction)
+	if err != nil {
+		return err
+	}
+	_, err = audit_log.Record(tx, actor_id, audit_log.BillEntity, bill_id.String(), action, before, response)
+	return err
+}
+
+// charge is the fee of a transaction as it is stored
+type charge struct {
+	fee           money.Rate
+	amountWithFee money.Money
+	rule          *money_accounts.FeeRule
+}
+
+// insertTransaction registers a transaction inside the given database transaction and updates the balance
+// of its account. It does not create any bill, callers are in charge of it.
+// When stored is not nil its fee is registered as is, instead of computing it from fields
+func insertTransaction(tx *sql.Tx, fields TransactionFields, person_id uuid.UUID, stored *charge) (Transaction, error) {
+	tr := Transaction{}
+	oldBalance := money.Zero
+	updatedBalance := money.Zero
+
+	if err := closed_periods.CheckOpenPeriod(tx, fields.AccountId, fields.Date); err != nil {
+		return tr, err
+	}
+
+	var rule *money_accounts.FeeRule
+	line := creditLine{}
+	// the account is locked like in lockCreditLine, so every writer of its balance waits for the previous one
+	row := tx.QueryRow(`SELECT balance, credit_limit, fee_rule FROM money_accounts WHERE id = $1 FOR UPDATE;`, fields.AccountId)
+	err := row.Scan(&oldBalance, &line.limit, &rule)
+	if err != nil {
+		return tr, fmt.Errorf(errors_handler.TR001)
+	}
+	line.balance = oldBalance
+	fee := fields.Fee
+	amountWithFee := fields.Amount.WithFee(fee, config.FeeRounding)
+	switch {
+	case stored != nil:
+		fee, amountWithFee, rule = stored.fee, stored.amountWithFee, stored.rule
+	case !fields.ApplyFeeRule:
+		rule = nil
+	default:
+		fee = money.Rate{}
+		amountWithFee = fields.Amount
+		if rule != nil {
+			amountWithFee += rule.Fee(fields.Amount)
+		}
+	}
+	newBalance := oldBalance + amountWithFee
+	if err := line.check(newBalance); err != nil {
+		return tr, err
 	}
 
 	row = tx.QueryRow(`UPDATE money_accounts SET balance = $1 WHERE id = $2 RETURNING balance;`, newBalance, fields.AccountId)
 	err = row.Scan(&updatedBalance)
 	if err != nil {
-		tx.Rollback()
 		return tr, fmt.Errorf(errors_handler.TR005)
 	}
 
 	if newBalance != updatedBalance {
-		tx.Rollback()
 		return tr, fmt.Errorf(errors_handler.TR006, oldBalance, newBalance, updatedBalance)
 	}
 
-	row = tx.QueryRow(`INSERT INTO transactions (account_id, person_id, date, amount, fee, amount_with_fee, description, balance) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *;`, fields.AccountId, person_id, fields.Date, fields.Amount, fields.Fee, amountWithFee, fields.Description, updatedBalance)
-	err = row.Scan(&tr.ID, &tr.AccountId, &tr.PersonId, &tr.Date, &tr.Amount, &tr.Fee, &tr.AmountWithFee, &tr.Description, &tr.Balance, &tr.PendingBillId, &tr.ClosedBillId, &tr.RevertBillId, &tr.CreatedAt, &tr.UpdatedAt)
+	// the balances are chained in created_at order, it is taken with clock_timestamp() once the account is locked,
+	// because NOW() is the start of tx and a write that waited for the lock would be placed before the one it chained from
+	row = tx.QueryRow(`INSERT INTO transactions (account_id, person_id, date, amount, fee, amount_with_fee, description, balance, fee_rule, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, clock_timestamp()) RETURNING *;`, fields.AccountId, person_id, fields.Date, fields.Amount, fee, amountWithFee, fields.Description, updatedBalance, rule)
+	err = row.Scan(&tr.ID, &tr.AccountId, &tr.PersonId, &tr.Date, &tr.Amount, &tr.Fee, &tr.AmountWithFee, &tr.Description, &tr.Balance, &tr.PendingBillId, &tr.ClosedBillId, &tr.RevertBillId, &tr.CreatedAt, &tr.UpdatedAt, &tr.FeeRule)
 	if err != nil {
-		tx.Rollback()
 		return tr, fmt.Errorf(errors_handler.DB007)
 	}
 
@@ -136,33 +419,19 @@ func CreateTransaction(fields TransactionFields, person_id uuid.UUID, block_zero
 	if err != nil {
 		errors_handler.HandleError(err)
 	}
-	// create pending bill from transaction
-	billFields := bills.BillFields{
-		PersonId:            tr.PersonId,
-		Date:                tr.Date,
-		Description:         tr.Description,
-		Currency:            tr.Currency,
-		Amount:              tr.Amount,
-		ParentTransactionId: tr.ID,
-		ParentBillCrossId:   uuid.UUID{},
-	}
+	return tr, nil
+}
 
-	bill_id := uuid.UUID{}
-	row = tx.QueryRow("INSERT INTO pending_bills (person_id, date, description, currency, amount, parent_transaction_id, parent_bill_cross_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;", billFields.PersonId, billFields.Date, billFields.Description, billFields.Currency, billFields.Amount, billFields.ParentTransactionId, billFields.ParentBillCrossId)
-	err = row.Scan(&bill_id)
-	if err != nil {
-		return tr, errors_handler.MapDBErrors(err)
+// checkTransactionValues validates the amount and the fee of a transaction before touching the database
+func checkTransactionValues(fields TransactionFields) error {
+	if fields.Amount == money.Zero {
+		return fmt.Errorf(errors_handler.TR008)
 	}
 
-	row = tx.QueryRow("UPDATE transactions SET pending_bill_id = $1 WHERE id = $2 RETURNING pending_bill_id;", bill_id, tr.ID)
-	err = row.Scan(&tr.PendingBillId)
-	if err != nil {
-		return tr, errors_handler.MapDBErrors(err)
+	if fields.Fee.Sign() < 0 || fields.Fee.Cmp(money.OneRate) > 0 {
+		return fmt.Errorf(errors_handler.TR009)
 	}
-
-	tx.Commit()
-
-	return tr, nil
+	return nil
 }
 
 func GetTransaction(transaction_id uuid.UUID) (Transaction, error) {
@@ -174,7 +443,7 @@ func GetTransaction(transaction_id uuid.UUID) (Transaction, error) {
 		return t, fmt.Errorf(errors_handler.DB001)
 	}
 	row := database.DB.QueryRow("SELECT * FROM transactions WHERE id = $1;", transaction_id)
-	err := row.Scan(&t.ID, &t.AccountId, &t.PersonId, &t.Date, &t.Amount, &t.Fee, &t.AmountWithFee, &t.Description, &t.Balance, &t.PendingBillId, &t.ClosedBillId, &t.RevertBillId, &t.CreatedAt, &t.UpdatedAt)
+	err := row.Scan(&t.ID, &t.AccountId, &t.PersonId, &t.Date, &t.Amount, &t.Fee, &t.AmountWithFee, &t.Description, &t.Balance, &t.PendingBillId, &t.ClosedBillId, &t.RevertBillId, &t.CreatedAt, &t.UpdatedAt, &t.FeeRule)
 	if err != nil {
 		return t, fmt.Errorf(errors_handler.DB001)
 	}
@@ -189,55 +458,627 @@ func GetTransaction(transaction_id uuid.UUID) (Transaction, error) {
 	return t, nil
 }
 
-func DeleteLastTransaction() (Transaction, error) {
-	lT := Transaction{} // last transaction
-	updatedBalance := float64(0)
+// UpdateTransaction edits a registered transaction, recomputing its amount with fee and the running balance
+// of it and every later transaction of the same account, as well as the account's balance.
+// The whole edit is rejected when any of those balances turns negative.
+// The pending bill generated by the transaction, if any, is kept in sync.
+// With KeepFee the stored fee, or the fee rule stored with the transaction, is applied to the new amount,
+// so an edit that does not send the fee never picks up a later change of the account's rule
+func UpdateTransaction(transaction_id uuid.UUID, fields TransactionUpdateFields, actor_id uuid.UUID) (Transaction, error) {
+	t := Transaction{}
+	if transaction_id == (uuid.UUID{}) {
+		return t, fmt.Errorf(errors_handler.DB001)
+	}
+	if fields.Amount == money.Zero {
+		return t, fmt.Errorf(errors_handler.TR008)
+	}
+	if fields.Fee.Sign() < 0 || fields.Fee.Cmp(money.OneRate) > 0 {
+		return t, fmt.Errorf(errors_handler.TR009)
+	}
+	// every field is overwritten, a missing date or person is not taken as unchanged
+	if fields.Date.IsZero() {
+		return t, fmt.Errorf(errors_handler.TR014)
+	}
+	if fields.PersonId == (uuid.UUID{}) {
+		return t, fmt.Errorf(errors_handler.TR007)
+	}
 
 	tx, err := database.DB.Begin()
 	if err != nil {
 		tx.Rollback()
-		return lT, fmt.Errorf(errors_handler.DB002)
+		return t, fmt.Errorf(errors_handler.DB002)
+	}
+
+	row := tx.QueryRow("SELECT * FROM transactions WHERE id = $1 FOR UPDATE;", transaction_id)
+	err = row.Scan(&t.ID, &t.AccountId, &t.PersonId, &t.Date, &t.Amount, &t.Fee, &t.AmountWithFee, &t.Description, &t.Balance, &t.PendingBillId, &t.ClosedBillId, &t.RevertBillId, &t.CreatedAt, &t.UpdatedAt, &t.FeeRule)
+	if err != nil {
+		tx.Rollback()
+		return t, fmt.Errorf(errors_handler.DB001)
+	}
+	before := t
+	if t.ClosedBillId != (uuid.UUID{}) || t.RevertBillId != (uuid.UUID{}) {
+		tx.Rollback()
+		return t, fmt.Errorf(errors_handler.TR011)
+	}
+	// once its bill is partially or fully paid the pending bill only holds the remainder,
+	// the new amount could not be split between the settled part and the remainder
+	closedChildren := 0
+	row = tx.QueryRow("SELECT COUNT(*) FROM closed_bills WHERE parent_transaction_id = $1 OR (origin_bill_id = $2 AND origin_bill_id <> $3);", t.ID, t.PendingBillId, uuid.UUID{})
+	err = row.Scan(&closedChildren)
+	if err != nil {
+		tx.Rollback()
+		return t, fmt.Errorf(errors_handler.DB004)
+	}
+	if closedChildren > 0 {
+		tx.Rollback()
+		return t, fmt.Errorf(errors_handler.TR011)
+	}
+	transferSide, err := isTransferTransaction(tx, t.ID)
+	if err != nil {
+		tx.Rollback()
+		return t, err
+	}
+	if transferSide {
+		tx.Rollback()
+		return t, fmt.Errorf(errors_handler.TR013)
+	}
+	// neither the old date nor the new one can be in a closed period
+	for _, date := range []time.Time{t.Date, fields.Date} {
+		if err := closed_periods.CheckOpenPeriod(tx, t.AccountId, date); err != nil {
+			tx.Rollback()
+			return t, err
+		}
 	}
 
-	row := tx.QueryRow("DELETE FROM transactions WHERE id in (SELECT id FROM transactions WHERE id <> $1 ORDER BY created_at DESC LIMIT 1) RETURNING *;", uuid.UUID{})
-	err = row.Scan(&lT.ID, &lT.AccountId, &lT.PersonId, &lT.Date, &lT.Amount, &lT.Fee, &lT.AmountWithFee, &lT.Description, &lT.Balance, &lT.PendingBillId, &lT.ClosedBillId, &lT.RevertBillId, &lT.CreatedAt, &lT.UpdatedAt)
+	// locks the account, so no other transaction is registered meanwhile
+	line, err := lockCreditLine(tx, t.AccountId)
 	if err != nil {
 		tx.Rollback()
-		return lT, fmt.Errorf(errors_handler.DB001)
+		return t, err
 	}
 
-	newBalance := utility.RoundToTwoDecimalPlaces(lT.Balance - lT.AmountWithFee)
-	// This should never happend
-	if newBalance < 0 {
+	amount := fields.Amount
+	fee := fields.Fee
+	var rule *money_accounts.FeeRule
+	if fields.KeepFee {
+		fee, rule = t.Fee, t.FeeRule
+	}
+	amountWithFee := amount.WithFee(fee, config.FeeRounding)
+	if rule != nil {
+		amountWithFee = amount + rule.Fee(amount)
+	}
+	previousBalance := t.Balance - t.AmountWithFee
+	newBalance := previousBalance + amountWithFee
+	if err := line.check(newBalance); err != nil {
 		tx.Rollback()
-		return lT, fmt.Errorf(errors_handler.TR002)
+		return t, err
 	}
 
-	row = tx.QueryRow(`UPDATE money_accounts SET balance = $1 WHERE id = $2 RETURNING balance;`, newBalance, lT.AccountId)
-	err = row.Scan(&updatedBalance)
+	row = tx.QueryRow("UPDATE transactions SET person_id = $1, date = $2, amount = $3, fee = $4, amount_with_fee = $5, description = $6, balance = $7, fee_rule = $8, updated_at = $9 WHERE id = $10 RETURNING *;", fields.PersonId, fields.Date, amount, fee, amountWithFee, fields.Description, newBalance, rule, time.Now(), t.ID)
+	err = row.Scan(&t.ID, &t.AccountId, &t.PersonId, &t.Date, &t.Amount, &t.Fee, &t.AmountWithFee, &t.Description, &t.Balance, &t.PendingBillId, &t.ClosedBillId, &t.RevertBillId, &t.CreatedAt, &t.UpdatedAt, &t.FeeRule)
+	if err != nil {
+		tx.Rollback()
+		return t, errors_handler.MapDBErrors(err)
+	}
+
+	finalBalance, err := rechainBalances(tx, t.AccountId, t.CreatedAt, newBalance, line)
+	if err != nil {
+		tx.Rollback()
+		return t, err
+	}
+
+	_, err = tx.Exec("UPDATE money_accounts SET balance = $1 WHERE id = $2;", finalBalance, t.AccountId)
+	if err != nil {
+		tx.Rollback()
+		return t, fmt.Errorf(errors_handler.TR005)
+	}
+
+	if t.PendingBillId != (uuid.UUID{}) {
+		billFields := bills.BillFields{
+			PersonId:    t.PersonId,
+			Date:        t.Date,
+			Description: t.Description,
+			Amount:      t.Amount,
+		}
+		_, err = bills.SetPendingBillFields(tx, t.PendingBillId, billFields)
+		if err != nil {
+			tx.Rollback()
+			return t, err
+		}
+	}
+
+	t.PersonName, err = persons.GetPersonsName(t.PersonId)
+	if err != nil {
+		errors_handler.HandleError(err)
+	}
+	t.Currency, err = money_accounts.GetAccountsCurrency(t.AccountId)
+	if err != nil {
+		errors_handler.HandleError(err)
+	}
+
+	_, err = audit_log.Record(tx, actor_id, audit_log.TransactionEntity, t.ID.String(), audit_log.UpdateAction, before, t)
+	if err != nil {
+		tx.Rollback()
+		return t, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return t, fmt.Errorf(errors_handler.DB003)
+	}
+	return t, nil
+}
+
+// rechainBalances recomputes the stored balance of every transaction of the account created after since,
+// starting from balance, none of them can go below the credit limit. It returns the balance of the last transaction
+func rechainBalances(tx *sql.Tx, account_id uuid.UUID, since time.Time, balance money.Money, line creditLine) (money.Money, error) {
+	rows, err := tx.Query("SELECT id, amount_with_fee FROM transactions WHERE account_id = $1 AND created_at > $2 ORDER BY created_at;", account_id, since)
+	if err != nil {
+		return balance, fmt.Errorf(errors_handler.DB005)
+	}
+	ids := []uuid.UUID{}
+	amounts := []money.Money{}
+	for rows.Next() {
+		id := uuid.UUID{}
+		amountWithFee := money.Zero
+		err = rows.Scan(&id, &amountWithFee)
+		if err != nil {
+			rows.Close()
+			return balance, fmt.Errorf(errors_handler.DB005)
+		}
+		ids = append(ids, id)
+		amounts = append(amounts, amountWithFee)
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		balance += amounts[i]
+		if err := line.check(balance); err != nil {
+			return balance, err
+		}
+		_, err = tx.Exec("UPDATE transactions SET balance = $1 WHERE id = $2;", balance, id)
+		if err != nil {
+			return balance, fmt.Errorf(errors_handler.DB009)
+		}
+	}
+	return balance, nil
+}
+
+// creditLine holds the balance and the credit limit of an account before an operation
+type creditLine struct {
+	balance money.Money
+	limit   money.Money
+}
+
+// check fails when balance is below the credit limit, the error tells the headroom the account had
+// before the operation
+func (c creditLine) check(balance money.Money) error {
+	if balance < -c.limit {
+		return fmt.Errorf(errors_handler.TR002, c.balance+c.limit)
+	}
+	return nil
+}
+
+// lockCreditLine locks the account, so no other transaction is registered meanwhile, and returns its credit line
+func lockCreditLine(tx *sql.Tx, account_id uuid.UUID) (creditLine, error) {
+	line := creditLine{}
+	row := tx.QueryRow("SELECT balance, credit_limit FROM money_accounts WHERE id = $1 FOR UPDATE;", account_id)
+	err := row.Scan(&line.balance, &line.limit)
+	if err != nil {
+		return line, fmt.Errorf(errors_handler.TR001)
+	}
+	return line, nil
+}
+
+// DeleteTransaction removes any transaction, its pending bill is removed as well. The balance of the later transactions
+// of the same account and the account's balance are recomputed
+func DeleteTransaction(transaction_id uuid.UUID, actor_id uuid.UUID) (Transaction, error) {
+	t := Transaction{}
+	if transaction_id == (uuid.UUID{}) {
+		return t, fmt.Errorf(errors_handler.DB001)
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return t, fmt.Errorf(errors_handler.DB002)
+	}
+
+	row := tx.QueryRow("SELECT * FROM transactions WHERE id = $1 FOR UPDATE;", transaction_id)
+	err = row.Scan(&t.ID, &t.AccountId, &t.PersonId, &t.Date, &t.Amount, &t.Fee, &t.AmountWithFee, &t.Description, &t.Balance, &t.PendingBillId, &t.ClosedBillId, &t.RevertBillId, &t.CreatedAt, &t.UpdatedAt, &t.FeeRule)
+	if err != nil {
+		tx.Rollback()
+		return t, fmt.Errorf(errors_handler.DB001)
+	}
+
+	t, err = removeTransaction(tx, t)
+	if err != nil {
+		tx.Rollback()
+		return t, err
+	}
+
+	_, err = audit_log.Record(tx, actor_id, audit_log.TransactionEntity, t.ID.String(), audit_log.DeleteAction, t, nil)
 	if err != nil {
 		tx.Rollback()
-		return lT, fmt.Errorf(errors_handler.TR005)
+		return t, err
 	}
 
-	lT.PersonName, err = persons.GetPersonsName(lT.PersonId)
+	err = tx.Commit()
+	if err != nil {
+		return t, fmt.Errorf(errors_handler.DB003)
+	}
+	return t, nil
+}
+
+// DeleteLastAccountTransaction removes the last transaction registered in the account with account_id
+func DeleteLastAccountTransaction(account_id uuid.UUID, actor_id uuid.UUID) (Transaction, error) {
+	t := Transaction{}
+	if account_id == (uuid.UUID{}) {
+		return t, fmt.Errorf(errors_handler.DB001)
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return t, fmt.Errorf(errors_handler.DB002)
+	}
+
+	row := tx.QueryRow("SELECT * FROM transactions WHERE account_id = $1 ORDER BY created_at DESC LIMIT 1 FOR UPDATE;", account_id)
+	err = row.Scan(&t.ID, &t.AccountId, &t.PersonId, &t.Date, &t.Amount, &t.Fee, &t.AmountWithFee, &t.Description, &t.Balance, &t.PendingBillId, &t.ClosedBillId, &t.RevertBillId, &t.CreatedAt, &t.UpdatedAt, &t.FeeRule)
+	if err != nil {
+		tx.Rollback()
+		return t, fmt.Errorf(errors_handler.DB001)
+	}
+
+	t, err = removeTransaction(tx, t)
+	if err != nil {
+		tx.Rollback()
+		return t, err
+	}
+
+	_, err = audit_log.Record(tx, actor_id, audit_log.TransactionEntity, t.ID.String(), audit_log.DeleteAction, t, nil)
+	if err != nil {
+		tx.Rollback()
+		return t, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return t, fmt.Errorf(errors_handler.DB003)
+	}
+	return t, nil
+}
+
+// removeTransaction deletes the transaction t inside the given database transaction and rechains
+// the balances of its account
+func removeTransaction(tx *sql.Tx, t Transaction) (Transaction, error) {
+	if t.ClosedBillId != (uuid.UUID{}) || t.RevertBillId != (uuid.UUID{}) {
+		return t, fmt.Errorf(errors_handler.TR011)
+	}
+	if err := closed_periods.CheckOpenPeriod(tx, t.AccountId, t.Date); err != nil {
+		return t, err
+	}
+
+	closedChildren := 0
+	row := tx.QueryRow("SELECT COUNT(*) FROM closed_bills WHERE parent_transaction_id = $1;", t.ID)
+	err := row.Scan(&closedChildren)
+	if err != nil {
+		return t, fmt.Errorf(errors_handler.DB004)
+	}
+	if closedChildren > 0 {
+		return t, fmt.Errorf(errors_handler.TR012)
+	}
+
+	transferSide, err := isTransferTransaction(tx, t.ID)
+	if err != nil {
+		return t, err
+	}
+	if transferSide {
+		return t, fmt.Errorf(errors_handler.TR013)
+	}
+
+	// locks the account, so no other transaction is registered meanwhile
+	line, err := lockCreditLine(tx, t.AccountId)
+	if err != nil {
+		return t, err
+	}
+
+	// pending bill is deleted on cascade
+	_, err = tx.Exec("DELETE FROM transactions WHERE id = $1;", t.ID)
+	if err != nil {
+		return t, errors_handler.MapDBErrors(err)
+	}
+
+	previousBalance := t.Balance - t.AmountWithFee
+	if err := line.check(previousBalance); err != nil {
+		return t, err
+	}
+	finalBalance, err := rechainBalances(tx, t.AccountId, t.CreatedAt, previousBalance, line)
+	if err != nil {
+		return t, err
+	}
+
+	_, err = tx.Exec("UPDATE money_accounts SET balance = $1 WHERE id = $2;", finalBalance, t.AccountId)
+	if err != nil {
+		return t, fmt.Errorf(errors_handler.TR005)
+	}
+
+	t.PersonName, err = persons.GetPersonsName(t.PersonId)
 	if err != nil {
 		errors_handler.HandleError(err)
 	}
-	lT.Currency, err = money_accounts.GetAccountsCurrency(lT.AccountId)
+	t.Currency, err = money_accounts.GetAccountsCurrency(t.AccountId)
 	if err != nil {
 		errors_handler.HandleError(err)
 	}
+	return t, nil
+}
+
+// CreateTransfer debits the amount from one account and credits it to another one in a single database transaction.
+// Both sides are registered as transactions without a person, so no pending bill is created.
+// When the accounts have different currencies the credited amount is amount * rate, otherwise rate is ignored
+func CreateTransfer(fields TransferFields, actor_id uuid.UUID) (TransferResponse, error) {
+	response := TransferResponse{}
+
+	if fields.FromAccountId == (uuid.UUID{}) || fields.ToAccountId == (uuid.UUID{}) {
+		return response, fmt.Errorf(errors_handler.TR001)
+	}
+	if fields.FromAccountId == fields.ToAccountId {
+		return response, fmt.Errorf(errors_handler.TF001)
+	}
+	amount := fields.Amount
+	if amount <= 0 {
+		return response, fmt.Errorf(errors_handler.TF002)
+	}
+	if fields.Fee.Sign() < 0 || fields.Fee.Cmp(money.OneRate) > 0 {
+		return response, fmt.Errorf(errors_handler.TR009)
+	}
+
+	fromCurrency, err := money_accounts.GetAccountsCurrency(fields.FromAccountId)
+	if err != nil {
+		return response, fmt.Errorf(errors_handler.TR001)
+	}
+	toCurrency, err := money_accounts.GetAccountsCurrency(fields.ToAccountId)
+	if err != nil {
+		return response, fmt.Errorf(errors_handler.TR001)
+	}
+
+	rate := money.OneRate
+	if fromCurrency != toCurrency {
+		if fields.Rate.Sign() <= 0 {
+			return response, fmt.Errorf(errors_handler.TF003)
+		}
+		rate = fields.Rate
+	}
+	creditedAmount := amount.MulRate(rate, money.HalfUp)
+	if creditedAmount <= 0 {
+		return response, fmt.Errorf(errors_handler.TF002)
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.DB002)
+	}
+
+	// both accounts are locked always in the same order, so opposite transfers do not deadlock
+	_, err = tx.Exec("SELECT id FROM money_accounts WHERE id IN ($1, $2) ORDER BY id FOR UPDATE;", fields.FromAccountId, fields.ToAccountId)
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.TR001)
+	}
+
+	debitFields := TransactionFields{
+		AccountId:   fields.FromAccountId,
+		Date:        fields.Date,
+		Amount:      amount * -1,
+		Fee:         fields.Fee,
+		Description: fields.Description,
+	}
+	response.FromTransaction, err = insertTransaction(tx, debitFields, uuid.UUID{}, nil)
+	if err != nil {
+		tx.Rollback()
+		return response, err
+	}
+
+	creditFields := TransactionFields{
+		AccountId:   fields.ToAccountId,
+		Date:        fields.Date,
+		Amount:      creditedAmount,
+		Fee:         money.Rate{},
+		Description: fields.Description,
+	}
+	response.ToTransaction, err = insertTransaction(tx, creditFields, uuid.UUID{}, nil)
+	if err != nil {
+		tx.Rollback()
+		return response, err
+	}
+
+	tf := Transfer{}
+	row := tx.QueryRow("INSERT INTO transfers (from_account_id, to_account_id, date, amount, fee, rate, credited_amount, description, from_transaction_id, to_transaction_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING *;", fields.FromAccountId, fields.ToAccountId, fields.Date, amount, fields.Fee, rate, creditedAmount, fields.Description, response.FromTransaction.ID, response.ToTransaction.ID)
+	err = row.Scan(&tf.ID, &tf.FromAccountId, &tf.ToAccountId, &tf.Date, &tf.Amount, &tf.Fee, &tf.Rate, &tf.CreditedAmount, &tf.Description, &tf.FromTransactionId, &tf.ToTransactionId, &tf.CreatedAt, &tf.UpdatedAt)
+	if err != nil {
+		tx.Rollback()
+		return response, fmt.Errorf(errors_handler.DB007)
+	}
+	response.Transfer = tf
+
+	_, err = audit_log.Record(tx, actor_id, audit_log.TransferEntity, tf.ID.String(), audit_log.CreateAction, nil, response)
+	if err != nil {
+		tx.Rollback()
+		return response, err
+	}
 
 	err = tx.Commit()
 	if err != nil {
-		return lT, fmt.Errorf(errors_handler.DB003)
+		return response, fmt.Errorf(errors_handler.DB003)
 	}
 
-	return lT, nil
+	return response, nil
+}
+
+func GetTransfer(transfer_id uuid.UUID) (TransferResponse, error) {
+	response := TransferResponse{}
+	tf := Transfer{}
+
+	row := database.DB.QueryRow("SELECT * FROM transfers WHERE id = $1;", transfer_id)
+	err := row.Scan(&tf.ID, &tf.FromAccountId, &tf.ToAccountId, &tf.Date, &tf.Amount, &tf.Fee, &tf.Rate, &tf.CreditedAmount, &tf.Description, &tf.FromTransactionId, &tf.ToTransactionId, &tf.CreatedAt, &tf.UpdatedAt)
+	if err != nil {
+		return response, fmt.Errorf(errors_handler.DB001)
+	}
+	response.Transfer = tf
+
+	response.FromTransaction, err = GetTransaction(tf.FromTransactionId)
+	if err != nil {
+		return response, err
+	}
+	response.ToTransaction, err = GetTransaction(tf.ToTransactionId)
+	if err != nil {
+		return response, err
+	}
+	return response, nil
+}
+
+// isTransferTransaction tells whether the transaction with transaction_id is one of the sides of a transfer
+func isTransferTransaction(tx *sql.Tx, transaction_id uuid.UUID) (bool, error) {
+	count := 0
+	row := tx.QueryRow("SELECT COUNT(*) FROM transfers WHERE from_transaction_id = $1 OR to_transaction_id = $1;", transaction_id)
+	err := row.Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf(errors_handler.DB004)
+	}
+	return count > 0, nil
+}
+
+// CheckLedger replays the transactions of every account in the order they were registered and reports
+// every stored balance that differs from the recomputed one and accounts whose balance differs from the balance
+// of their last transaction. The bill references of the transactions are enforced by foreign keys.
+// With repair the balances are overwritten with the recomputed ones, all in the same database transaction,
+// and every overwritten balance is recorded in the audit log as a write of the system
+func CheckLedger(repair bool) (LedgerReport, error) {
+	report := LedgerReport{Issues: []LedgerIssue{}}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		tx.Rollback()
+		return report, fmt.Errorf(errors_handler.DB002)
+	}
+
+	// accounts are locked so no transaction is registered while checking
+	rows, err := tx.Query("SELECT id, balance FROM money_accounts WHERE id <> $1 ORDER BY created_at FOR UPDATE;", uuid.UUID{})
+	if err != nil {
+		tx.Rollback()
+		return report, fmt.Errorf(errors_handler.DB005)
+	}
+	accounts := []money_accounts.AccountNameAndBalance{}
+	for rows.Next() {
+		a := money_accounts.AccountNameAndBalance{}
+		err = rows.Scan(&a.ID, &a.Balance)
+		if err != nil {
+			rows.Close()
+			tx.Rollback()
+			return report, fmt.Errorf(errors_handler.DB005)
+		}
+		accounts = append(accounts, a)
+	}
+	rows.Close()
+
+	for _, a := range accounts {
+		issues, count, err := checkAccountLedger(tx, a.ID, a.Balance)
+		if err != nil {
+			tx.Rollback()
+			return report, err
+		}
+		report.Accounts++
+		report.Transactions += count
+		report.Issues = append(report.Issues, issues...)
+	}
+
+	if repair && len(report.Issues) > 0 {
+		for _, issue := range report.Issues {
+			err = repairLedgerIssue(tx, issue)
+			if err != nil {
+				tx.Rollback()
+				return report, err
+			}
+		}
+		report.Repaired = true
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return report, fmt.Errorf(errors_handler.DB003)
+	}
+	return report, nil
+}
+
+// checkAccountLedger recomputes the balances of the transactions of one account, it returns the issues found
+// and the number of transactions checked
+func checkAccountLedger(tx *sql.Tx, account_id uuid.UUID, accountBalance money.Money) ([]LedgerIssue, int, error) {
+	issues := []LedgerIssue{}
+	count := 0
+	rows, err := tx.Query("SELECT id, amount_with_fee, balance FROM transactions WHERE account_id = $1 AND id <> $2 ORDER BY created_at;", account_id, uuid.UUID{})
+	if err != nil {
+		return issues, count, fmt.Errorf(errors_handler.DB005)
+	}
+	defer rows.Close()
+
+	balance := money.Zero
+	for rows.Next() {
+		id := uuid.UUID{}
+		amountWithFee := money.Zero
+		stored := money.Zero
+		err = rows.Scan(&id, &amountWithFee, &stored)
+		if err != nil {
+			return issues, count, fmt.Errorf(errors_handler.DB005)
+		}
+		count++
+		balance += amountWithFee
+		if stored != balance {
+			issues = append(issues, LedgerIssue{
+				Kind:          TransactionBalanceIssue,
+				AccountId:     account_id,
+				TransactionId: id,
+				Stored:        stored,
+				Expected:      balance,
+			})
+		}
+	}
+
+	if accountBalance != balance {
+		issues = append(issues, LedgerIssue{
+			Kind:      AccountBalanceIssue,
+			AccountId: account_id,
+			Stored:    accountBalance,
+			Expected:  balance,
+		})
+	}
+	return issues, count, nil
+}
+
+func repairLedgerIssue(tx *sql.Tx, issue LedgerIssue) error {
+	var err error
+	entity_type, entity_id := "", ""
+	switch issue.Kind {
+	case TransactionBalanceIssue:
+		_, err = tx.Exec("UPDATE transactions SET balance = $1 WHERE id = $2;", issue.Expected, issue.TransactionId)
+		entity_type, entity_id = audit_log.TransactionEntity, issue.TransactionId.String()
+	case AccountBalanceIssue:
+		_, err = tx.Exec("UPDATE money_accounts SET balance = $1 WHERE id = $2;", issue.Expected, issue.AccountId)
+		entity_type, entity_id = audit_log.MoneyAccountEntity, issue.AccountId.String()
+	}
+	if err != nil {
+		return fmt.Errorf(errors_handler.DB009)
+	}
+	_, err = audit_log.Record(tx, audit_log.SystemActor, entity_type, entity_id, audit_log.UpdateAction, ledgerBalance{issue.Stored}, ledgerBalance{issue.Expected})
+	return err
 }
 
 func deleteAllTransactions() {
+	// closed bills and transactions reference each other
+	database.DB.Exec("UPDATE transactions SET closed_bill_id = $1, revert_bill_id = $1 WHERE id <> $1;", uuid.UUID{})
+	database.DB.Exec("DELETE FROM closed_bills WHERE id <> $1;", uuid.UUID{})
+	database.DB.Exec("DELETE FROM transfers;")
 	database.DB.QueryRow("DELETE FROM transactions WHERE id <> $1;", uuid.UUID{})
 	bills.EmptyBills()
 }
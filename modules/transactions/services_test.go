@@ -1,16 +1,23 @@
 package transactions
 
 import (
+	"encoding/json"
+	"fmt"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/grabielcruz/transportation_back/database"
 	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
 	"github.com/grabielcruz/transportation_back/modules/bills"
+	"github.com/grabielcruz/transportation_back/modules/closed_periods"
 	"github.com/grabielcruz/transportation_back/modules/config"
 	"github.com/grabielcruz/transportation_back/modules/money_accounts"
 	"github.com/grabielcruz/transportation_back/modules/persons"
+	"github.com/grabielcruz/transportation_back/money"
 	"github.com/grabielcruz/transportation_back/utility"
 	"github.com/stretchr/testify/assert"
 )
@@ -21,9 +28,9 @@ func TestTransactionServices(t *testing.T) {
 	database.SetupDB(envPath)
 	database.CreateTables(sqlPath)
 	defer database.CloseConnection()
-	account, err := money_accounts.CreateMoneyAccount(money_accounts.GenerateAccountFields())
+	account, err := money_accounts.CreateMoneyAccount(money_accounts.GenerateAccountFields(), uuid.UUID{})
 	assert.Nil(t, err)
-	person, err := persons.CreatePerson(persons.GeneratePersonFields())
+	person, err := persons.CreatePerson(persons.GeneratePersonFields(), uuid.UUID{})
 	assert.Nil(t, err)
 
 	t.Run("Get transaction response with zero transactions initially", func(t *testing.T) {
@@ -39,7 +46,7 @@ func TestTransactionServices(t *testing.T) {
 
 	t.Run("Create one transaction with a person", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
-		newTransaction, err := CreateTransaction(transactionFields, person.ID, true)
+		newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
 		assert.Nil(t, err)
 		updatedAccount, err := money_accounts.GetOneMoneyAccount(newTransaction.AccountId)
 		assert.Nil(t, err)
@@ -55,7 +62,7 @@ func TestTransactionServices(t *testing.T) {
 	t.Run("Error when creating transaction with unexisting account", func(t *testing.T) {
 		zeroId := uuid.UUID{}
 		transactionFields := GenerateTransactionFields(zeroId)
-		_, err := CreateTransaction(transactionFields, zeroId, true)
+		_, err := CreateTransaction(transactionFields, zeroId, true, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.TR001, err.Error())
 	})
@@ -66,13 +73,13 @@ func TestTransactionServices(t *testing.T) {
 	t.Run("Error when generating negative balance", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
 		transactionFields.Amount *= -1
-		_, err := CreateTransaction(transactionFields, person.ID, true)
+		_, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
 		assert.NotNil(t, err)
-		assert.Equal(t, errors_handler.TR002, err.Error())
+		assert.Equal(t, fmt.Sprintf(errors_handler.TR002, money.Zero), err.Error())
 		updatedAccount, err := money_accounts.GetOneMoneyAccount(transactionFields.AccountId)
 		assert.Nil(t, err)
 		// accounts balance should remain unmodified, which means it is equal to zero
-		assert.Equal(t, float64(0), updatedAccount.Balance)
+		assert.Equal(t, money.Zero, updatedAccount.Balance)
 	})
 
 	money_accounts.ResetAccountsBalance(account.ID)
@@ -80,7 +87,7 @@ func TestTransactionServices(t *testing.T) {
 
 	t.Run("Create one transaction and get it in paginated response", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
-		newTransaction, err := CreateTransaction(transactionFields, person.ID, true)
+		newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
 		assert.Nil(t, err)
 		transactions, err := GetTransactions(account.ID, config.Limit, config.Offset)
 		assert.Nil(t, err)
@@ -95,7 +102,7 @@ func TestTransactionServices(t *testing.T) {
 
 	t.Run("Create one transaction without fee and get it with single response", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
-		newTransaction, err := CreateTransaction(transactionFields, person.ID, true)
+		newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
 		assert.Nil(t, err)
 		transaction, err := GetTransaction(newTransaction.ID)
 		assert.Nil(t, err)
@@ -113,8 +120,8 @@ func TestTransactionServices(t *testing.T) {
 		// fee stuff
 		assert.Equal(t, newTransaction.Fee, transaction.Fee)
 		assert.Equal(t, newTransaction.AmountWithFee, transaction.AmountWithFee)
-		assert.Equal(t, newTransaction.AmountWithFee, utility.RoundToTwoDecimalPlaces(newTransaction.Amount*(1+newTransaction.Fee)))
-		assert.Equal(t, transaction.AmountWithFee, utility.RoundToTwoDecimalPlaces(newTransaction.Amount*(1+newTransaction.Fee)))
+		assert.Equal(t, newTransaction.AmountWithFee, newTransaction.Amount.WithFee(newTransaction.Fee, config.FeeRounding))
+		assert.Equal(t, transaction.AmountWithFee, newTransaction.Amount.WithFee(newTransaction.Fee, config.FeeRounding))
 		// these uuids should be zero
 		assert.Equal(t, newTransaction.ClosedBillId, uuid.UUID{})
 		assert.Equal(t, newTransaction.RevertBillId, uuid.UUID{})
@@ -128,7 +135,7 @@ func TestTransactionServices(t *testing.T) {
 	t.Run("Create one transaction with fee and get it with single response", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
 		transactionFields.Fee = utility.GetRandomFee()
-		newTransaction, err := CreateTransaction(transactionFields, person.ID, true)
+		newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
 		assert.Nil(t, err)
 		transaction, err := GetTransaction(newTransaction.ID)
 		assert.Nil(t, err)
@@ -146,8 +153,8 @@ func TestTransactionServices(t *testing.T) {
 		// fee stuff
 		assert.Equal(t, newTransaction.Fee, transaction.Fee)
 		assert.Equal(t, newTransaction.AmountWithFee, transaction.AmountWithFee)
-		assert.Equal(t, newTransaction.AmountWithFee, utility.RoundToTwoDecimalPlaces(newTransaction.Amount*(1+newTransaction.Fee)))
-		assert.Equal(t, transaction.AmountWithFee, utility.RoundToTwoDecimalPlaces(newTransaction.Amount*(1+newTransaction.Fee)))
+		assert.Equal(t, newTransaction.AmountWithFee, newTransaction.Amount.WithFee(newTransaction.Fee, config.FeeRounding))
+		assert.Equal(t, transaction.AmountWithFee, newTransaction.Amount.WithFee(newTransaction.Fee, config.FeeRounding))
 		// these uuids should be zero
 		assert.Equal(t, newTransaction.ClosedBillId, uuid.UUID{})
 		assert.Equal(t, newTransaction.RevertBillId, uuid.UUID{})
@@ -160,7 +167,7 @@ func TestTransactionServices(t *testing.T) {
 
 	t.Run("It should create transaction with person zero when not blocked", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
-		newTransaction, err := CreateTransaction(transactionFields, uuid.UUID{}, false)
+		newTransaction, err := CreateTransaction(transactionFields, uuid.UUID{}, false, uuid.UUID{})
 		assert.Nil(t, err)
 		transaction, err := GetTransaction(newTransaction.ID)
 		assert.Nil(t, err)
@@ -178,8 +185,8 @@ func TestTransactionServices(t *testing.T) {
 		// fee stuff
 		assert.Equal(t, newTransaction.Fee, transaction.Fee)
 		assert.Equal(t, newTransaction.AmountWithFee, transaction.AmountWithFee)
-		assert.Equal(t, newTransaction.AmountWithFee, utility.RoundToTwoDecimalPlaces(newTransaction.Amount*(1+newTransaction.Fee)))
-		assert.Equal(t, transaction.AmountWithFee, utility.RoundToTwoDecimalPlaces(newTransaction.Amount*(1+newTransaction.Fee)))
+		assert.Equal(t, newTransaction.AmountWithFee, newTransaction.Amount.WithFee(newTransaction.Fee, config.FeeRounding))
+		assert.Equal(t, transaction.AmountWithFee, newTransaction.Amount.WithFee(newTransaction.Fee, config.FeeRounding))
 		// these uuids should be zero
 		assert.Equal(t, newTransaction.ClosedBillId, uuid.UUID{})
 		assert.Equal(t, newTransaction.RevertBillId, uuid.UUID{})
@@ -193,7 +200,7 @@ func TestTransactionServices(t *testing.T) {
 	t.Run("Error when creating transaction without a person when blocked", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
 		transactionFields.Fee = utility.GetRandomFee()
-		_, err := CreateTransaction(transactionFields, uuid.UUID{}, true)
+		_, err := CreateTransaction(transactionFields, uuid.UUID{}, true, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.TR007, err.Error())
 	})
@@ -214,24 +221,24 @@ func TestTransactionServices(t *testing.T) {
 
 	t.Run("Error when creating transaction with amount zero", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
-		transactionFields.Amount = float64(0)
-		_, err := CreateTransaction(transactionFields, person.ID, true)
+		transactionFields.Amount = money.Zero
+		_, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.TR008, err.Error())
 	})
 
 	t.Run("Error when creating transaction with negative fee", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
-		transactionFields.Fee = -0.05
-		_, err := CreateTransaction(transactionFields, person.ID, true)
+		transactionFields.Fee = money.MustParseRate("-0.05")
+		_, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.TR009, err.Error())
 	})
 
 	t.Run("Error when creating transaction with a fee greater than one", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
-		transactionFields.Fee = 1.05
-		_, err := CreateTransaction(transactionFields, person.ID, true)
+		transactionFields.Fee = money.MustParseRate("1.05")
+		_, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.TR009, err.Error())
 	})
@@ -242,9 +249,9 @@ func TestTransactionServices(t *testing.T) {
 		for _, v := range amounts {
 			personId := person.ID
 			transactionFields := GenerateTransactionFields(account.ID)
-			transactionFields.Fee = 0
+			transactionFields.Fee = money.Rate{}
 			transactionFields.Amount = v
-			_, err := CreateTransaction(transactionFields, personId, true)
+			_, err := CreateTransaction(transactionFields, personId, true, uuid.UUID{})
 			assert.Nil(t, err)
 		}
 		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
@@ -255,15 +262,39 @@ func TestTransactionServices(t *testing.T) {
 	money_accounts.ResetAccountsBalance(account.ID)
 	deleteAllTransactions()
 
+	t.Run("Execute 20 concurrent transactions and get accounts balance right", func(t *testing.T) {
+		sum := money.Zero
+		var wg sync.WaitGroup
+		for i := 1; i <= 20; i++ {
+			transactionFields := GenerateTransactionFields(account.ID)
+			transactionFields.Fee = money.Rate{}
+			transactionFields.Amount = money.FromUnits(int64(i))
+			sum += transactionFields.Amount
+			wg.Add(1)
+			go func(fields TransactionFields) {
+				defer wg.Done()
+				_, err := CreateTransaction(fields, person.ID, true, uuid.UUID{})
+				assert.Nil(t, err)
+			}(transactionFields)
+		}
+		wg.Wait()
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, sum, updatedAccount.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
 	t.Run("Execute 100 transactions with fee of 5% and get accounts balance right", func(t *testing.T) {
 		amounts := utility.GetSliceOfAmounts(100)
-		sum := utility.GetSumOfAmountsWithFee(amounts, 0.05)
+		sum := utility.GetSumOfAmountsWithFee(amounts, money.MustParseRate("0.05"))
 		for _, v := range amounts {
 			personId := person.ID
 			transactionFields := GenerateTransactionFields(account.ID)
 			transactionFields.Amount = v
-			transactionFields.Fee = 0.05
-			_, err := CreateTransaction(transactionFields, personId, true)
+			transactionFields.Fee = money.MustParseRate("0.05")
+			_, err := CreateTransaction(transactionFields, personId, true, uuid.UUID{})
 			assert.Nil(t, err)
 		}
 		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
@@ -281,7 +312,7 @@ func TestTransactionServices(t *testing.T) {
 
 			transactionFields := GenerateTransactionFields(account.ID)
 			transactionFields.Amount = v
-			_, err := CreateTransaction(transactionFields, personId, true)
+			_, err := CreateTransaction(transactionFields, personId, true, uuid.UUID{})
 			assert.Nil(t, err)
 		}
 		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
@@ -300,7 +331,7 @@ func TestTransactionServices(t *testing.T) {
 			personId := person.ID
 			transactionFields := GenerateTransactionFields(account.ID)
 			transactionFields.Amount = v
-			_, err := CreateTransaction(transactionFields, personId, true)
+			_, err := CreateTransaction(transactionFields, personId, true, uuid.UUID{})
 			assert.Nil(t, err)
 		}
 		transactions, err := GetTransactions(account.ID, config.Limit, 50)
@@ -314,8 +345,8 @@ func TestTransactionServices(t *testing.T) {
 
 	t.Run("Create one transaction without fee, it creates a pending bill. When deletion, pending bill also is deleted", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
-		transactionFields.Fee = 0
-		newTransaction, err := CreateTransaction(transactionFields, person.ID, true)
+		transactionFields.Fee = money.Rate{}
+		newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
 		assert.Nil(t, err)
 		// pending bill
 		newPendingBill, err := bills.GetOneBill(newTransaction.PendingBillId)
@@ -327,7 +358,7 @@ func TestTransactionServices(t *testing.T) {
 		assert.Equal(t, newPendingBill.Description, newTransaction.Description)
 
 		// delete
-		deletedLastTransaction, err := DeleteLastTransaction()
+		deletedLastTransaction, err := DeleteLastAccountTransaction(account.ID, uuid.UUID{})
 		assert.Nil(t, err)
 
 		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
@@ -343,7 +374,7 @@ func TestTransactionServices(t *testing.T) {
 		assert.Equal(t, newTransaction.Description, deletedLastTransaction.Description)
 		assert.Equal(t, newTransaction.PersonId, deletedLastTransaction.PersonId)
 		assert.Equal(t, newTransaction.PersonName, deletedLastTransaction.PersonName)
-		assert.Equal(t, float64(0), updatedAccount.Balance)
+		assert.Equal(t, money.Zero, updatedAccount.Balance)
 		assert.Equal(t, newTransaction.Currency, account.Currency)
 		// these uuids should be zero
 		assert.Equal(t, newTransaction.ClosedBillId, uuid.UUID{})
@@ -371,7 +402,7 @@ func TestTransactionServices(t *testing.T) {
 	t.Run("Create one transaction with fee and delete it", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
 		transactionFields.Fee = utility.GetRandomFee()
-		newTransaction, err := CreateTransaction(transactionFields, person.ID, true)
+		newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
 		assert.Nil(t, err)
 
 		// pending bill
@@ -383,7 +414,7 @@ func TestTransactionServices(t *testing.T) {
 		assert.Equal(t, newPendingBill.Date, newTransaction.Date)
 		assert.Equal(t, newPendingBill.Description, newTransaction.Description)
 
-		deletedLastTransaction, err := DeleteLastTransaction()
+		deletedLastTransaction, err := DeleteLastAccountTransaction(account.ID, uuid.UUID{})
 		assert.Nil(t, err)
 		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
 		assert.Nil(t, err)
@@ -398,7 +429,7 @@ func TestTransactionServices(t *testing.T) {
 		assert.Equal(t, newTransaction.Description, deletedLastTransaction.Description)
 		assert.Equal(t, newTransaction.PersonId, deletedLastTransaction.PersonId)
 		assert.Equal(t, newTransaction.PersonName, deletedLastTransaction.PersonName)
-		assert.Equal(t, float64(0), updatedAccount.Balance)
+		assert.Equal(t, money.Zero, updatedAccount.Balance)
 		assert.Equal(t, newTransaction.Currency, account.Currency)
 		// these uuids should be zero
 		assert.Equal(t, newTransaction.ClosedBillId, uuid.UUID{})
@@ -424,7 +455,7 @@ func TestTransactionServices(t *testing.T) {
 	deleteAllTransactions()
 
 	t.Run("Error when deleting last transaction with no transactions", func(t *testing.T) {
-		_, err := DeleteLastTransaction()
+		_, err := DeleteLastAccountTransaction(account.ID, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.DB001, err.Error())
 	})
@@ -432,10 +463,10 @@ func TestTransactionServices(t *testing.T) {
 	t.Run("Error when deleting pending bill associated with transaction", func(t *testing.T) {
 		transactionFields := GenerateTransactionFields(account.ID)
 		transactionFields.Fee = utility.GetRandomFee()
-		newTransaction, err := CreateTransaction(transactionFields, person.ID, true)
+		newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
 		assert.Nil(t, err)
 		// this deletion should be forbidden
-		_, err = bills.DeleteBill(newTransaction.PendingBillId)
+		_, err = bills.DeleteBill(newTransaction.PendingBillId, time.Time{}, uuid.UUID{})
 		assert.NotNil(t, err)
 		assert.Equal(t, errors_handler.BL003, err.Error())
 		sameTransaction, err := GetTransaction(newTransaction.ID)
@@ -467,6 +498,1362 @@ func TestTransactionServices(t *testing.T) {
 		assert.Len(t, transactions.Transactions, 1)
 	})
 
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Close pending bill completely with a transaction", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(50)
+		transactionFields.Fee = money.Rate{}
+		response, err := ClosePendingBill(pendingBill.ID, transactionFields, true, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, pendingBill.ID, response.ClosedBill.ID)
+		assert.Equal(t, bills.SolvedStatus, response.ClosedBill.Status)
+		assert.Equal(t, money.FromUnits(50), response.ClosedBill.Amount)
+		assert.Equal(t, response.Transaction.ID, response.ClosedBill.TransactionId)
+		assert.Equal(t, pendingBill.ID, response.Transaction.ClosedBillId)
+		assert.Equal(t, person.ID, response.Transaction.PersonId)
+		assert.Equal(t, uuid.UUID{}, response.Transaction.PendingBillId)
+		assert.Equal(t, uuid.UUID{}, response.PendingBill.ID)
+
+		closedBill, err := bills.GetOneBill(pendingBill.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, bills.SolvedStatus, closedBill.Status)
+		assert.Equal(t, response.Transaction.ID, closedBill.TransactionId)
+
+		billResponse, err := bills.GetPendingBills(person.ID, true, true, config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, billResponse.Count)
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(50), updatedAccount.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Close pending bill completely with a smaller transaction", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(45)
+		transactionFields.Fee = money.Rate{}
+		response, err := ClosePendingBill(pendingBill.ID, transactionFields, true, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, pendingBill.ID, response.ClosedBill.ID)
+		assert.Equal(t, money.FromUnits(50), response.ClosedBill.Amount)
+		assert.Equal(t, money.FromUnits(45), response.Transaction.Amount)
+		assert.Equal(t, uuid.UUID{}, response.PendingBill.ID)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Close pending bill partially and keep the remainder pending", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(20)
+		transactionFields.Fee = money.Rate{}
+		response, err := ClosePendingBill(pendingBill.ID, transactionFields, false, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.NotEqual(t, pendingBill.ID, response.ClosedBill.ID)
+		assert.Equal(t, money.FromUnits(20), response.ClosedBill.Amount)
+		assert.Equal(t, bills.SolvedStatus, response.ClosedBill.Status)
+		assert.Equal(t, response.ClosedBill.ID, response.Transaction.ClosedBillId)
+		assert.Equal(t, pendingBill.ID, response.PendingBill.ID)
+		assert.Equal(t, money.FromUnits(30), response.PendingBill.Amount)
+
+		remainder, err := bills.GetOneBill(pendingBill.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, bills.PendingStatus, remainder.Status)
+		assert.Equal(t, money.FromUnits(30), remainder.Amount)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Close pending bill partially with the whole amount closes it completely", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(50)
+		response, err := ClosePendingBill(pendingBill.ID, transactionFields, false, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, pendingBill.ID, response.ClosedBill.ID)
+		assert.Equal(t, uuid.UUID{}, response.PendingBill.ID)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Close pending bill generated by a transaction", func(t *testing.T) {
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(10)
+		transactionFields.Fee = money.Rate{}
+		parentTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		response, err := ClosePendingBill(parentTransaction.PendingBillId, transactionFields, true, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, parentTransaction.PendingBillId, response.ClosedBill.ID)
+		assert.Equal(t, parentTransaction.ID, response.ClosedBill.ParentTransactionId)
+
+		// parent transaction is not linked anymore to a pending bill
+		updatedParent, err := GetTransaction(parentTransaction.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, uuid.UUID{}, updatedParent.PendingBillId)
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(20), updatedAccount.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when closing pending bill with a different currency", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = "VED"
+		if account.Currency == "VED" {
+			billFields.Currency = "USD"
+		}
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = billFields.Amount
+		_, err = ClosePendingBill(pendingBill.ID, transactionFields, true, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.TR010, err.Error())
+
+		samePendingBill, err := bills.GetOneBill(pendingBill.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, bills.PendingStatus, samePendingBill.Status)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when closing pending bill with a transaction of different sign", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(-50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(50)
+		_, err = ClosePendingBill(pendingBill.ID, transactionFields, true, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.BL004, err.Error())
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when closing pending bill with a transaction greater than the bill", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.MustParse("50.01")
+		_, err = ClosePendingBill(pendingBill.ID, transactionFields, true, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.BL005, err.Error())
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.Zero, updatedAccount.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when closing pending bill generating a negative balance", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(-50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(-50)
+		_, err = ClosePendingBill(pendingBill.ID, transactionFields, true, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, fmt.Sprintf(errors_handler.TR002, money.Zero), err.Error())
+
+		samePendingBill, err := bills.GetOneBill(pendingBill.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, bills.PendingStatus, samePendingBill.Status)
+	})
+
+	t.Run("Error when closing unexisting pending bill", func(t *testing.T) {
+		randomUUID, err := uuid.NewRandom()
+		assert.Nil(t, err)
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(10)
+		_, err = ClosePendingBill(randomUUID, transactionFields, true, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Revert closed bill", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(50)
+		transactionFields.Fee = money.Rate{}
+		closing, err := ClosePendingBill(pendingBill.ID, transactionFields, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		response, err := RevertClosedBill(closing.ClosedBill.ID, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, bills.RevertedStatus, response.ClosedBill.Status)
+		assert.Equal(t, response.Transaction.ID, response.ClosedBill.RevertTransactionId)
+		assert.Equal(t, closing.Transaction.ID, response.ClosedBill.TransactionId)
+		assert.Equal(t, money.FromUnits(-50), response.Transaction.Amount)
+		assert.Equal(t, closing.ClosedBill.ID, response.Transaction.RevertBillId)
+		assert.Equal(t, person.ID, response.Transaction.PersonId)
+
+		// debt comes back
+		assert.NotEqual(t, uuid.UUID{}, response.PendingBill.ID)
+		assert.Equal(t, bills.PendingStatus, response.PendingBill.Status)
+		assert.Equal(t, money.FromUnits(50), response.PendingBill.Amount)
+		assert.Equal(t, pendingBill.PersonId, response.PendingBill.PersonId)
+		assert.Equal(t, pendingBill.Currency, response.PendingBill.Currency)
+		assert.Equal(t, pendingBill.Description, response.PendingBill.Description)
+
+		revertedBill, err := bills.GetOneBill(closing.ClosedBill.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, bills.RevertedStatus, revertedBill.Status)
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.Zero, updatedAccount.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Revert closed bill generated by a transaction links parent transaction to the new pending bill", func(t *testing.T) {
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(10)
+		transactionFields.Fee = money.Rate{}
+		parentTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		closing, err := ClosePendingBill(parentTransaction.PendingBillId, transactionFields, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		response, err := RevertClosedBill(closing.ClosedBill.ID, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, parentTransaction.ID, response.PendingBill.ParentTransactionId)
+
+		updatedParent, err := GetTransaction(parentTransaction.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, response.PendingBill.ID, updatedParent.PendingBillId)
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(10), updatedAccount.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when reverting a closed bill twice", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(50)
+		closing, err := ClosePendingBill(pendingBill.ID, transactionFields, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		_, err = RevertClosedBill(closing.ClosedBill.ID, uuid.UUID{})
+		assert.Nil(t, err)
+		_, err = RevertClosedBill(closing.ClosedBill.ID, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.BL006, err.Error())
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when reverting a closed bill generates a negative balance", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(50)
+		transactionFields.Fee = money.Rate{}
+		closing, err := ClosePendingBill(pendingBill.ID, transactionFields, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		// money leaves the account
+		transactionFields.Amount = money.FromUnits(-50)
+		_, err = CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		_, err = RevertClosedBill(closing.ClosedBill.ID, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, fmt.Sprintf(errors_handler.TR002, money.Zero), err.Error())
+
+		sameClosedBill, err := bills.GetOneBill(closing.ClosedBill.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, bills.SolvedStatus, sameClosedBill.Status)
+	})
+
+	t.Run("Error when reverting unexisting closed bill", func(t *testing.T) {
+		randomUUID, err := uuid.NewRandom()
+		assert.Nil(t, err)
+		_, err = RevertClosedBill(randomUUID, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Pay pending bill in installments and get its settlements", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(90)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(30)
+		transactionFields.Fee = money.Rate{}
+		remainders := []money.Money{money.FromUnits(60), money.FromUnits(30)}
+		for _, remainder := range remainders {
+			response, err := ClosePendingBill(pendingBill.ID, transactionFields, false, uuid.UUID{})
+			assert.Nil(t, err)
+			assert.Equal(t, pendingBill.ID, response.ClosedBill.OriginBillId)
+			assert.Equal(t, pendingBill.ID, response.PendingBill.ID)
+			assert.Equal(t, remainder, response.PendingBill.Amount)
+		}
+
+		// last installment closes the bill
+		response, err := ClosePendingBill(pendingBill.ID, transactionFields, false, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, pendingBill.ID, response.ClosedBill.ID)
+		assert.Equal(t, pendingBill.ID, response.ClosedBill.OriginBillId)
+		assert.Equal(t, uuid.UUID{}, response.PendingBill.ID)
+
+		settlements, err := bills.GetBillSettlements(pendingBill.ID, config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, settlements.Count)
+		assert.Len(t, settlements.Bills, 3)
+		for _, settlement := range settlements.Bills {
+			assert.Equal(t, pendingBill.ID, settlement.OriginBillId)
+			assert.Equal(t, money.FromUnits(30), settlement.Amount)
+			assert.Equal(t, bills.SolvedStatus, settlement.Status)
+		}
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(90), updatedAccount.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Update first of three transactions and recompute later balances", func(t *testing.T) {
+		created := []Transaction{}
+		for _, amount := range []money.Money{money.FromUnits(10), money.FromUnits(20), money.FromUnits(30)} {
+			transactionFields := GenerateTransactionFields(account.ID)
+			transactionFields.Amount = amount
+			transactionFields.Fee = money.Rate{}
+			newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+			assert.Nil(t, err)
+			created = append(created, newTransaction)
+		}
+
+		updateFields := TransactionUpdateFields{
+			PersonId:    person.ID,
+			Date:        created[0].Date,
+			Amount:      money.FromUnits(15),
+			Fee:         money.MustParseRate("0.1"),
+			Description: utility.GetRandomString(55),
+		}
+		updatedTransaction, err := UpdateTransaction(created[0].ID, updateFields, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(15), updatedTransaction.Amount)
+		assert.Equal(t, money.MustParseRate("0.1"), updatedTransaction.Fee)
+		assert.Equal(t, money.MustParse("16.5"), updatedTransaction.AmountWithFee)
+		assert.Equal(t, money.MustParse("16.5"), updatedTransaction.Balance)
+		assert.Equal(t, updateFields.Description, updatedTransaction.Description)
+		assert.Equal(t, person.Name, updatedTransaction.PersonName)
+
+		expectedBalances := []money.Money{money.MustParse("16.5"), money.MustParse("36.5"), money.MustParse("66.5")}
+		for i, tr := range created {
+			sameTransaction, err := GetTransaction(tr.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, expectedBalances[i], sameTransaction.Balance)
+		}
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParse("66.5"), updatedAccount.Balance)
+
+		// pending bill in sync
+		pendingBill, err := bills.GetOneBill(created[0].PendingBillId)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(15), pendingBill.Amount)
+		assert.Equal(t, updateFields.Description, pendingBill.Description)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when updating a transaction generates a negative balance later", func(t *testing.T) {
+		created := []Transaction{}
+		for _, amount := range []money.Money{money.FromUnits(10), money.FromUnits(-8), money.FromUnits(20)} {
+			transactionFields := GenerateTransactionFields(account.ID)
+			transactionFields.Amount = amount
+			transactionFields.Fee = money.Rate{}
+			newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+			assert.Nil(t, err)
+			created = append(created, newTransaction)
+		}
+
+		updateFields := TransactionUpdateFields{
+			PersonId:    person.ID,
+			Date:        created[0].Date,
+			Amount:      money.FromUnits(5),
+			Description: created[0].Description,
+		}
+		_, err := UpdateTransaction(created[0].ID, updateFields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, fmt.Sprintf(errors_handler.TR002, money.FromUnits(22)), err.Error())
+
+		// nothing changed
+		expectedBalances := []money.Money{money.FromUnits(10), money.FromUnits(2), money.FromUnits(22)}
+		for i, tr := range created {
+			sameTransaction, err := GetTransaction(tr.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, expectedBalances[i], sameTransaction.Balance)
+			assert.Equal(t, tr.Amount, sameTransaction.Amount)
+		}
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(22), updatedAccount.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when updating a transaction that closed a bill", func(t *testing.T) {
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(50)
+		pendingBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(50)
+		closing, err := ClosePendingBill(pendingBill.ID, transactionFields, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		updateFields := TransactionUpdateFields{
+			PersonId:    person.ID,
+			Date:        closing.Transaction.Date,
+			Amount:      money.FromUnits(40),
+			Description: closing.Transaction.Description,
+		}
+		_, err = UpdateTransaction(closing.Transaction.ID, updateFields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.TR011, err.Error())
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when updating a transaction whose bill was partially paid", func(t *testing.T) {
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(50)
+		transactionFields.Fee = money.Rate{}
+		parentTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		paymentFields := GenerateTransactionFields(account.ID)
+		paymentFields.Amount = money.FromUnits(20)
+		paymentFields.Fee = money.Rate{}
+		_, err = ClosePendingBill(parentTransaction.PendingBillId, paymentFields, false, uuid.UUID{})
+		assert.Nil(t, err)
+
+		updateFields := TransactionUpdateFields{
+			PersonId:    person.ID,
+			Date:        parentTransaction.Date,
+			Amount:      money.FromUnits(60),
+			Description: "edit",
+		}
+		_, err = UpdateTransaction(parentTransaction.ID, updateFields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.TR011, err.Error())
+
+		// the remainder keeps the unpaid amount
+		remainder, err := bills.GetOneBill(parentTransaction.PendingBillId)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(30), remainder.Amount)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when updating unexisting transaction", func(t *testing.T) {
+		randomUUID, err := uuid.NewRandom()
+		assert.Nil(t, err)
+		updateFields := TransactionUpdateFields{
+			PersonId:    person.ID,
+			Date:        time.Now(),
+			Amount:      money.FromUnits(40),
+			Description: utility.GetRandomString(55),
+		}
+		_, err = UpdateTransaction(randomUUID, updateFields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+	})
+
+	t.Run("Error when updating a transaction without date or person", func(t *testing.T) {
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(10)
+		newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		updateFields := TransactionUpdateFields{
+			PersonId:    person.ID,
+			Amount:      money.FromUnits(40),
+			Description: utility.GetRandomString(55),
+		}
+		_, err = UpdateTransaction(newTransaction.ID, updateFields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.TR014, err.Error())
+
+		updateFields.Date = newTransaction.Date
+		updateFields.PersonId = uuid.UUID{}
+		_, err = UpdateTransaction(newTransaction.ID, updateFields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.TR007, err.Error())
+
+		sameTransaction, err := GetTransaction(newTransaction.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, newTransaction.Date, sameTransaction.Date)
+		assert.Equal(t, person.ID, sameTransaction.PersonId)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Delete a transaction in the middle and recompute later balances", func(t *testing.T) {
+		created := []Transaction{}
+		for _, amount := range []money.Money{money.FromUnits(10), money.FromUnits(20), money.FromUnits(30)} {
+			transactionFields := GenerateTransactionFields(account.ID)
+			transactionFields.Amount = amount
+			transactionFields.Fee = money.Rate{}
+			newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+			assert.Nil(t, err)
+			created = append(created, newTransaction)
+		}
+
+		deletedTransaction, err := DeleteTransaction(created[1].ID, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, created[1].ID, deletedTransaction.ID)
+
+		_, err = GetTransaction(created[1].ID)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+
+		// pending bill also deleted
+		_, err = bills.GetOneBill(created[1].PendingBillId)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+
+		lastTransaction, err := GetTransaction(created[2].ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(40), lastTransaction.Balance)
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(40), updatedAccount.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when deleting a transaction generates a negative balance later", func(t *testing.T) {
+		created := []Transaction{}
+		for _, amount := range []money.Money{money.FromUnits(10), money.FromUnits(-8)} {
+			transactionFields := GenerateTransactionFields(account.ID)
+			transactionFields.Amount = amount
+			transactionFields.Fee = money.Rate{}
+			newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+			assert.Nil(t, err)
+			created = append(created, newTransaction)
+		}
+
+		_, err := DeleteTransaction(created[0].ID, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, fmt.Sprintf(errors_handler.TR002, money.FromUnits(2)), err.Error())
+
+		sameTransaction, err := GetTransaction(created[0].ID)
+		assert.Nil(t, err)
+		assert.Equal(t, created[0].ID, sameTransaction.ID)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when deleting a transaction that closed a bill or whose bill was closed", func(t *testing.T) {
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(10)
+		transactionFields.Fee = money.Rate{}
+		parentTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		closing, err := ClosePendingBill(parentTransaction.PendingBillId, transactionFields, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		_, err = DeleteTransaction(closing.Transaction.ID, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.TR011, err.Error())
+
+		_, err = DeleteTransaction(parentTransaction.ID, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.TR012, err.Error())
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Delete last transaction of one account only", func(t *testing.T) {
+		otherAccount, err := money_accounts.CreateMoneyAccount(money_accounts.GenerateAccountFields(), uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(10)
+		transactionFields.Fee = money.Rate{}
+		accountTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields.AccountId = otherAccount.ID
+		otherTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		deletedTransaction, err := DeleteLastAccountTransaction(account.ID, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, accountTransaction.ID, deletedTransaction.ID)
+
+		sameTransaction, err := GetTransaction(otherTransaction.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, otherTransaction.ID, sameTransaction.ID)
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.Zero, updatedAccount.Balance)
+
+		_, err = DeleteLastAccountTransaction(account.ID, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+
+		money_accounts.ResetAccountsBalance(otherAccount.ID)
+		deleteAllTransactions()
+		money_accounts.DeleteOneMoneyAccount(otherAccount.ID, time.Time{}, uuid.UUID{})
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Transfer between accounts with the same currency", func(t *testing.T) {
+		accountFields := money_accounts.GenerateAccountFields()
+		accountFields.Currency = account.Currency
+		otherAccount, err := money_accounts.CreateMoneyAccount(accountFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(100)
+		transactionFields.Fee = money.Rate{}
+		_, err = CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transferFields := TransferFields{
+			FromAccountId: account.ID,
+			ToAccountId:   otherAccount.ID,
+			Date:          time.Now(),
+			Amount:        money.FromUnits(40),
+			Rate:          money.MustParseRate("3"),
+			Description:   utility.GetRandomString(20),
+		}
+		response, err := CreateTransfer(transferFields, uuid.UUID{})
+		assert.Nil(t, err)
+		// rate is ignored for the same currency
+		assert.Equal(t, money.MustParseRate("1"), response.Transfer.Rate)
+		assert.Equal(t, money.FromUnits(40), response.Transfer.CreditedAmount)
+		assert.Equal(t, money.FromUnits(-40), response.FromTransaction.Amount)
+		assert.Equal(t, money.FromUnits(40), response.ToTransaction.Amount)
+		assert.Equal(t, uuid.UUID{}, response.FromTransaction.PendingBillId)
+		assert.Equal(t, uuid.UUID{}, response.ToTransaction.PendingBillId)
+		assert.Equal(t, response.FromTransaction.ID, response.Transfer.FromTransactionId)
+		assert.Equal(t, response.ToTransaction.ID, response.Transfer.ToTransactionId)
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(60), updatedAccount.Balance)
+		updatedOtherAccount, err := money_accounts.GetOneMoneyAccount(otherAccount.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(40), updatedOtherAccount.Balance)
+
+		sameTransfer, err := GetTransfer(response.Transfer.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, response.Transfer.ID, sameTransfer.Transfer.ID)
+		assert.Equal(t, response.FromTransaction.ID, sameTransfer.FromTransaction.ID)
+
+		// sides of a transfer can not be modified separately
+		_, err = DeleteTransaction(response.ToTransaction.ID, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.TR013, err.Error())
+		_, err = UpdateTransaction(response.FromTransaction.ID, TransactionUpdateFields{PersonId: person.ID, Date: time.Now(), Amount: money.FromUnits(-10), Description: "edit"}, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.TR013, err.Error())
+
+		money_accounts.ResetAccountsBalance(otherAccount.ID)
+		deleteAllTransactions()
+		money_accounts.DeleteOneMoneyAccount(otherAccount.ID, time.Time{}, uuid.UUID{})
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Transfer between accounts with different currencies", func(t *testing.T) {
+		vedFields := money_accounts.GenerateAccountFields()
+		vedFields.Currency = "VED"
+		vedAccount, err := money_accounts.CreateMoneyAccount(vedFields, uuid.UUID{})
+		assert.Nil(t, err)
+		usdFields := money_accounts.GenerateAccountFields()
+		usdFields.Currency = "USD"
+		usdAccount, err := money_accounts.CreateMoneyAccount(usdFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(vedAccount.ID)
+		transactionFields.Amount = money.FromUnits(1000)
+		transactionFields.Fee = money.Rate{}
+		_, err = CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transferFields := TransferFields{
+			FromAccountId: vedAccount.ID,
+			ToAccountId:   usdAccount.ID,
+			Date:          time.Now(),
+			Amount:        money.FromUnits(500),
+			Description:   utility.GetRandomString(20),
+		}
+		_, err = CreateTransfer(transferFields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.TF003, err.Error())
+
+		transferFields.Rate = money.MustParseRate("0.025")
+		response, err := CreateTransfer(transferFields, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParse("12.5"), response.Transfer.CreditedAmount)
+		assert.Equal(t, money.FromUnits(500), response.FromTransaction.Balance)
+		assert.Equal(t, money.MustParse("12.5"), response.ToTransaction.Balance)
+
+		// not enough money
+		transferFields.Amount = money.FromUnits(600)
+		_, err = CreateTransfer(transferFields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, fmt.Sprintf(errors_handler.TR002, money.FromUnits(500)), err.Error())
+		updatedUsdAccount, err := money_accounts.GetOneMoneyAccount(usdAccount.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParse("12.5"), updatedUsdAccount.Balance)
+
+		money_accounts.ResetAccountsBalance(vedAccount.ID)
+		money_accounts.ResetAccountsBalance(usdAccount.ID)
+		deleteAllTransactions()
+		money_accounts.DeleteOneMoneyAccount(vedAccount.ID, time.Time{}, uuid.UUID{})
+		money_accounts.DeleteOneMoneyAccount(usdAccount.ID, time.Time{}, uuid.UUID{})
+	})
+
+	t.Run("Error when transfering to the same account", func(t *testing.T) {
+		transferFields := TransferFields{
+			FromAccountId: account.ID,
+			ToAccountId:   account.ID,
+			Date:          time.Now(),
+			Amount:        money.FromUnits(10),
+			Description:   utility.GetRandomString(20),
+		}
+		_, err := CreateTransfer(transferFields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.TF001, err.Error())
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Get closed bills filtered by settling transaction", func(t *testing.T) {
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(10)
+		transactionFields.Fee = money.Rate{}
+		parentTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		closing, err := ClosePendingBill(parentTransaction.PendingBillId, transactionFields, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		billResponse, err := bills.GetClosedBills(bills.ClosedBillsFilter{TransactionId: closing.Transaction.ID}, config.Limit, config.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, billResponse.Count)
+		assert.Equal(t, closing.ClosedBill.ID, billResponse.Bills[0].ID)
+		assert.Equal(t, bills.SolvedStatus, billResponse.Bills[0].Status)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Account statement reconciles with stored balances", func(t *testing.T) {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		created := []Transaction{}
+		for i, amount := range []money.Money{money.FromUnits(100), money.FromUnits(-40), money.FromUnits(20)} {
+			transactionFields := GenerateTransactionFields(account.ID)
+			transactionFields.Date = today
+			if i == 0 {
+				// first transaction dated two days ago, before the period
+				transactionFields.Date = today.AddDate(0, 0, -2)
+			}
+			transactionFields.Amount = amount
+			transactionFields.Fee = money.MustParseRate("0.1")
+			newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+			assert.Nil(t, err)
+			created = append(created, newTransaction)
+		}
+
+		statement, err := money_accounts.GetStatement(account.ID, today.AddDate(0, 0, -1), today)
+		assert.Nil(t, err)
+		assert.Equal(t, account.Currency, statement.Currency)
+		assert.Equal(t, money.FromUnits(110), statement.OpeningBalance)
+		assert.Len(t, statement.Movements, 2)
+		assert.Equal(t, created[1].ID, statement.Movements[0].TransactionId)
+		assert.Equal(t, person.Name, statement.Movements[0].PersonName)
+		assert.Equal(t, money.FromUnits(-4), statement.Movements[0].Fee)
+		assert.Equal(t, money.FromUnits(66), statement.Movements[0].Balance)
+		assert.Equal(t, money.FromUnits(88), statement.Movements[1].Balance)
+		assert.Equal(t, money.FromUnits(20), statement.TotalCredits)
+		assert.Equal(t, money.FromUnits(40), statement.TotalDebits)
+		assert.Equal(t, money.FromUnits(-2), statement.TotalFees)
+		assert.Equal(t, money.FromUnits(88), statement.ClosingBalance)
+
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, updatedAccount.Balance, statement.ClosingBalance)
+
+		// whole history
+		statement, err = money_accounts.GetStatement(account.ID, time.Time{}, today)
+		assert.Nil(t, err)
+		assert.Equal(t, money.Zero, statement.OpeningBalance)
+		assert.Len(t, statement.Movements, 3)
+		assert.Equal(t, money.FromUnits(120), statement.TotalCredits)
+		assert.Equal(t, money.FromUnits(40), statement.TotalDebits)
+		assert.Equal(t, money.FromUnits(8), statement.TotalFees)
+		assert.Equal(t, money.FromUnits(88), statement.ClosingBalance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Account statement places back-dated transactions by their date", func(t *testing.T) {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Date = today
+		transactionFields.Amount = money.FromUnits(100)
+		transactionFields.Fee = money.Rate{}
+		current, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		// registered after the current one but dated three days ago
+		transactionFields.Date = today.AddDate(0, 0, -3)
+		transactionFields.Amount = money.FromUnits(-40)
+		backDated, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(60), backDated.Balance)
+
+		// the back-dated transaction is part of the opening balance, not a movement of the period
+		statement, err := money_accounts.GetStatement(account.ID, today.AddDate(0, 0, -1), today)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(-40), statement.OpeningBalance)
+		assert.Len(t, statement.Movements, 1)
+		assert.Equal(t, current.ID, statement.Movements[0].TransactionId)
+		assert.Equal(t, money.FromUnits(60), statement.Movements[0].Balance)
+		assert.Equal(t, money.FromUnits(60), statement.ClosingBalance)
+
+		// over both dates it comes first, with the running balance of the statement
+		statement, err = money_accounts.GetStatement(account.ID, today.AddDate(0, 0, -3), today)
+		assert.Nil(t, err)
+		assert.Equal(t, money.Zero, statement.OpeningBalance)
+		assert.Len(t, statement.Movements, 2)
+		assert.Equal(t, backDated.ID, statement.Movements[0].TransactionId)
+		assert.Equal(t, money.FromUnits(-40), statement.Movements[0].Balance)
+		assert.Equal(t, current.ID, statement.Movements[1].TransactionId)
+		assert.Equal(t, money.FromUnits(60), statement.Movements[1].Balance)
+		assert.Equal(t, money.FromUnits(60), statement.ClosingBalance)
+
+		// a period before both of them
+		statement, err = money_accounts.GetStatement(account.ID, today.AddDate(0, 0, -10), today.AddDate(0, 0, -5))
+		assert.Nil(t, err)
+		assert.Len(t, statement.Movements, 0)
+		assert.Equal(t, money.Zero, statement.ClosingBalance)
+	})
+
+	t.Run("Error when account statement does not reconcile with stored balances", func(t *testing.T) {
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(10)
+		transactionFields.Fee = money.Rate{}
+		newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		_, err = database.DB.Exec("UPDATE transactions SET balance = balance + 1 WHERE id = $1;", newTransaction.ID)
+		assert.Nil(t, err)
+
+		_, err = money_accounts.GetStatement(account.ID, time.Time{}, time.Now())
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.MA001, err.Error())
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Check and repair ledger", func(t *testing.T) {
+		created := []Transaction{}
+		for _, amount := range []money.Money{money.FromUnits(10), money.FromUnits(20), money.FromUnits(30)} {
+			transactionFields := GenerateTransactionFields(account.ID)
+			transactionFields.Amount = amount
+			transactionFields.Fee = money.Rate{}
+			newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+			assert.Nil(t, err)
+			created = append(created, newTransaction)
+		}
+
+		report, err := CheckLedger(false)
+		assert.Nil(t, err)
+		assert.Len(t, report.Issues, 0)
+		assert.Equal(t, 3, report.Transactions)
+
+		// second transaction loses 5 units, the account keeps its balance
+		_, err = database.DB.Exec("UPDATE transactions SET balance = balance - 5 WHERE id = $1;", created[1].ID)
+		assert.Nil(t, err)
+		_, err = database.DB.Exec("UPDATE money_accounts SET balance = 1 WHERE id = $1;", account.ID)
+		assert.Nil(t, err)
+
+		report, err = CheckLedger(false)
+		assert.Nil(t, err)
+		assert.False(t, report.Repaired)
+		assert.Len(t, report.Issues, 2)
+		assert.Equal(t, TransactionBalanceIssue, report.Issues[0].Kind)
+		assert.Equal(t, created[1].ID, report.Issues[0].TransactionId)
+		assert.Equal(t, money.FromUnits(25), report.Issues[0].Stored)
+		assert.Equal(t, money.FromUnits(30), report.Issues[0].Expected)
+		assert.Equal(t, AccountBalanceIssue, report.Issues[1].Kind)
+		assert.Equal(t, account.ID, report.Issues[1].AccountId)
+		assert.Equal(t, money.FromUnits(1), report.Issues[1].Stored)
+		assert.Equal(t, money.FromUnits(60), report.Issues[1].Expected)
+
+		report, err = CheckLedger(true)
+		assert.Nil(t, err)
+		assert.True(t, report.Repaired)
+		assert.Len(t, report.Issues, 2)
+
+		// the repairs are recorded as written by the system
+		entries, err := audit_log.GetAuditLog(audit_log.AuditLogFilter{EntityType: audit_log.TransactionEntity, EntityId: created[1].ID.String()}, 10, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, audit_log.UpdateAction, entries.Entries[0].Action)
+		assert.Equal(t, audit_log.SystemActor, entries.Entries[0].ActorId)
+		repaired := ledgerBalance{}
+		assert.Nil(t, json.Unmarshal(entries.Entries[0].After, &repaired))
+		assert.Equal(t, money.FromUnits(30), repaired.Balance)
+		entries, err = audit_log.GetAuditLog(audit_log.AuditLogFilter{EntityType: audit_log.MoneyAccountEntity, EntityId: account.ID.String()}, 10, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, audit_log.SystemActor, entries.Entries[0].ActorId)
+
+		report, err = CheckLedger(false)
+		assert.Nil(t, err)
+		assert.Len(t, report.Issues, 0)
+		sameTransaction, err := GetTransaction(created[1].ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(30), sameTransaction.Balance)
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(account.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(60), updatedAccount.Balance)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Chain the balance of a transaction that waited for the account after the one it waited for", func(t *testing.T) {
+		// waiting starts before the other transaction is written
+		waiting, err := database.DB.Begin()
+		assert.Nil(t, err)
+		time.Sleep(10 * time.Millisecond)
+
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(10)
+		transactionFields.Fee = money.Rate{}
+		first, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields.Amount = money.FromUnits(20)
+		second, err := insertTransaction(waiting, transactionFields, person.ID, nil)
+		assert.Nil(t, err)
+		assert.Nil(t, waiting.Commit())
+		assert.Equal(t, money.FromUnits(30), second.Balance)
+		assert.True(t, second.CreatedAt.After(first.CreatedAt))
+
+		report, err := CheckLedger(false)
+		assert.Nil(t, err)
+		assert.Len(t, report.Issues, 0)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Create transactions applying the fee rule of the account", func(t *testing.T) {
+		accountFields := money_accounts.GenerateAccountFields()
+		accountFields.FeeRule = &money_accounts.FeeRule{Kind: money_accounts.FixedFee, Amount: money.MustParse("2.5")}
+		feeAccount, err := money_accounts.CreateMoneyAccount(accountFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(feeAccount.ID)
+		transactionFields.Amount = money.FromUnits(100)
+		transactionFields.Fee = money.MustParseRate("0.5")
+		transactionFields.ApplyFeeRule = true
+		credit, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.Rate{}, credit.Fee)
+		assert.Equal(t, money.MustParse("102.5"), credit.AmountWithFee)
+		assert.Equal(t, accountFields.FeeRule, credit.FeeRule)
+
+		transactionFields.Amount = money.FromUnits(-50)
+		debit, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParse("-52.5"), debit.AmountWithFee)
+		assert.Equal(t, money.FromUnits(50), debit.Balance)
+
+		sameTransaction, err := GetTransaction(debit.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, accountFields.FeeRule, sameTransaction.FeeRule)
+
+		// editing without fee applies the stored rule to the new amount, even after the rule of the account changed
+		changedFields := accountFields
+		changedFields.FeeRule = &money_accounts.FeeRule{Kind: money_accounts.FixedFee, Amount: money.FromUnits(10)}
+		_, err = money_accounts.UpdateMoneyAccount(feeAccount.ID, changedFields, time.Time{}, uuid.UUID{})
+		assert.Nil(t, err)
+		updateFields := TransactionUpdateFields{
+			PersonId:    person.ID,
+			Date:        credit.Date,
+			Amount:      money.FromUnits(200),
+			Description: credit.Description,
+			KeepFee:     true,
+		}
+		updatedCredit, err := UpdateTransaction(credit.ID, updateFields, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.Rate{}, updatedCredit.Fee)
+		assert.Equal(t, money.MustParse("202.5"), updatedCredit.AmountWithFee)
+		assert.Equal(t, accountFields.FeeRule, updatedCredit.FeeRule)
+
+		// editing with an explicit fee drops the rule
+		updateFields.Fee = money.MustParseRate("0.1")
+		updateFields.KeepFee = false
+		updatedCredit, err = UpdateTransaction(credit.ID, updateFields, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(220), updatedCredit.AmountWithFee)
+		assert.Nil(t, updatedCredit.FeeRule)
+
+		// editing only the description keeps the explicit fee and the amount with fee
+		updateFields.Fee = money.Rate{}
+		updateFields.Description = "fixed typo"
+		updateFields.KeepFee = true
+		updatedCredit, err = UpdateTransaction(credit.ID, updateFields, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParseRate("0.1"), updatedCredit.Fee)
+		assert.Equal(t, money.FromUnits(220), updatedCredit.AmountWithFee)
+
+		// back to the original amount with fee so the next transactions keep their balances
+		updateFields.Amount = money.FromUnits(100)
+		updateFields.Fee = money.MustParseRate("0.025")
+		updateFields.KeepFee = false
+		updatedCredit, err = UpdateTransaction(credit.ID, updateFields, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParse("102.5"), updatedCredit.AmountWithFee)
+
+		// an explicit fee ignores the rule
+		transactionFields.Amount = money.FromUnits(10)
+		transactionFields.Fee = money.MustParseRate("0.1")
+		transactionFields.ApplyFeeRule = false
+		explicit, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(11), explicit.AmountWithFee)
+		assert.Nil(t, explicit.FeeRule)
+
+		// accounts without rule do not charge fees
+		transactionFields = GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(10)
+		transactionFields.Fee = money.MustParseRate("0.1")
+		transactionFields.ApplyFeeRule = true
+		noRule, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(10), noRule.AmountWithFee)
+		assert.Nil(t, noRule.FeeRule)
+
+		money_accounts.ResetAccountsBalance(feeAccount.ID)
+		deleteAllTransactions()
+		money_accounts.DeleteOneMoneyAccount(feeAccount.ID, time.Time{}, uuid.UUID{})
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Revert a closed bill cancelling the stored fee after the fee rule changed", func(t *testing.T) {
+		accountFields := money_accounts.GenerateAccountFields()
+		accountFields.FeeRule = &money_accounts.FeeRule{Kind: money_accounts.FixedFee, Amount: money.MustParse("2.5")}
+		feeAccount, err := money_accounts.CreateMoneyAccount(accountFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(feeAccount.ID)
+		transactionFields.Amount = money.FromUnits(100)
+		transactionFields.ApplyFeeRule = true
+		newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		closing, err := ClosePendingBill(newTransaction.PendingBillId, transactionFields, true, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParse("102.5"), closing.Transaction.AmountWithFee)
+
+		accountFields.FeeRule = &money_accounts.FeeRule{Kind: money_accounts.FixedFee, Amount: money.FromUnits(10)}
+		_, err = money_accounts.UpdateMoneyAccount(feeAccount.ID, accountFields, time.Time{}, uuid.UUID{})
+		assert.Nil(t, err)
+
+		response, err := RevertClosedBill(closing.ClosedBill.ID, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.MustParse("-102.5"), response.Transaction.AmountWithFee)
+		assert.Equal(t, closing.Transaction.Fee, response.Transaction.Fee)
+		assert.Equal(t, closing.Transaction.FeeRule, response.Transaction.FeeRule)
+		updatedAccount, err := money_accounts.GetOneMoneyAccount(feeAccount.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, newTransaction.AmountWithFee, updatedAccount.Balance)
+
+		money_accounts.ResetAccountsBalance(feeAccount.ID)
+		deleteAllTransactions()
+		money_accounts.DeleteOneMoneyAccount(feeAccount.ID, time.Time{}, uuid.UUID{})
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Overdraw an account down to its credit limit", func(t *testing.T) {
+		accountFields := money_accounts.GenerateAccountFields()
+		accountFields.CreditLimit = money.FromUnits(100)
+		creditAccount, err := money_accounts.CreateMoneyAccount(accountFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		transactionFields := GenerateTransactionFields(creditAccount.ID)
+		transactionFields.Amount = money.FromUnits(-60)
+		transactionFields.Fee = money.Rate{}
+		first, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(-60), first.Balance)
+
+		transactionFields.Amount = money.FromUnits(-50)
+		_, err = CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, fmt.Sprintf(errors_handler.TR002, money.FromUnits(40)), err.Error())
+
+		transactionFields.Amount = money.FromUnits(-40)
+		second, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(-100), second.Balance)
+
+		updateFields := TransactionUpdateFields{
+			PersonId:    person.ID,
+			Date:        second.Date,
+			Amount:      money.MustParse("-40.01"),
+			Description: second.Description,
+		}
+		_, err = UpdateTransaction(second.ID, updateFields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, fmt.Sprintf(errors_handler.TR002, money.Zero), err.Error())
+
+		// the limit can not be lowered below the current overdraft
+		accountFields.CreditLimit = money.FromUnits(50)
+		_, err = money_accounts.UpdateMoneyAccount(creditAccount.ID, accountFields, time.Time{}, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.MA002, err.Error())
+
+		_, err = DeleteTransaction(second.ID, uuid.UUID{})
+		assert.Nil(t, err)
+		accountFields.CreditLimit = money.FromUnits(60)
+		updatedAccount, err := money_accounts.UpdateMoneyAccount(creditAccount.ID, accountFields, time.Time{}, uuid.UUID{})
+		assert.Nil(t, err)
+		assert.Equal(t, money.FromUnits(60), updatedAccount.CreditLimit)
+		assert.Equal(t, money.FromUnits(-60), updatedAccount.Balance)
+
+		// deleting a credit is checked against the limit as well
+		transactionFields.Amount = money.FromUnits(20)
+		credit, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		transactionFields.Amount = money.FromUnits(-10)
+		_, err = CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		_, err = DeleteTransaction(credit.ID, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, fmt.Sprintf(errors_handler.TR002, money.FromUnits(10)), err.Error())
+
+		money_accounts.ResetAccountsBalance(creditAccount.ID)
+		deleteAllTransactions()
+		money_accounts.DeleteOneMoneyAccount(creditAccount.ID, time.Time{}, uuid.UUID{})
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when writing transactions dated in a closed period", func(t *testing.T) {
+		closedUntil := time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC)
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Amount = money.FromUnits(100)
+		transactionFields.Fee = money.Rate{}
+		transactionFields.Date = closedUntil
+		oldTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+		transactionFields.Date = closedUntil.AddDate(0, 0, 1)
+		newTransaction, err := CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		period, err := closed_periods.CreateClosedPeriod(closed_periods.ClosedPeriodFields{AccountId: account.ID, ClosedUntil: closedUntil})
+		assert.Nil(t, err)
+
+		transactionFields.Date = closedUntil
+		_, err = CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+
+		updateFields := TransactionUpdateFields{
+			PersonId:    person.ID,
+			Date:        closedUntil,
+			Amount:      money.FromUnits(50),
+			Description: newTransaction.Description,
+		}
+		_, err = UpdateTransaction(newTransaction.ID, updateFields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+		updateFields.Date = newTransaction.Date
+		_, err = UpdateTransaction(oldTransaction.ID, updateFields, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+
+		_, err = DeleteTransaction(oldTransaction.ID, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+
+		// other accounts are still open
+		accountFields := money_accounts.GenerateAccountFields()
+		accountFields.Currency = account.Currency
+		otherAccount, err := money_accounts.CreateMoneyAccount(accountFields, uuid.UUID{})
+		assert.Nil(t, err)
+		transactionFields.AccountId = otherAccount.ID
+		_, err = CreateTransaction(transactionFields, person.ID, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		_, err = closed_periods.DeleteClosedPeriod(period.ID)
+		assert.Nil(t, err)
+		_, err = DeleteTransaction(newTransaction.ID, uuid.UUID{})
+		assert.Nil(t, err)
+
+		money_accounts.ResetAccountsBalance(otherAccount.ID)
+		deleteAllTransactions()
+		money_accounts.DeleteOneMoneyAccount(otherAccount.ID, time.Time{}, uuid.UUID{})
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
+	t.Run("Error when closing or reverting bills dated in a closed period", func(t *testing.T) {
+		closedUntil := time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC)
+		billFields := bills.GenerateBillFields(person.ID)
+		billFields.Currency = account.Currency
+		billFields.Amount = money.FromUnits(50)
+		billFields.Date = closedUntil
+		lockedBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+		paidBill, err := bills.CreatePendingBill(billFields, uuid.UUID{})
+		assert.Nil(t, err)
+
+		// paid before the period was closed, by a transaction dated after it
+		transactionFields := GenerateTransactionFields(account.ID)
+		transactionFields.Date = closedUntil.AddDate(0, 0, 1)
+		transactionFields.Amount = money.FromUnits(50)
+		transactionFields.Fee = money.Rate{}
+		closing, err := ClosePendingBill(paidBill.ID, transactionFields, true, uuid.UUID{})
+		assert.Nil(t, err)
+
+		period, err := closed_periods.CreateClosedPeriod(closed_periods.ClosedPeriodFields{ClosedUntil: closedUntil})
+		assert.Nil(t, err)
+
+		// the transaction is dated in an open period but the bill is not
+		_, err = ClosePendingBill(lockedBill.ID, transactionFields, true, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+		transactionFields.Amount = money.FromUnits(20)
+		_, err = ClosePendingBill(lockedBill.ID, transactionFields, false, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+
+		_, err = RevertClosedBill(closing.ClosedBill.ID, uuid.UUID{})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.CP001, err.Error())
+		sameBill, err := bills.GetOneBill(closing.ClosedBill.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, bills.SolvedStatus, sameBill.Status)
+
+		_, err = closed_periods.DeleteClosedPeriod(period.ID)
+		assert.Nil(t, err)
+		_, err = RevertClosedBill(closing.ClosedBill.ID, uuid.UUID{})
+		assert.Nil(t, err)
+	})
+
+	money_accounts.ResetAccountsBalance(account.ID)
+	deleteAllTransactions()
+
 	// at the end of all transactions services tests
 	money_accounts.DeleteAllMoneyAccounts()
 	persons.DeleteAllPersons()
@@ -1,13 +1,48 @@
 package transactions
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/money"
+)
 
 func checkTransactionFields(fields TransactionFields) error {
 	if fields.Description == "" {
 		return fmt.Errorf("Transaction should have a description")
 	}
-	if fields.Amount == float64(0) {
+	if fields.Amount == money.Zero {
+		return fmt.Errorf("Amount should be greater than zero")
+	}
+	return nil
+}
+
+// checkTransactionUpdateFields requires every field but the fee, an edit overwrites all of them
+func checkTransactionUpdateFields(fields TransactionUpdateFields) error {
+	if fields.Description == "" {
+		return fmt.Errorf("Transaction should have a description")
+	}
+	if fields.Amount == money.Zero {
 		return fmt.Errorf("Amount should be greater than zero")
 	}
+	if fields.Date.IsZero() {
+		return fmt.Errorf("Transaction should have a date")
+	}
+	if fields.PersonId == (uuid.UUID{}) {
+		return fmt.Errorf("Transaction should have a person")
+	}
+	return nil
+}
+
+func checkTransferFields(fields TransferFields) error {
+	if fields.Description == "" {
+		return fmt.Errorf("Transfer should have a description")
+	}
+	if fields.Amount <= money.Zero {
+		return fmt.Errorf("Amount should be greater than zero")
+	}
+	if fields.FromAccountId == fields.ToAccountId {
+		return fmt.Errorf("Accounts should be different")
+	}
 	return nil
 }
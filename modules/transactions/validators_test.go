@@ -2,7 +2,10 @@ package transactions
 
 import (
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/money"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,3 +17,37 @@ func TestCheckTransactionFields(t *testing.T) {
 	err = checkTransactionFields(fields)
 	assert.Equal(t, "Amount should be greater than zero", err.Error())
 }
+
+func TestCheckTransactionUpdateFields(t *testing.T) {
+	fields := TransactionUpdateFields{}
+	err := checkTransactionUpdateFields(fields)
+	assert.Equal(t, "Transaction should have a description", err.Error())
+	fields.Description = "asdfasdf asdfas"
+	err = checkTransactionUpdateFields(fields)
+	assert.Equal(t, "Amount should be greater than zero", err.Error())
+	fields.Amount = money.FromUnits(10)
+	err = checkTransactionUpdateFields(fields)
+	assert.Equal(t, "Transaction should have a date", err.Error())
+	fields.Date = time.Now()
+	err = checkTransactionUpdateFields(fields)
+	assert.Equal(t, "Transaction should have a person", err.Error())
+	fields.PersonId = uuid.New()
+	err = checkTransactionUpdateFields(fields)
+	assert.Nil(t, err)
+}
+
+func TestCheckTransferFields(t *testing.T) {
+	fields := TransferFields{}
+	err := checkTransferFields(fields)
+	assert.Equal(t, "Transfer should have a description", err.Error())
+	fields.Description = "asdfasdf asdfas"
+	fields.Amount = money.FromUnits(-10)
+	err = checkTransferFields(fields)
+	assert.Equal(t, "Amount should be greater than zero", err.Error())
+	fields.Amount = money.FromUnits(10)
+	err = checkTransferFields(fields)
+	assert.Equal(t, "Accounts should be different", err.Error())
+	fields.ToAccountId = uuid.New()
+	err = checkTransferFields(fields)
+	assert.Nil(t, err)
+}
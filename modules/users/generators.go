@@ -0,0 +1,11 @@
+package users
+
+import "github.com/grabielcruz/transportation_back/utility"
+
+func GenerateUserFields() UserFields {
+	return UserFields{
+		Username: utility.GetRandomString(15),
+		Password: utility.GetRandomString(20),
+		Role:     ReadOnlyRole,
+	}
+}
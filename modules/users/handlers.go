@@ -0,0 +1,152 @@
+package users
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/common"
+	"github.com/julienschmidt/httprouter"
+)
+
+func GetUsersHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	users, err := GetUsers()
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, users)
+}
+
+func CreateUserHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	fields := UserFields{}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		common.SendReadError(w)
+		return
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		common.SendUnmarshalError(w)
+		return
+	}
+	if err := checkUserFields(fields); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	user, err := CreateUser(fields)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusCreated, user)
+}
+
+func GetUserHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := uuid.Parse(ps.ByName("id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	user, err := GetUser(id)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, user)
+}
+
+func UpdateUserRoleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := uuid.Parse(ps.ByName("id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	fields := RoleFields{}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		common.SendReadError(w)
+		return
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		common.SendUnmarshalError(w)
+		return
+	}
+	if err := checkRole(fields.Role); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	user, err := UpdateUserRole(id, fields.Role)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, user)
+}
+
+func DeleteUserHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := uuid.Parse(ps.ByName("id"))
+	if err != nil {
+		common.SendInvalidUUIDError(w, err.Error())
+		return
+	}
+	deletedId, err := DeleteUser(id)
+	if err != nil {
+		common.SendServiceError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, deletedId)
+}
+
+func LoginHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	fields := UserFields{}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		common.SendReadError(w)
+		return
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		common.SendUnmarshalError(w)
+		return
+	}
+	if err := checkCredentials(fields); err != nil {
+		common.SendValidationError(w, err.Error())
+		return
+	}
+	session, err := Login(fields)
+	if err != nil {
+		common.SendAuthError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, session)
+}
+
+// LogoutHandler closes the session of the token the request was authenticated with
+func LogoutHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	claims, err := GetClaims(r)
+	if err != nil {
+		common.SendAuthError(w, err.Error())
+		return
+	}
+	id, err := Logout(claims)
+	if err != nil {
+		common.SendAuthError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, id)
+}
+
+// RefreshHandler exchanges the token the request was authenticated with for a new one
+func RefreshHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	claims, err := GetClaims(r)
+	if err != nil {
+		common.SendAuthError(w, err.Error())
+		return
+	}
+	session, err := RefreshSession(claims)
+	if err != nil {
+		common.SendAuthError(w, err.Error())
+		return
+	}
+	common.SendJson(w, http.StatusOK, session)
+}
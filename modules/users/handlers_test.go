@@ -0,0 +1,171 @@
+package users
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsersHandlers(t *testing.T) {
+	envPath := filepath.Clean("../../.env_test")
+	sqlPath := filepath.Clean("../../database/database.sql")
+	database.SetupDB(envPath)
+	database.CreateTables(sqlPath)
+	defer database.CloseConnection()
+	SetSecret(testSecret)
+	router := httprouter.New()
+	router.GET("/ping", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusOK)
+	})
+	Routes(router)
+	handler := RequireAuth(router)
+
+	fields := GenerateUserFields()
+	fields.Role = AdminRole
+	_, err := CreateUser(fields)
+	assert.Nil(t, err)
+
+	login := func(t *testing.T, fields UserFields) *httptest.ResponseRecorder {
+		body, err := json.Marshal(fields)
+		assert.Nil(t, err)
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+		assert.Nil(t, err)
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Public routes do not need a token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/ping", nil)
+		assert.Nil(t, err)
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Login and call routes with the token until logout", func(t *testing.T) {
+		w := login(t, fields)
+		assert.Equal(t, http.StatusOK, w.Code)
+		session := Session{}
+		err := json.Unmarshal(w.Body.Bytes(), &session)
+		assert.Nil(t, err)
+		assert.Equal(t, fields.Username, session.User.Username)
+
+		w2 := httptest.NewRecorder()
+		req2, err := http.NewRequest(http.MethodGet, "/users", nil)
+		assert.Nil(t, err)
+		req2.Header.Set("Authorization", "Bearer "+session.Token)
+		handler.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusOK, w2.Code)
+
+		w3 := httptest.NewRecorder()
+		req3, err := http.NewRequest(http.MethodPost, "/refresh", nil)
+		assert.Nil(t, err)
+		req3.Header.Set("Authorization", "Bearer "+session.Token)
+		handler.ServeHTTP(w3, req3)
+		assert.Equal(t, http.StatusOK, w3.Code)
+		refreshed := Session{}
+		err = json.Unmarshal(w3.Body.Bytes(), &refreshed)
+		assert.Nil(t, err)
+
+		w4 := httptest.NewRecorder()
+		req4, err := http.NewRequest(http.MethodPost, "/logout", nil)
+		assert.Nil(t, err)
+		req4.Header.Set("Authorization", "Bearer "+refreshed.Token)
+		handler.ServeHTTP(w4, req4)
+		assert.Equal(t, http.StatusOK, w4.Code)
+
+		w5 := httptest.NewRecorder()
+		req5, err := http.NewRequest(http.MethodGet, "/users", nil)
+		assert.Nil(t, err)
+		req5.Header.Set("Authorization", "Bearer "+refreshed.Token)
+		handler.ServeHTTP(w5, req5)
+		assert.Equal(t, http.StatusUnauthorized, w5.Code)
+		var errResponse errors_handler.ErrorResponse
+		err = json.Unmarshal(w5.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "AU002", errResponse.Code)
+	})
+
+	t.Run("Error when calling a route without token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/users", nil)
+		assert.Nil(t, err)
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		var errResponse errors_handler.ErrorResponse
+		err = json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "AU001", errResponse.Code)
+	})
+
+	t.Run("Error when login with wrong password", func(t *testing.T) {
+		w := login(t, UserFields{Username: fields.Username, Password: "wrong password"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		var errResponse errors_handler.ErrorResponse
+		err := json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "US002", errResponse.Code)
+	})
+
+	t.Run("Error when creating a user with a short password", func(t *testing.T) {
+		w := login(t, fields)
+		session := Session{}
+		err := json.Unmarshal(w.Body.Bytes(), &session)
+		assert.Nil(t, err)
+
+		body, err := json.Marshal(UserFields{Username: "jane", Password: "short"})
+		assert.Nil(t, err)
+		w2 := httptest.NewRecorder()
+		req2, err := http.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
+		assert.Nil(t, err)
+		req2.Header.Set("Authorization", "Bearer "+session.Token)
+		handler.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusBadRequest, w2.Code)
+		var errResponse errors_handler.ErrorResponse
+		err = json.Unmarshal(w2.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "VA001", errResponse.Code)
+	})
+
+	t.Run("Error when the role is not allowed to call a route", func(t *testing.T) {
+		readOnly := GenerateUserFields()
+		_, err := CreateUser(readOnly)
+		assert.Nil(t, err)
+		w := login(t, readOnly)
+		session := Session{}
+		err = json.Unmarshal(w.Body.Bytes(), &session)
+		assert.Nil(t, err)
+
+		w2 := httptest.NewRecorder()
+		req2, err := http.NewRequest(http.MethodGet, "/users", nil)
+		assert.Nil(t, err)
+		req2.Header.Set("Authorization", "Bearer "+session.Token)
+		handler.ServeHTTP(w2, req2)
+		assert.Equal(t, http.StatusForbidden, w2.Code)
+		var errResponse errors_handler.ErrorResponse
+		err = json.Unmarshal(w2.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "AU003", errResponse.Code)
+
+		// the new role applies without login again
+		_, err = UpdateUserRole(session.User.ID, AdminRole)
+		assert.Nil(t, err)
+		w3 := httptest.NewRecorder()
+		req3, err := http.NewRequest(http.MethodGet, "/users", nil)
+		assert.Nil(t, err)
+		req3.Header.Set("Authorization", "Bearer "+session.Token)
+		handler.ServeHTTP(w3, req3)
+		assert.Equal(t, http.StatusOK, w3.Code)
+	})
+
+	DeleteAllUsers()
+}
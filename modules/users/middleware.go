@@ -0,0 +1,59 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grabielcruz/transportation_back/common"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+)
+
+// publicRoutes can be called without a token
+var publicRoutes = map[string]bool{
+	"GET /ping":   true,
+	"POST /login": true,
+}
+
+func IsPublicRoute(method string, path string) bool {
+	return publicRoutes[method+" "+path]
+}
+
+type claimsKey struct{}
+
+// RequireAuth rejects with 401 the requests without a valid bearer token, except the public routes,
+// and with 403 the ones whose route is not allowed for the role of the user. The claims of the token are added to the context of the request
+func RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsPublicRoute(r.Method, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(header, "Bearer ")
+		if token == header || token == "" {
+			common.SendAuthError(w, errors_handler.AU001)
+			return
+		}
+		claims, err := Authenticate(token)
+		if err != nil {
+			common.SendAuthError(w, err.Error())
+			return
+		}
+		if !IsAllowed(claims.Role, r.Method, r.URL.Path) {
+			common.SendForbiddenError(w, errors_handler.AU003)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+	})
+}
+
+// GetClaims returns the claims RequireAuth added to the request
+func GetClaims(r *http.Request) (Claims, error) {
+	claims, ok := r.Context().Value(claimsKey{}).(Claims)
+	if !ok {
+		return claims, fmt.Errorf(errors_handler.AU001)
+	}
+	return claims, nil
+}
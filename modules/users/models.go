@@ -0,0 +1,50 @@
+package users
+
+import (
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/common"
+)
+
+// roles of the users, admin can call every route and the others the routes listed for them in Permissions
+const (
+	AdminRole      = "ADMIN"
+	AccountantRole = "ACCOUNTANT"
+	OperatorRole   = "OPERATOR"
+	ReadOnlyRole   = "READ_ONLY"
+)
+
+// User never carries its password, only the hash is stored
+type User struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	common.Timestamps
+}
+
+// UserFields creates a user, the role is not needed to login
+type UserFields struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type RoleFields struct {
+	Role string `json:"role"`
+}
+
+// Claims are signed inside a bearer token. The token is valid until ExpiresAt, a unix time,
+// while its session is not closed by a logout or a refresh
+type Claims struct {
+	UserId    uuid.UUID `json:"user_id"`
+	SessionId uuid.UUID `json:"session_id"`
+	ExpiresAt int64     `json:"exp"`
+	// not signed, it is read from the user on every request so a new role applies at once
+	Role string `json:"-"`
+}
+
+// Session is sent on login and refresh, the token goes in the Authorization header as "Bearer <token>"
+type Session struct {
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"`
+	User      User   `json:"user"`
+}
@@ -0,0 +1,81 @@
+package users
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// passwords are hashed with PBKDF2-HMAC-SHA256 and stored as pbkdf2_sha256$iterations$salt$hash
+const (
+	hashAlgorithm  = "pbkdf2_sha256"
+	hashIterations = 100000
+	saltLength     = 16
+	keyLength      = 32
+)
+
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2([]byte(password), salt, hashIterations, keyLength)
+	return fmt.Sprintf("%v$%d$%v$%v", hashAlgorithm, hashIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// checkPassword tells if password matches the stored hash, comparing in constant time
+func checkPassword(password string, hash string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != hashAlgorithm {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	computed := pbkdf2([]byte(password), salt, iterations, len(key))
+	return subtle.ConstantTimeCompare(key, computed) == 1
+}
+
+// pbkdf2 derives a key of keyLen bytes as described in RFC 8018
+func pbkdf2(password []byte, salt []byte, iterations int, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+	key := make([]byte, 0, blocks*hashLen)
+	u := make([]byte, hashLen)
+	counter := make([]byte, 4)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter, uint32(block))
+		prf.Write(counter)
+		key = prf.Sum(key)
+		t := key[len(key)-hashLen:]
+		copy(u, t)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range u {
+				t[j] ^= u[j]
+			}
+		}
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,144 @@
+package users
+
+import "strings"
+
+var (
+	readers     = []string{AccountantRole, OperatorRole, ReadOnlyRole}
+	operators   = []string{AccountantRole, OperatorRole}
+	accountants = []string{AccountantRole}
+	adminsOnly  = []string{}
+)
+
+// Permissions maps every route registered by the modules, as "METHOD /pattern", to the roles allowed
+// to call it besides the admin. Routes missing here can only be called by the admin
+var Permissions = map[string][]string{
+	"GET /openapi.json": readers,
+
+	// money accounts
+	"GET /money_accounts":               readers,
+	"GET /money_accounts/:id":           readers,
+	"POST /money_accounts":              accountants,
+	"PATCH /money_accounts/:id":         accountants,
+	"DELETE /money_accounts/:id":        accountants,
+	"GET /money_accounts/:id/statement": readers,
+
+	// persons
+	"GET /persons":        readers,
+	"POST /persons":       operators,
+	"GET /persons/:id":    readers,
+	"PATCH /persons/:id":  operators,
+	"DELETE /persons/:id": accountants,
+
+	// transactions
+	"GET /transactions/:account_id":                readers,
+	"GET /transaction/:transaction_id":             readers,
+	"POST /transaction_to_pending_bill/:person_id": accountants,
+	"POST /close_pending_bill/:bill_id/:completed": accountants,
+	"POST /partial_payment/:bill_id":               accountants,
+	"POST /revert_closed_bill/:bill_id":            accountants,
+	"PATCH /transactions/:transaction_id":          accountants,
+	"DELETE /transactions/:transaction_id":         accountants,
+	"DELETE /last_transaction/:account_id":         accountants,
+	"POST /transfers":                              accountants,
+	"GET /transfers/:transfer_id":                  readers,
+	"GET /admin/ledger_check":                      accountants,
+	"POST /admin/ledger_repair":                    adminsOnly,
+
+	// bills
+	"GET /pending_bills/:person_id":  readers,
+	"POST /pending_bills":            accountants,
+	"GET /bills/:bill_id":            readers,
+	"GET /bill_settlements/:bill_id": readers,
+	"GET /closed_bills":              readers,
+	"GET /aging_report":              readers,
+	"GET /persons/:id/balance":       readers,
+	"PATCH /pending_bills/:bill_id":  accountants,
+	"DELETE /pending_bills/:bill_id": accountants,
+	"POST /bill_cross":               accountants,
+	"GET /bill_cross/:bill_cross_id": readers,
+
+	// currencies
+	"GET /currencies":                          readers,
+	"POST /currencies/:currency":               accountants,
+	"DELETE /currencies/:currency":             accountants,
+	"GET /exchange_rates":                      readers,
+	"GET /exchange_rates/:exchange_rate_id":    readers,
+	"POST /exchange_rates":                     accountants,
+	"PATCH /exchange_rates/:exchange_rate_id":  accountants,
+	"DELETE /exchange_rates/:exchange_rate_id": accountants,
+	"GET /effective_exchange_rate/:from/:to":   readers,
+
+	// recurring bills
+	"GET /recurring_bills":             readers,
+	"POST /recurring_bills":            accountants,
+	"GET /recurring_bills/:id":         readers,
+	"DELETE /recurring_bills/:id":      accountants,
+	"POST /recurring_bills/:id/pause":  accountants,
+	"POST /recurring_bills/:id/resume": accountants,
+	"GET /recurring_bills/:id/preview": readers,
+	"POST /run_recurring_bills":        accountants,
+
+	// dashboard
+	"GET /dashboard": readers,
+
+	// closed periods, only the admin reopens a period
+	"GET /admin/closed_periods":        accountants,
+	"POST /admin/closed_periods":       accountants,
+	"DELETE /admin/closed_periods/:id": adminsOnly,
+
+	// audit log
+	"GET /audit_log": accountants,
+
+	// users, every role handles its own session
+	"POST /logout":          readers,
+	"POST /refresh":         readers,
+	"GET /users":            adminsOnly,
+	"POST /users":           adminsOnly,
+	"GET /users/:id":        adminsOnly,
+	"PATCH /users/:id/role": adminsOnly,
+	"DELETE /users/:id":     adminsOnly,
+}
+
+// IsAllowed tells whether the role can call the route matching method and path
+func IsAllowed(role string, method string, path string) bool {
+	if role == AdminRole {
+		return true
+	}
+	for route, roles := range Permissions {
+		if !matchRoute(route, method, path) {
+			continue
+		}
+		for _, r := range roles {
+			if r == role {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// matchRoute compares the request with a "METHOD /pattern" route, where :name segments match any segment
+func matchRoute(route string, method string, path string) bool {
+	routeMethod, pattern, _ := strings.Cut(route, " ")
+	if routeMethod != method {
+		return false
+	}
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternSegments) != len(pathSegments) {
+		return false
+	}
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, ":") {
+			if pathSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,14 @@
+package users
+
+import "github.com/julienschmidt/httprouter"
+
+func Routes(router *httprouter.Router) {
+	router.POST("/login", LoginHandler)
+	router.POST("/logout", LogoutHandler)
+	router.POST("/refresh", RefreshHandler)
+	router.GET("/users", GetUsersHandler)
+	router.POST("/users", CreateUserHandler)
+	router.GET("/users/:id", GetUserHandler)
+	router.PATCH("/users/:id/role", UpdateUserRoleHandler)
+	router.DELETE("/users/:id", DeleteUserHandler)
+}
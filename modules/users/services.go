@@ -0,0 +1,172 @@
+package users
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/common"
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/config"
+)
+
+func GetUsers() ([]User, error) {
+	users := []User{}
+	rows, err := database.DB.Query("SELECT id, username, role, created_at, updated_at FROM users ORDER BY username;")
+	if err != nil {
+		return users, fmt.Errorf(errors_handler.DB005)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		u := User{}
+		err = rows.Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+		if err != nil {
+			return users, fmt.Errorf(errors_handler.DB005)
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func CreateUser(fields UserFields) (User, error) {
+	u := User{}
+	hash, err := hashPassword(fields.Password)
+	if err != nil {
+		return u, fmt.Errorf(errors_handler.DB007)
+	}
+	row := database.DB.QueryRow("INSERT INTO users (username, password_hash, role) VALUES ($1, $2, $3) RETURNING id, username, role, created_at, updated_at;", fields.Username, hash, fields.Role)
+	err = row.Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return u, errors_handler.MapDBErrors(err)
+	}
+	return u, nil
+}
+
+// CreateFirstUser creates the user as admin only when there are no users yet, so the API can be reached
+// after the tables are created. It returns whether the user was created
+func CreateFirstUser(fields UserFields) (bool, error) {
+	fields.Role = AdminRole
+	if err := checkUserFields(fields); err != nil {
+		return false, err
+	}
+	count := 0
+	row := database.DB.QueryRow("SELECT COUNT(*) FROM users;")
+	err := row.Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf(errors_handler.DB004)
+	}
+	if count > 0 {
+		return false, nil
+	}
+	_, err = CreateUser(fields)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func GetUser(user_id uuid.UUID) (User, error) {
+	u := User{}
+	row := database.DB.QueryRow("SELECT id, username, role, created_at, updated_at FROM users WHERE id = $1;", user_id)
+	err := row.Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return u, errors_handler.MapDBErrors(err)
+	}
+	return u, nil
+}
+
+func UpdateUserRole(user_id uuid.UUID, role string) (User, error) {
+	u := User{}
+	row := database.DB.QueryRow("UPDATE users SET role = $1, updated_at = $2 WHERE id = $3 RETURNING id, username, role, created_at, updated_at;", role, time.Now(), user_id)
+	err := row.Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return u, errors_handler.MapDBErrors(err)
+	}
+	return u, nil
+}
+
+// DeleteUser removes the user and its sessions
+func DeleteUser(user_id uuid.UUID) (common.ID, error) {
+	id := common.ID{}
+	row := database.DB.QueryRow("DELETE FROM users WHERE id = $1 RETURNING id;", user_id)
+	err := row.Scan(&id.ID)
+	if err != nil {
+		return id, errors_handler.MapDBErrors(err)
+	}
+	return id, nil
+}
+
+// Login opens a session when the password matches, unknown users and wrong passwords get the same error
+func Login(fields UserFields) (Session, error) {
+	u := User{}
+	var hash string
+	row := database.DB.QueryRow("SELECT id, username, role, created_at, updated_at, password_hash FROM users WHERE username = $1;", fields.Username)
+	err := row.Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt, &u.UpdatedAt, &hash)
+	if err != nil || !checkPassword(fields.Password, hash) {
+		return Session{}, fmt.Errorf(errors_handler.US002)
+	}
+	return openSession(u)
+}
+
+// Logout closes the session of the token, it can not be used again even if it has not expired
+func Logout(claims Claims) (common.ID, error) {
+	id := common.ID{}
+	row := database.DB.QueryRow("UPDATE sessions SET closed_at = $1 WHERE id = $2 AND closed_at IS NULL RETURNING id;", time.Now(), claims.SessionId)
+	err := row.Scan(&id.ID)
+	if err != nil {
+		return id, fmt.Errorf(errors_handler.AU002)
+	}
+	return id, nil
+}
+
+// RefreshSession closes the session of the token and opens a new one with a new expiry
+func RefreshSession(claims Claims) (Session, error) {
+	_, err := Logout(claims)
+	if err != nil {
+		return Session{}, err
+	}
+	u, err := GetUser(claims.UserId)
+	if err != nil {
+		return Session{}, fmt.Errorf(errors_handler.AU002)
+	}
+	return openSession(u)
+}
+
+// Authenticate returns the claims of a token correctly signed, not expired and whose session is still open,
+// along with the current role of the user
+func Authenticate(token string) (Claims, error) {
+	now := time.Now()
+	claims, err := parseToken(token, now)
+	if err != nil {
+		return claims, err
+	}
+	var userId uuid.UUID
+	row := database.DB.QueryRow("SELECT s.user_id, u.role FROM sessions s JOIN users u ON u.id = s.user_id WHERE s.id = $1 AND s.closed_at IS NULL AND s.expires_at > $2;", claims.SessionId, now)
+	err = row.Scan(&userId, &claims.Role)
+	if err != nil || userId != claims.UserId {
+		return claims, fmt.Errorf(errors_handler.AU002)
+	}
+	return claims, nil
+}
+
+func openSession(u User) (Session, error) {
+	s := Session{User: u}
+	expiresAt := time.Now().Add(config.TokenDuration).Truncate(time.Second)
+	claims := Claims{UserId: u.ID, ExpiresAt: expiresAt.Unix()}
+	row := database.DB.QueryRow("INSERT INTO sessions (user_id, expires_at) VALUES ($1, $2) RETURNING id;", u.ID, expiresAt)
+	err := row.Scan(&claims.SessionId)
+	if err != nil {
+		return s, fmt.Errorf(errors_handler.DB007)
+	}
+	s.Token, err = signToken(claims)
+	if err != nil {
+		return s, fmt.Errorf(errors_handler.DB007)
+	}
+	s.ExpiresAt = claims.ExpiresAt
+	return s, nil
+}
+
+func DeleteAllUsers() {
+	database.DB.Exec("DELETE FROM users;")
+}
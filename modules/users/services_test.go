@@ -0,0 +1,211 @@
+package users
+
+import (
+	"encoding/hex"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grabielcruz/transportation_back/database"
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+	"github.com/grabielcruz/transportation_back/modules/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "secret used to sign the tokens of the tests"
+
+func TestPbkdf2(t *testing.T) {
+	// test vectors of RFC 7914
+	key := pbkdf2([]byte("passwd"), []byte("salt"), 1, 64)
+	assert.Equal(t, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783", hex.EncodeToString(key))
+	key = pbkdf2([]byte("Password"), []byte("NaCl"), 80000, 64)
+	assert.Equal(t, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d", hex.EncodeToString(key))
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := hashPassword("my password")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(hash, "pbkdf2_sha256$100000$"))
+	assert.True(t, checkPassword("my password", hash))
+	assert.False(t, checkPassword("my Password", hash))
+	assert.False(t, checkPassword("my password", "plain text"))
+
+	// every hash has its own salt
+	other, err := hashPassword("my password")
+	assert.Nil(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
+func TestToken(t *testing.T) {
+	SetSecret(testSecret)
+	now := time.Now()
+	claims := Claims{UserId: uuid.New(), SessionId: uuid.New(), ExpiresAt: now.Add(time.Hour).Unix()}
+	token, err := signToken(claims)
+	assert.Nil(t, err)
+
+	parsed, err := parseToken(token, now)
+	assert.Nil(t, err)
+	assert.Equal(t, claims, parsed)
+
+	_, err = parseToken(token, now.Add(time.Hour))
+	assert.NotNil(t, err)
+	assert.Equal(t, errors_handler.AU002, err.Error())
+
+	// claims changed without signing them again
+	other, err := signToken(Claims{UserId: uuid.New(), SessionId: claims.SessionId, ExpiresAt: claims.ExpiresAt})
+	assert.Nil(t, err)
+	forged := strings.Split(other, ".")[0] + "." + strings.Split(token, ".")[1]
+	_, err = parseToken(forged, now)
+	assert.NotNil(t, err)
+	assert.Equal(t, errors_handler.AU001, err.Error())
+
+	SetSecret("another secret")
+	_, err = parseToken(token, now)
+	assert.NotNil(t, err)
+	assert.Equal(t, errors_handler.AU001, err.Error())
+
+	_, err = parseToken("not a token", now)
+	assert.NotNil(t, err)
+	assert.Equal(t, errors_handler.AU001, err.Error())
+}
+
+func TestIsAllowed(t *testing.T) {
+	assert.True(t, IsAllowed(AdminRole, http.MethodDelete, "/currencies/USD"))
+	assert.True(t, IsAllowed(AccountantRole, http.MethodDelete, "/currencies/USD"))
+	assert.False(t, IsAllowed(OperatorRole, http.MethodDelete, "/currencies/USD"))
+	assert.True(t, IsAllowed(OperatorRole, http.MethodPost, "/persons"))
+	assert.True(t, IsAllowed(OperatorRole, http.MethodGet, "/bills/5a7d3b94-0a3c-4b5f-9a39-7a1b0a0d2f61"))
+	assert.False(t, IsAllowed(OperatorRole, http.MethodPost, "/transactions/5a7d3b94-0a3c-4b5f-9a39-7a1b0a0d2f61"))
+	assert.False(t, IsAllowed(OperatorRole, http.MethodDelete, "/last_transaction/5a7d3b94-0a3c-4b5f-9a39-7a1b0a0d2f61"))
+	assert.True(t, IsAllowed(ReadOnlyRole, http.MethodGet, "/persons/5a7d3b94-0a3c-4b5f-9a39-7a1b0a0d2f61/balance"))
+	assert.False(t, IsAllowed(ReadOnlyRole, http.MethodPost, "/persons"))
+	assert.False(t, IsAllowed(AccountantRole, http.MethodGet, "/users"))
+	// routes out of the table are left to the admin
+	assert.False(t, IsAllowed(AccountantRole, http.MethodGet, "/unknown"))
+	assert.True(t, IsAllowed(AdminRole, http.MethodGet, "/unknown"))
+}
+
+func TestUsersServices(t *testing.T) {
+	envPath := filepath.Clean("../../.env_test")
+	sqlPath := filepath.Clean("../../database/database.sql")
+	database.SetupDB(envPath)
+	database.CreateTables(sqlPath)
+	defer database.CloseConnection()
+	SetSecret(testSecret)
+
+	t.Run("Create the first user only once", func(t *testing.T) {
+		fields := GenerateUserFields()
+		created, err := CreateFirstUser(fields)
+		assert.Nil(t, err)
+		assert.True(t, created)
+		created, err = CreateFirstUser(GenerateUserFields())
+		assert.Nil(t, err)
+		assert.False(t, created)
+
+		users, err := GetUsers()
+		assert.Nil(t, err)
+		assert.Len(t, users, 1)
+		assert.Equal(t, fields.Username, users[0].Username)
+		assert.Equal(t, AdminRole, users[0].Role)
+	})
+
+	DeleteAllUsers()
+
+	t.Run("Create, get and delete a user", func(t *testing.T) {
+		fields := GenerateUserFields()
+		user, err := CreateUser(fields)
+		assert.Nil(t, err)
+		assert.Equal(t, fields.Username, user.Username)
+
+		sameUser, err := GetUser(user.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, user, sameUser)
+
+		_, err = CreateUser(fields)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.US001, err.Error())
+
+		updated, err := UpdateUserRole(user.ID, AccountantRole)
+		assert.Nil(t, err)
+		assert.Equal(t, AccountantRole, updated.Role)
+
+		id, err := DeleteUser(user.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, user.ID, id.ID)
+		_, err = GetUser(user.ID)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.DB001, err.Error())
+	})
+
+	DeleteAllUsers()
+
+	t.Run("Login, refresh and logout", func(t *testing.T) {
+		fields := GenerateUserFields()
+		user, err := CreateUser(fields)
+		assert.Nil(t, err)
+
+		session, err := Login(fields)
+		assert.Nil(t, err)
+		assert.Equal(t, user.ID, session.User.ID)
+		assert.InDelta(t, time.Now().Add(config.TokenDuration).Unix(), session.ExpiresAt, 2)
+
+		claims, err := Authenticate(session.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, user.ID, claims.UserId)
+		assert.Equal(t, ReadOnlyRole, claims.Role)
+
+		refreshed, err := RefreshSession(claims)
+		assert.Nil(t, err)
+		assert.NotEqual(t, session.Token, refreshed.Token)
+		// the old token is closed by the refresh
+		_, err = Authenticate(session.Token)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.AU002, err.Error())
+
+		claims, err = Authenticate(refreshed.Token)
+		assert.Nil(t, err)
+		_, err = Logout(claims)
+		assert.Nil(t, err)
+		_, err = Authenticate(refreshed.Token)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.AU002, err.Error())
+		_, err = Logout(claims)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.AU002, err.Error())
+	})
+
+	DeleteAllUsers()
+
+	t.Run("Error when login with wrong credentials", func(t *testing.T) {
+		fields := GenerateUserFields()
+		_, err := CreateUser(fields)
+		assert.Nil(t, err)
+
+		_, err = Login(UserFields{Username: fields.Username, Password: fields.Password + "x"})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.US002, err.Error())
+		_, err = Login(UserFields{Username: fields.Username + "x", Password: fields.Password})
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.US002, err.Error())
+	})
+
+	DeleteAllUsers()
+
+	t.Run("Sessions of a deleted user are closed", func(t *testing.T) {
+		fields := GenerateUserFields()
+		user, err := CreateUser(fields)
+		assert.Nil(t, err)
+		session, err := Login(fields)
+		assert.Nil(t, err)
+		_, err = DeleteUser(user.ID)
+		assert.Nil(t, err)
+		_, err = Authenticate(session.Token)
+		assert.NotNil(t, err)
+		assert.Equal(t, errors_handler.AU002, err.Error())
+	})
+
+	DeleteAllUsers()
+}
@@ -0,0 +1,60 @@
+package users
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
+)
+
+// secret signs the tokens, it is set once on startup
+var secret []byte
+
+func SetSecret(s string) {
+	secret = []byte(s)
+}
+
+// signToken writes the claims as base64url(json).base64url(hmac-sha256)
+func signToken(claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(sign(encoded)), nil
+}
+
+// parseToken checks the signature and the expiry of the token, the session is checked by Authenticate
+func parseToken(token string, now time.Time) (Claims, error) {
+	claims := Claims{}
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return claims, fmt.Errorf(errors_handler.AU001)
+	}
+	decodedSignature, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(decodedSignature, sign(encoded)) {
+		return claims, fmt.Errorf(errors_handler.AU001)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return claims, fmt.Errorf(errors_handler.AU001)
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, fmt.Errorf(errors_handler.AU001)
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return claims, fmt.Errorf(errors_handler.AU002)
+	}
+	return claims, nil
+}
+
+func sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,33 @@
+package users
+
+import "fmt"
+
+const minPasswordLength = 8
+
+func checkUserFields(fields UserFields) error {
+	if fields.Username == "" {
+		return fmt.Errorf("Username is required")
+	}
+	if len(fields.Password) < minPasswordLength {
+		return fmt.Errorf("Password should have at least %d characters", minPasswordLength)
+	}
+	return checkRole(fields.Role)
+}
+
+func checkRole(role string) error {
+	switch role {
+	case AdminRole, AccountantRole, OperatorRole, ReadOnlyRole:
+		return nil
+	}
+	return fmt.Errorf("Role should be ADMIN, ACCOUNTANT, OPERATOR or READ_ONLY")
+}
+
+func checkCredentials(fields UserFields) error {
+	if fields.Username == "" {
+		return fmt.Errorf("Username is required")
+	}
+	if fields.Password == "" {
+		return fmt.Errorf("Password is required")
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckUserFields(t *testing.T) {
+	fields := UserFields{}
+	err := checkUserFields(fields)
+	assert.Equal(t, "Username is required", err.Error())
+	fields.Username = "john"
+	fields.Password = "1234567"
+	err = checkUserFields(fields)
+	assert.Equal(t, "Password should have at least 8 characters", err.Error())
+	fields.Password = "12345678"
+	err = checkUserFields(fields)
+	assert.Equal(t, "Role should be ADMIN, ACCOUNTANT, OPERATOR or READ_ONLY", err.Error())
+	fields.Role = OperatorRole
+	err = checkUserFields(fields)
+	assert.Nil(t, err)
+}
+
+func TestCheckCredentials(t *testing.T) {
+	fields := UserFields{}
+	err := checkCredentials(fields)
+	assert.Equal(t, "Username is required", err.Error())
+	fields.Username = "john"
+	err = checkCredentials(fields)
+	assert.Equal(t, "Password is required", err.Error())
+	fields.Password = "short"
+	err = checkCredentials(fields)
+	assert.Nil(t, err)
+}
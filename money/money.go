@@ -0,0 +1,219 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount of money counted in cents, it maps to NUMERIC(17,2) columns.
+// Amounts are added and subtracted with the usual operators, multiplications go through MulRate
+// so the rounding is always explicit
+type Money int64
+
+type RoundingMode int
+
+const (
+	// HalfUp rounds to the nearest cent, ties away from zero. It is how NUMERIC rounds
+	HalfUp RoundingMode = iota
+	// HalfEven rounds to the nearest cent, ties to the even cent
+	HalfEven
+	// Down truncates towards zero
+	Down
+	// Up rounds away from zero
+	Up
+)
+
+const Zero Money = 0
+
+func FromCents(cents int64) Money {
+	return Money(cents)
+}
+
+func FromUnits(units int64) Money {
+	return Money(units * 100)
+}
+
+// decimalPattern is a plain decimal number, big.Rat alone would also take Go literals like "0x10" or "1_000"
+var decimalPattern = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+
+// Parse reads a decimal amount like "-12.5" or "12.50", it fails when the amount has fractions of a cent
+func Parse(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if !decimalPattern.MatchString(s) {
+		return Zero, fmt.Errorf("Invalid amount of money: %v", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Zero, fmt.Errorf("Invalid amount of money: %v", s)
+	}
+	r.Mul(r, big.NewRat(100, 1))
+	if !r.IsInt() {
+		return Zero, fmt.Errorf("Amount of money should not have more than two decimal places: %v", s)
+	}
+	if !r.Num().IsInt64() {
+		return Zero, fmt.Errorf("Amount of money out of range: %v", s)
+	}
+	return Money(r.Num().Int64()), nil
+}
+
+// MustParse is like Parse but panics on error, it is meant for constants and tests
+func MustParse(s string) Money {
+	m, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+func (m Money) String() string {
+	cents := int64(m)
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%v%d.%02d", sign, cents/100, cents%100)
+}
+
+func (m Money) IsZero() bool {
+	return m == 0
+}
+
+// Sign returns -1, 0 or 1
+func (m Money) Sign() int {
+	switch {
+	case m < 0:
+		return -1
+	case m > 0:
+		return 1
+	}
+	return 0
+}
+
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// Float64 is only meant for reporting, it must not be used to compute amounts
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// MulRate multiplies the amount by rate, rounding the result to cents with mode
+func (m Money) MulRate(rate Rate, mode RoundingMode) Money {
+	r := rate.rat()
+	r.Mul(r, new(big.Rat).SetInt64(int64(m)))
+	return Money(round(r, mode))
+}
+
+// DivRate divides the amount by rate, rounding the result to cents with mode. It is how an amount
+// is converted with the inverse of a rate. Dividing by a zero rate gives zero
+func (m Money) DivRate(rate Rate, mode RoundingMode) Money {
+	if rate.IsZero() {
+		return Zero
+	}
+	r := new(big.Rat).SetInt64(int64(m))
+	r.Quo(r, rate.rat())
+	return Money(round(r, mode))
+}
+
+// WithFee returns the amount plus the fee, where fee is a fraction of the amount between 0 and 1
+func (m Money) WithFee(fee Rate, mode RoundingMode) Money {
+	return m + m.MulRate(fee, mode)
+}
+
+// round takes a rational number of cents to an integer one
+func round(r *big.Rat, mode RoundingMode) int64 {
+	num := r.Num()
+	den := r.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return q.Int64()
+	}
+
+	step := big.NewInt(int64(num.Sign()))
+	twiceRem := new(big.Int).Abs(rem)
+	twiceRem.Mul(twiceRem, big.NewInt(2))
+	half := twiceRem.Cmp(den)
+
+	awayFromZero := false
+	switch mode {
+	case HalfUp:
+		awayFromZero = half >= 0
+	case HalfEven:
+		awayFromZero = half > 0 || (half == 0 && q.Bit(0) == 1)
+	case Up:
+		awayFromZero = true
+	case Down:
+		awayFromZero = false
+	}
+	if awayFromZero {
+		q.Add(q, step)
+	}
+	return q.Int64()
+}
+
+// Scan implements sql.Scanner, NUMERIC values come as text from the driver
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*m = Zero
+		return nil
+	case []byte:
+		parsed, err := Parse(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case string:
+		parsed, err := Parse(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case int64:
+		*m = FromUnits(v)
+		return nil
+	}
+	return fmt.Errorf("Can not scan %T into money", src)
+}
+
+// Value implements driver.Valuer, the amount is sent as text so NUMERIC receives it exactly
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// MarshalJSON writes the amount as a JSON number with two decimal places
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a string holding a number
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
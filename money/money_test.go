@@ -0,0 +1,100 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	toParse := []string{"0", "12", "12.5", "12.50", "-0.01", "-1234.56", " 3.10 "}
+	parsed := []Money{0, 1200, 1250, 1250, -1, -123456, 310}
+	for i := range toParse {
+		m, err := Parse(toParse[i])
+		assert.Nil(t, err)
+		assert.Equal(t, parsed[i], m)
+	}
+
+	for _, bad := range []string{"", "abc", "1.005", "1/2", "true", "0x10", "1_000", "1e2", ".5"} {
+		_, err := Parse(bad)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "0.00", Zero.String())
+	assert.Equal(t, "12.05", FromCents(1205).String())
+	assert.Equal(t, "-0.05", FromCents(-5).String())
+	assert.Equal(t, "-40.00", FromUnits(-40).String())
+}
+
+func TestMulRate(t *testing.T) {
+	// 0.125 is a tie in every mode
+	amount := FromCents(125)
+	assert.Equal(t, FromCents(13), amount.MulRate(MustParseRate("0.1"), HalfUp))
+	assert.Equal(t, FromCents(12), amount.MulRate(MustParseRate("0.1"), HalfEven))
+	assert.Equal(t, FromCents(12), amount.MulRate(MustParseRate("0.1"), Down))
+	assert.Equal(t, FromCents(13), amount.MulRate(MustParseRate("0.1"), Up))
+
+	negative := FromCents(-125)
+	assert.Equal(t, FromCents(-13), negative.MulRate(MustParseRate("0.1"), HalfUp))
+	assert.Equal(t, FromCents(-12), negative.MulRate(MustParseRate("0.1"), HalfEven))
+	assert.Equal(t, FromCents(-12), negative.MulRate(MustParseRate("0.1"), Down))
+	assert.Equal(t, FromCents(-13), negative.MulRate(MustParseRate("0.1"), Up))
+
+	// 0.135 ties to the even cent upwards
+	assert.Equal(t, FromCents(14), FromCents(135).MulRate(MustParseRate("0.1"), HalfEven))
+
+	// exact results are not rounded
+	assert.Equal(t, MustParse("12.5"), FromUnits(500).MulRate(MustParseRate("0.025"), Down))
+	assert.Equal(t, MustParse("1.05"), FromUnits(1).WithFee(MustParseRate("0.05"), HalfUp))
+	assert.Equal(t, MustParse("-0.33"), FromCents(-33).WithFee(MustParseRate("0"), HalfUp))
+}
+
+func TestDivRate(t *testing.T) {
+	// 100 / 3 = 33.333...
+	assert.Equal(t, FromCents(3333), FromUnits(100).DivRate(MustParseRate("3"), HalfUp))
+	assert.Equal(t, FromCents(3334), FromUnits(100).DivRate(MustParseRate("3"), Up))
+	assert.Equal(t, FromCents(-3333), FromUnits(-100).DivRate(MustParseRate("3"), HalfUp))
+
+	// dividing by the rate undoes multiplying by it when the result is exact
+	assert.Equal(t, FromUnits(40), FromUnits(1).DivRate(MustParseRate("0.025"), HalfUp))
+	assert.Equal(t, Zero, FromUnits(1).DivRate(Rate{}, HalfUp))
+}
+
+func TestScanAndValue(t *testing.T) {
+	m := Money(0)
+	assert.Nil(t, m.Scan([]byte("123.45")))
+	assert.Equal(t, FromCents(12345), m)
+	assert.Nil(t, m.Scan("-0.10"))
+	assert.Equal(t, FromCents(-10), m)
+	assert.Nil(t, m.Scan(int64(7)))
+	assert.Equal(t, FromUnits(7), m)
+	assert.Nil(t, m.Scan(nil))
+	assert.Equal(t, Zero, m)
+	assert.NotNil(t, m.Scan(1.5))
+
+	value, err := FromCents(-12345).Value()
+	assert.Nil(t, err)
+	assert.Equal(t, "-123.45", value)
+}
+
+func TestJSON(t *testing.T) {
+	type wrapper struct {
+		Amount Money `json:"amount"`
+	}
+	data, err := json.Marshal(wrapper{Amount: FromCents(1000000000000001)})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"amount":10000000000000.01}`, string(data))
+
+	w := wrapper{}
+	assert.Nil(t, json.Unmarshal(data, &w))
+	assert.Equal(t, FromCents(1000000000000001), w.Amount)
+
+	assert.Nil(t, json.Unmarshal([]byte(`{"amount":"-2.5"}`), &w))
+	assert.Equal(t, FromCents(-250), w.Amount)
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"amount":true}`), &w))
+	assert.NotNil(t, json.Unmarshal([]byte(`{"amount":0.001}`), &w))
+}
@@ -0,0 +1,166 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const rateScale = 1000000
+
+// Rate is an exact decimal factor with up to six decimal places, like an exchange rate or a fee.
+// It maps to NUMERIC(17,6) columns. It is kept in a struct so a plain number is never taken for millionths
+type Rate struct {
+	millionths int64
+}
+
+var OneRate = Rate{rateScale}
+
+func RateFromMillionths(millionths int64) Rate {
+	return Rate{millionths}
+}
+
+// ParseRate reads a decimal rate like "0.05" or "18.5", it fails when the rate has more than six decimal places
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if !decimalPattern.MatchString(s) {
+		return Rate{}, fmt.Errorf("Invalid rate: %v", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Rate{}, fmt.Errorf("Invalid rate: %v", s)
+	}
+	r.Mul(r, big.NewRat(rateScale, 1))
+	if !r.IsInt() {
+		return Rate{}, fmt.Errorf("Rate should not have more than six decimal places: %v", s)
+	}
+	if !r.Num().IsInt64() {
+		return Rate{}, fmt.Errorf("Rate out of range: %v", s)
+	}
+	return Rate{r.Num().Int64()}, nil
+}
+
+// MustParseRate is like ParseRate but panics on error, it is meant for constants and tests
+func MustParseRate(s string) Rate {
+	r, err := ParseRate(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (r Rate) Millionths() int64 {
+	return r.millionths
+}
+
+// String writes the rate without trailing zeros, like "0.05" or "18.5"
+func (r Rate) String() string {
+	n := r.millionths
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	fraction := strings.TrimRight(fmt.Sprintf("%06d", n%rateScale), "0")
+	if fraction == "" {
+		return fmt.Sprintf("%v%d", sign, n/rateScale)
+	}
+	return fmt.Sprintf("%v%d.%v", sign, n/rateScale, fraction)
+}
+
+func (r Rate) IsZero() bool {
+	return r.millionths == 0
+}
+
+// Sign returns -1, 0 or 1
+func (r Rate) Sign() int {
+	switch {
+	case r.millionths < 0:
+		return -1
+	case r.millionths > 0:
+		return 1
+	}
+	return 0
+}
+
+// Cmp returns -1, 0 or 1 when r is less than, equal to or greater than other
+func (r Rate) Cmp(other Rate) int {
+	switch {
+	case r.millionths < other.millionths:
+		return -1
+	case r.millionths > other.millionths:
+		return 1
+	}
+	return 0
+}
+
+// Inverse returns 1 / r rounded half up to six decimal places, it is meant for reporting.
+// Amounts are converted with the inverse of a rate through DivRate, which does not round the rate
+func (r Rate) Inverse() Rate {
+	if r.millionths == 0 {
+		return Rate{}
+	}
+	inverse := new(big.Rat).SetFrac(big.NewInt(rateScale*rateScale), big.NewInt(r.millionths))
+	return Rate{round(inverse, HalfUp)}
+}
+
+// rat returns the exact value of the rate
+func (r Rate) rat() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(r.millionths), big.NewInt(rateScale))
+}
+
+// Scan implements sql.Scanner, NUMERIC values come as text from the driver
+func (r *Rate) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*r = Rate{}
+		return nil
+	case []byte:
+		parsed, err := ParseRate(string(v))
+		if err != nil {
+			return err
+		}
+		*r = parsed
+		return nil
+	case string:
+		parsed, err := ParseRate(v)
+		if err != nil {
+			return err
+		}
+		*r = parsed
+		return nil
+	case int64:
+		*r = Rate{v * rateScale}
+		return nil
+	}
+	return fmt.Errorf("Can not scan %T into rate", src)
+}
+
+// Value implements driver.Valuer, the rate is sent as text so NUMERIC receives it exactly
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// MarshalJSON writes the rate as a JSON number
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a string holding a number
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
@@ -0,0 +1,77 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	toParse := []string{"0", "1", "0.05", "18.5", "0.000001", "-0.5", " 3.10 "}
+	parsed := []int64{0, 1000000, 50000, 18500000, 1, -500000, 3100000}
+	for i := range toParse {
+		r, err := ParseRate(toParse[i])
+		assert.Nil(t, err)
+		assert.Equal(t, RateFromMillionths(parsed[i]), r)
+	}
+
+	for _, bad := range []string{"", "abc", "0.0000001", "1/2", "true", "0x10", "1_000"} {
+		_, err := ParseRate(bad)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestRateString(t *testing.T) {
+	assert.Equal(t, "0", Rate{}.String())
+	assert.Equal(t, "1", OneRate.String())
+	assert.Equal(t, "0.05", MustParseRate("0.050").String())
+	assert.Equal(t, "18.5", MustParseRate("18.5").String())
+	assert.Equal(t, "-0.000001", RateFromMillionths(-1).String())
+}
+
+func TestRateCmp(t *testing.T) {
+	assert.Equal(t, -1, MustParseRate("0.5").Cmp(OneRate))
+	assert.Equal(t, 0, MustParseRate("1.000").Cmp(OneRate))
+	assert.Equal(t, 1, MustParseRate("1.05").Cmp(OneRate))
+	assert.Equal(t, -1, MustParseRate("-0.1").Sign())
+	assert.Equal(t, 0, Rate{}.Sign())
+}
+
+func TestRateInverse(t *testing.T) {
+	assert.Equal(t, MustParseRate("0.025"), MustParseRate("40").Inverse())
+	assert.Equal(t, MustParseRate("0.333333"), MustParseRate("3").Inverse())
+	assert.Equal(t, MustParseRate("0.666667"), MustParseRate("1.5").Inverse())
+	assert.Equal(t, Rate{}, Rate{}.Inverse())
+}
+
+func TestRateScanAndValue(t *testing.T) {
+	r := Rate{}
+	assert.Nil(t, r.Scan([]byte("0.050000")))
+	assert.Equal(t, MustParseRate("0.05"), r)
+	assert.Nil(t, r.Scan("18.500000"))
+	assert.Equal(t, MustParseRate("18.5"), r)
+	assert.Nil(t, r.Scan(int64(2)))
+	assert.Equal(t, MustParseRate("2"), r)
+	assert.NotNil(t, r.Scan(1.5))
+
+	v, err := MustParseRate("0.015").Value()
+	assert.Nil(t, err)
+	assert.Equal(t, "0.015", v)
+}
+
+func TestRateJSON(t *testing.T) {
+	type wrapper struct {
+		Rate Rate `json:"rate"`
+	}
+	data, err := json.Marshal(wrapper{Rate: MustParseRate("0.015")})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"rate":0.015}`, string(data))
+
+	w := wrapper{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"rate":18.5}`), &w))
+	assert.Equal(t, MustParseRate("18.5"), w.Rate)
+	assert.Nil(t, json.Unmarshal([]byte(`{"rate":"0.05"}`), &w))
+	assert.Equal(t, MustParseRate("0.05"), w.Rate)
+	assert.NotNil(t, json.Unmarshal([]byte(`{"rate":0.1234567}`), &w))
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// openapi.json describes every route mounted in SetupAndGetRoutes, it has to be updated with them
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+type openAPIDocument struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+// registeredRoutes parses the Routes functions of the modules and this package, returning the
+// routes as "METHOD /path/:param"
+func registeredRoutes(t *testing.T) []string {
+	files, err := filepath.Glob("../modules/*/routes.go")
+	assert.Nil(t, err)
+	files = append(files, "routes.go")
+
+	methods := map[string]bool{"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true}
+	routes := []string{}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		assert.Nil(t, err)
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || !methods[sel.Sel.Name] {
+				return true
+			}
+			if x, ok := sel.X.(*ast.Ident); !ok || x.Name != "router" {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			path, err := strconv.Unquote(lit.Value)
+			assert.Nil(t, err)
+			routes = append(routes, sel.Sel.Name+" "+path)
+			return true
+		})
+	}
+	return routes
+}
+
+func TestOpenAPI(t *testing.T) {
+	// the document itself needs a token, it is served here without the middleware
+	r := httprouter.New()
+	r.GET("/openapi.json", OpenAPIHandler)
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	assert.Nil(t, err)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	doc := openAPIDocument{}
+	err = json.Unmarshal(w.Body.Bytes(), &doc)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(doc.OpenAPI, "3."))
+
+	// openapi writes the parameters as {param}
+	param := regexp.MustCompile(`:(\w+)`)
+	routes := registeredRoutes(t)
+	assert.NotEmpty(t, routes)
+	registered := map[string]bool{}
+	for _, route := range routes {
+		route = param.ReplaceAllString(route, "{$1}")
+		registered[route] = true
+		method, path, _ := strings.Cut(route, " ")
+		_, ok := doc.Paths[path][strings.ToLower(method)]
+		assert.True(t, ok, "route %v is missing from openapi.json", route)
+	}
+
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			route := strings.ToUpper(method) + " " + path
+			assert.True(t, registered[route], "openapi.json documents %v but it is not registered", route)
+		}
+	}
+}
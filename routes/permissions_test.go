@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grabielcruz/transportation_back/modules/users"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissions(t *testing.T) {
+	routes := registeredRoutes(t)
+	assert.NotEmpty(t, routes)
+	registered := map[string]bool{}
+	for _, route := range routes {
+		registered[route] = true
+		method, path, _ := strings.Cut(route, " ")
+		if users.IsPublicRoute(method, path) {
+			continue
+		}
+		_, ok := users.Permissions[route]
+		assert.True(t, ok, "route %v is missing from the permissions", route)
+	}
+
+	for route := range users.Permissions {
+		assert.True(t, registered[route], "permissions list %v but it is not registered", route)
+	}
+}
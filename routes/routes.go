@@ -4,9 +4,16 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/grabielcruz/transportation_back/modules/audit_log"
+	"github.com/grabielcruz/transportation_back/modules/bills"
+	"github.com/grabielcruz/transportation_back/modules/closed_periods"
+	"github.com/grabielcruz/transportation_back/modules/currencies"
+	"github.com/grabielcruz/transportation_back/modules/dashboard"
 	"github.com/grabielcruz/transportation_back/modules/money_accounts"
 	"github.com/grabielcruz/transportation_back/modules/persons"
+	"github.com/grabielcruz/transportation_back/modules/recurring_bills"
 	"github.com/grabielcruz/transportation_back/modules/transactions"
+	"github.com/grabielcruz/transportation_back/modules/users"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -15,14 +22,23 @@ func InitialHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params)
 	fmt.Fprint(w, "pong")
 }
 
-func SetupAndGetRoutes() *httprouter.Router {
+// SetupAndGetRoutes mounts the routes of every module behind the authentication middleware
+func SetupAndGetRoutes() http.Handler {
 	router := httprouter.New()
 
 	router.GET("/ping", InitialHandler)
+	router.GET("/openapi.json", OpenAPIHandler)
 
 	money_accounts.Routes(router)
 	persons.Routes(router)
 	transactions.Routes(router)
+	bills.Routes(router)
+	currencies.Routes(router)
+	recurring_bills.Routes(router)
+	dashboard.Routes(router)
+	closed_periods.Routes(router)
+	users.Routes(router)
+	audit_log.Routes(router)
 
-	return router
+	return users.RequireAuth(router)
 }
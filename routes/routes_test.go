@@ -1,10 +1,12 @@
 package routes
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	errors_handler "github.com/grabielcruz/transportation_back/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,4 +22,29 @@ func TestBasicRoute(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Equal(t, "pong", w.Body.String())
 	})
+
+	t.Run("Error when calling a route without token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/money_accounts", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		var errResponse errors_handler.ErrorResponse
+		err := json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "AU001", errResponse.Code)
+	})
+
+	t.Run("Error when calling a route with a badly signed token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/money_accounts", nil)
+		req.Header.Set("Authorization", "Bearer e30.c2lnbmF0dXJl")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		var errResponse errors_handler.ErrorResponse
+		err := json.Unmarshal(w.Body.Bytes(), &errResponse)
+		assert.Nil(t, err)
+		assert.Equal(t, "AU001", errResponse.Code)
+	})
 }
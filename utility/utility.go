@@ -4,6 +4,8 @@ import (
 	"math"
 	"math/rand"
 	"time"
+
+	"github.com/grabielcruz/transportation_back/money"
 )
 
 var seed = time.Now().Unix()
@@ -18,28 +20,29 @@ func GetRandomString(length int) string {
 	return ran_str
 }
 
-func GetRandomBalance() float64 {
+func GetRandomBalance() money.Money {
 	rand.Seed(change_seed())
-	balance := rand.Float64()
-	return RoundToTwoDecimalPlaces(balance)
+	return money.FromCents(rand.Int63n(101))
 }
 
-func GetRandomFee() float64 {
-	return RoundToTwoDecimalPlaces(rand.Float64())
+// GetRandomFee returns a fee of whole percents between 0 and 0.99
+func GetRandomFee() money.Rate {
+	rand.Seed(change_seed())
+	return money.RateFromMillionths(rand.Int63n(100) * 10000)
 }
 
-func GetRandomNonZeroBalance() float64 {
+func GetRandomNonZeroBalance() money.Money {
 	balance := GetRandomBalance()
 	if balance == 0 {
-		balance = 1.00
+		balance = money.FromUnits(1)
 	}
 	return balance
 }
 
-func GetRandomPositiveBalance() float64 {
+func GetRandomPositiveBalance() money.Money {
 	balance := GetRandomBalance()
 	if balance == 0 {
-		balance = 1.00
+		balance = money.FromUnits(1)
 	}
 	if balance < 0 {
 		balance = balance * -1
@@ -47,10 +50,10 @@ func GetRandomPositiveBalance() float64 {
 	return balance
 }
 
-func GetRandomNegativeBalance() float64 {
+func GetRandomNegativeBalance() money.Money {
 	balance := GetRandomBalance()
 	if balance == 0 {
-		balance = -1.00
+		balance = money.FromUnits(-1)
 	}
 	if balance > 0 {
 		balance = balance * -1
@@ -63,10 +66,6 @@ func getRandomFloat64() float64 {
 	return rand.Float64()
 }
 
-func RoundToTwoDecimalPlaces(f float64) float64 {
-	return math.Round(f*100) / 100
-}
-
 func GetRandomBoolean() bool {
 	rand.Seed(change_seed())
 	return rand.Intn(2) == 1
@@ -93,32 +92,31 @@ func GenerateDummyData() DummyType {
 	return dummy
 }
 
-func GetSliceOfAmounts(total int) []float64 {
-	nums := []float64{}
+func GetSliceOfAmounts(total int) []money.Money {
+	nums := []money.Money{}
 	for i := 1; i < total+1; i++ {
 		f := getRandomFloat64() * 10
 		if i%10 == 0 {
 			f *= -1
 			f /= 10
 		}
-		nums = append(nums, f)
+		nums = append(nums, money.FromCents(int64(math.Round(f*100))))
 	}
 	return nums
 }
 
-func GetSumOfAmounts(nums []float64) float64 {
-	sum := float64(0)
+func GetSumOfAmounts(nums []money.Money) money.Money {
+	sum := money.Zero
 	for i := 0; i < len(nums); i++ {
-		sum = RoundToTwoDecimalPlaces(sum + RoundToTwoDecimalPlaces(nums[i]))
+		sum += nums[i]
 	}
 	return sum
 }
 
-func GetSumOfAmountsWithFee(nums []float64, fee float64) float64 {
-	sum := float64(0)
+func GetSumOfAmountsWithFee(nums []money.Money, fee money.Rate) money.Money {
+	sum := money.Zero
 	for i := 0; i < len(nums); i++ {
-		amount := RoundToTwoDecimalPlaces(RoundToTwoDecimalPlaces(nums[i]) * RoundToTwoDecimalPlaces(1+fee))
-		sum = RoundToTwoDecimalPlaces(sum + amount)
+		sum += nums[i].WithFee(fee, money.HalfUp)
 	}
 	return sum
 }
@@ -3,6 +3,7 @@ package utility
 import (
 	"testing"
 
+	"github.com/grabielcruz/transportation_back/money"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -52,10 +53,12 @@ func TestGetRandomCurrency(t *testing.T) {
 	}
 }
 
-func TestRoundToTwoDecimalPlaces(t *testing.T) {
-	toRound := []float64{10.099, 0.012, 0.496, 0.454, 0.456, 1.996, -1.996, -1.995, -1.994, 1.96}
-	rounded := []float64{10.10, 0.01, 0.50, 0.45, 0.46, 2.00, -2.00, -2.00, -1.99, 1.96}
-	for i := range toRound {
-		assert.Equal(t, rounded[i], RoundToTwoDecimalPlaces(toRound[i]))
+func TestRandomFee(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		fee := GetRandomFee()
+		assert.GreaterOrEqual(t, fee.Sign(), 0)
+		assert.Equal(t, -1, fee.Cmp(money.OneRate))
+		// whole percents
+		assert.Equal(t, int64(0), fee.Millionths()%10000)
 	}
 }